@@ -0,0 +1,340 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "io"
+    "log"
+    "os"
+    "sort"
+    "strings"
+)
+
+// animationConfig describes keyframed changes to the camera and scene objects over a range of frames
+type animationConfig struct {
+    // StartFrame and EndFrame are the first and last frames rendered by default
+    StartFrame, EndFrame int
+
+    // FrameRate is the number of frames per second, used with the camera ShutterSpeed for motion blur.  Defaults to 24
+    FrameRate float32
+
+    // Camera animates the camera json fields such as LookFrom, LookAt and Fov
+    Camera animationTrack
+
+    // Objects animates scene objects by name, keyframes hold object fields such as Origin, Radius and Properties
+    Objects map[string]animationTrack
+}
+
+// animationTrack is a list of keyframes and how to interpolate between them.
+// Each keyframe is a partial json object with a Frame number, every number in it is animated separately
+type animationTrack struct {
+    // Interpolation is linear, bezier or catmullrom.  Defaults to linear
+    Interpolation string
+
+    // Keyframes hold the values at each Frame.  Bezier keyframes may also hold InTangent and OutTangent objects giving the change per frame on either side, missing tangents are flat
+    Keyframes []map[string]interface{}
+}
+
+const (
+    // LinearInterpolation moves at constant speed between keyframes
+    LinearInterpolation = "linear"
+
+    // BezierInterpolation uses cubic Bezier segments shaped by each keyframe's InTangent and OutTangent
+    BezierInterpolation = "bezier"
+
+    // CatmullRomInterpolation passes smoothly through every keyframe using its neighbours to pick tangents
+    CatmullRomInterpolation = "catmullrom"
+)
+
+// channelKey is the value of one number at one keyframe
+type channelKey struct {
+    frame float64
+    value float64
+    inTangent, outTangent float64
+}
+
+// animationChannel is a single animated number such as LookFrom.X
+type animationChannel struct {
+    path []string
+    keys []channelKey
+}
+
+// animatedTarget is the parsed form of an animationTrack
+type animatedTarget struct {
+    interpolation string
+    channels []animationChannel
+}
+
+// animationSettings is the global animation loaded by ImportAnimation
+var animationSettings animationConfig
+
+var cameraAnimation animatedTarget
+var objectAnimations map[string]animatedTarget
+
+// baseCamera and baseObjects hold the camera and objects as they were before any animation was applied
+var baseCamera cameraConfig
+var baseObjects map[string]map[string]interface{}
+
+// ImportAnimation will import an animation json file, the scene and camera must already be imported
+func ImportAnimation(filename string) {
+    animationFile, err := os.Open(filename)
+    checkError(err)
+    defer animationFile.Close()
+
+    info, err := animationFile.Stat()
+    checkError(err)
+
+    contents := make([]byte, info.Size())
+
+    _, err = animationFile.Read(contents)
+    if (err != nil && io.EOF != err) {
+        checkError(err)
+    }
+
+    animationSettings = animationConfig{}
+    err = json.Unmarshal(contents, &animationSettings)
+    checkError(err)
+
+    baseCamera = cameraSettings
+    cameraAnimation = parseAnimationTrack("Camera", animationSettings.Camera)
+
+    objectAnimations = make(map[string]animatedTarget)
+    baseObjects = make(map[string]map[string]interface{})
+    for name, track := range animationSettings.Objects {
+        obj, exists := Scene.Scene.collisionList[name]
+        if (false == exists) {
+            log.Fatalf("Animation refers to object %q which is not in the scene", name)
+        }
+
+        baseObjects[name] = toJSONObject(obj)
+        objectAnimations[name] = parseAnimationTrack(name, track)
+    }
+}
+
+// AnimationFrameRange returns the first and last frame of the imported animation
+func AnimationFrameRange() (int, int) {
+    return animationSettings.StartFrame, animationSettings.EndFrame
+}
+
+// ApplyAnimationFrame sets the global camera and animated scene objects to their values at the given frame
+func ApplyAnimationFrame(frame int) {
+    if (len(cameraAnimation.channels) > 0) {
+        camera := toJSONObject(baseCamera)
+        cameraAnimation.apply(camera, float64(frame))
+
+        b, err := json.Marshal(camera)
+        checkError(err)
+
+        var config cameraConfig
+        err = json.Unmarshal(b, &config)
+        checkError(err)
+
+        setGlobalCamera(config)
+    }
+
+    if (len(objectAnimations) == 0) {
+        return
+    }
+
+    names := make([]string, 0, len(objectAnimations))
+    for name := range objectAnimations {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    // The shutter stays open for part of the following frame, objects are blurred over the distance they cover in that time
+    shutterFrames := float64(cameraSettings.ShutterSpeed * defaultFloat(animationSettings.FrameRate, 24.0))
+
+    for _, name := range names {
+        sphere := animatedSphere(name, float64(frame))
+        if (shutterFrames > 0.0) {
+            closed := animatedSphere(name, float64(frame) + shutterFrames)
+            sphere.Motion = sphere.Motion.Add(closed.Origin.Subtract(sphere.Origin))
+        }
+
+        Scene.AddObject(name, sphere)
+    }
+
+    Scene.BuildHierarchy()
+}
+
+// animatedSphere returns the named object at frame
+func animatedSphere(name string, frame float64) Sphere {
+    object := toJSONObject(baseObjects[name])
+    objectAnimations[name].apply(object, frame)
+
+    sphere, ok := deserializeSphere(object)
+    if (false == ok) {
+        log.Fatalf("Animated object %q is not a valid sphere at frame %v", name, frame)
+    }
+
+    return sphere
+}
+
+// toJSONObject converts a value to its generic json object form
+func toJSONObject(v interface{}) map[string]interface{} {
+    b, err := json.Marshal(v)
+    checkError(err)
+
+    var object map[string]interface{}
+    err = json.Unmarshal(b, &object)
+    checkError(err)
+
+    return object
+}
+
+// parseAnimationTrack splits the keyframes of a track into one channel per animated number
+func parseAnimationTrack(name string, track animationTrack) animatedTarget {
+    target := animatedTarget{ interpolation: track.Interpolation }
+    switch target.interpolation {
+        case "":
+            target.interpolation = LinearInterpolation
+        case LinearInterpolation, BezierInterpolation, CatmullRomInterpolation:
+        default:
+            log.Fatalf("Unknown interpolation %q for %v", track.Interpolation, name)
+    }
+
+    channels := make(map[string]*animationChannel)
+    var order []string
+
+    for _, keyframe := range track.Keyframes {
+        frame, ok := keyframe["Frame"].(float64)
+        if (false == ok) {
+            log.Fatalf("Keyframe for %v is missing a Frame number", name)
+        }
+
+        values := make(map[string]float64)
+        inTangents := make(map[string]float64)
+        outTangents := make(map[string]float64)
+        for key, value := range keyframe {
+            switch key {
+                case "Frame":
+                case "InTangent":
+                    flattenJSON(nil, value, inTangents)
+                case "OutTangent":
+                    flattenJSON(nil, value, outTangents)
+                default:
+                    flattenJSON([]string{ key }, value, values)
+            }
+        }
+
+        for path, value := range values {
+            channel, exists := channels[path]
+            if (false == exists) {
+                channel = &animationChannel{ path: strings.Split(path, ".") }
+                channels[path] = channel
+                order = append(order, path)
+            }
+
+            channel.keys = append(channel.keys, channelKey {
+                frame: frame,
+                value: value,
+                inTangent: inTangents[path],
+                outTangent: outTangents[path] })
+        }
+    }
+
+    sort.Strings(order)
+    for _, path := range order {
+        channel := channels[path]
+        sort.SliceStable(channel.keys, func(a, b int) bool {
+            return channel.keys[a].frame < channel.keys[b].frame
+        })
+
+        target.channels = append(target.channels, *channel)
+    }
+
+    return target
+}
+
+// flattenJSON records every number in value under its dotted path
+func flattenJSON(path []string, value interface{}, out map[string]float64) {
+    switch v := value.(type) {
+        case float64:
+            out[strings.Join(path, ".")] = v
+        case map[string]interface{}:
+            for key, child := range v {
+                flattenJSON(append(append([]string{}, path...), key), child, out)
+            }
+    }
+}
+
+// setJSONPath sets the number at path inside object, creating objects along the way
+func setJSONPath(object map[string]interface{}, path []string, value float64) {
+    for _, key := range path[:len(path) - 1] {
+        child, ok := object[key].(map[string]interface{})
+        if (false == ok) {
+            child = make(map[string]interface{})
+            object[key] = child
+        }
+
+        object = child
+    }
+
+    object[path[len(path) - 1]] = value
+}
+
+// apply writes every channel's value at frame into object
+func (a animatedTarget) apply(object map[string]interface{}, frame float64) {
+    for _, channel := range a.channels {
+        setJSONPath(object, channel.path, channel.evaluate(frame, a.interpolation))
+    }
+}
+
+// evaluate returns the channel's value at frame, holding the first and last keyframe values outside of the keyed range
+func (c animationChannel) evaluate(frame float64, interpolation string) float64 {
+    keys := c.keys
+    if (frame <= keys[0].frame) {
+        return keys[0].value
+    }
+
+    last := len(keys) - 1
+    if (frame >= keys[last].frame) {
+        return keys[last].value
+    }
+
+    i := sort.Search(len(keys), func(k int) bool { return keys[k].frame > frame }) - 1
+    k0 := keys[i]
+    k1 := keys[i + 1]
+    span := k1.frame - k0.frame
+    t := (frame - k0.frame) / span
+
+    switch interpolation {
+        case BezierInterpolation:
+            return hermite(k0.value, k0.outTangent * span, k1.value, k1.inTangent * span, t)
+        case CatmullRomInterpolation:
+            return hermite(k0.value, catmullRomTangent(keys, i) * span, k1.value, catmullRomTangent(keys, i + 1) * span, t)
+    }
+
+    return k0.value + (k1.value - k0.value) * t
+}
+
+// catmullRomTangent returns the change per frame at key i using the keys on either side of it
+func catmullRomTangent(keys []channelKey, i int) float64 {
+    before := i - 1
+    if (before < 0) {
+        before = 0
+    }
+
+    after := i + 1
+    if (after >= len(keys)) {
+        after = len(keys) - 1
+    }
+
+    if (keys[after].frame == keys[before].frame) {
+        return 0.0
+    }
+
+    return (keys[after].value - keys[before].value) / (keys[after].frame - keys[before].frame)
+}
+
+// hermite evaluates the cubic curve from p0 to p1 with tangents m0 and m1 at t in [0, 1].
+// A cubic Bezier with handles p0 + m0 / 3 and p1 - m1 / 3 is the same curve
+func hermite(p0, m0, p1, m1, t float64) float64 {
+    t2 := t * t
+    t3 := t2 * t
+    return (2.0 * t3 - 3.0 * t2 + 1.0) * p0 +
+        (t3 - 2.0 * t2 + t) * m0 +
+        (-2.0 * t3 + 3.0 * t2) * p1 +
+        (t3 - t2) * m1
+}
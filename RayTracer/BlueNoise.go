@@ -0,0 +1,97 @@
+package raytracer
+
+import
+(
+    "math"
+    "sync"
+)
+
+// BlueNoiseSampler draws samples from a Sobol sequence shared by all pixels and offsets them per pixel using a tiled blue noise mask.
+// Neighbouring pixels get very different offsets so the remaining error shows up as high frequency noise instead of blotches
+type BlueNoiseSampler struct {
+    samplerState
+}
+
+// blueNoiseSize is the width and height of the tiled blue noise mask
+const blueNoiseSize = 32
+
+var blueNoiseMask []float32
+var blueNoiseMaskOnce sync.Once
+
+// generateBlueNoiseMask builds a blue noise threshold mask using the void-and-cluster method.
+// Pixels are ranked by repeatedly filling the largest void, measured with a toroidal Gaussian energy function
+func generateBlueNoiseMask() {
+    const size = blueNoiseSize
+    const sigma = 1.5
+
+    // Precompute the energy contributed by a filled pixel at every toroidal offset
+    var kernel [size][size]float64
+    for dy := 0; dy < size; dy++ {
+        for dx := 0; dx < size; dx++ {
+            x := math.Min(float64(dx), float64(size - dx))
+            y := math.Min(float64(dy), float64(size - dy))
+            kernel[dy][dx] = math.Exp(-(x * x + y * y) / (2.0 * sigma * sigma))
+        }
+    }
+
+    energy := make([]float64, size * size)
+    filled := make([]bool, size * size)
+    blueNoiseMask = make([]float32, size * size)
+
+    for rank := 0; rank < size * size; rank++ {
+        // Find the largest void, the unfilled pixel with the least energy
+        void := -1
+        for i := range energy {
+            if (!filled[i] && (void < 0 || energy[i] < energy[void])) {
+                void = i
+            }
+        }
+
+        filled[void] = true
+        blueNoiseMask[void] = (float32(rank) + 0.5) / float32(size * size)
+
+        vx := void % size
+        vy := void / size
+        for y := 0; y < size; y++ {
+            for x := 0; x < size; x++ {
+                energy[y * size + x] += kernel[(y - vy + size) % size][(x - vx + size) % size]
+            }
+        }
+    }
+}
+
+// blueNoiseOffset returns the mask value for the pixel at x, y, toroidally shifted by the given dimension
+func blueNoiseOffset(x, y int, d uint32) float32 {
+    blueNoiseMaskOnce.Do(generateBlueNoiseMask)
+
+    shift := hashUint32(d * 0x68bc21eb)
+    mx := (uint32(x) + shift) % blueNoiseSize
+    my := (uint32(y) + (shift >> 16)) % blueNoiseSize
+    return blueNoiseMask[my * blueNoiseSize + mx]
+}
+
+// Get1D returns the next dimension of the shared sequence dithered by the blue noise mask
+func (s *BlueNoiseSampler) Get1D() float32 {
+    d := s.nextDimension()
+    v := owenScrambledSobol(s.sampleIndex, d, s.seed) + blueNoiseOffset(s.x, s.y, d)
+    if (v >= 1.0) {
+        v -= 1.0
+    }
+
+    return clampSample(v)
+}
+
+// Get2D returns the next two dithered dimensions, aligned so both come from the same pair of the group
+func (s *BlueNoiseSampler) Get2D() (float32, float32) {
+    if (s.dimension % 2 == 1) {
+        s.nextDimension()
+    }
+
+    return s.Get1D(), s.Get1D()
+}
+
+// Clone returns a copy of the blue noise sampler
+func (s *BlueNoiseSampler) Clone() Sampler {
+    c := *s
+    return &c
+}
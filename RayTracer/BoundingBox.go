@@ -0,0 +1,101 @@
+package raytracer
+
+import
+(
+    "math"
+)
+
+// BoundingBox is an axis aligned box used to quickly reject rays that cannot hit an object
+type BoundingBox struct {
+    Min, Max Vector3
+}
+
+// EmptyBoundingBox returns a box that contains nothing, growing it by any point or box gives that point or box
+func EmptyBoundingBox() BoundingBox {
+    return BoundingBox {
+        Min: NewVector3(math.MaxFloat32, math.MaxFloat32, math.MaxFloat32),
+        Max: NewVector3(-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32) }
+}
+
+// Union returns the smallest box containing both b and a
+func (b BoundingBox) Union(a BoundingBox) BoundingBox {
+    return BoundingBox {
+        Min: NewVector3(
+            float32(math.Min(float64(b.Min.X), float64(a.Min.X))),
+            float32(math.Min(float64(b.Min.Y), float64(a.Min.Y))),
+            float32(math.Min(float64(b.Min.Z), float64(a.Min.Z)))),
+        Max: NewVector3(
+            float32(math.Max(float64(b.Max.X), float64(a.Max.X))),
+            float32(math.Max(float64(b.Max.Y), float64(a.Max.Y))),
+            float32(math.Max(float64(b.Max.Z), float64(a.Max.Z)))) }
+}
+
+// AddPoint returns the smallest box containing both b and p
+func (b BoundingBox) AddPoint(p Vector3) BoundingBox {
+    return b.Union(BoundingBox{ Min: p, Max: p })
+}
+
+// Center returns the point in the middle of the box
+func (b BoundingBox) Center() Vector3 {
+    return b.Min.Add(b.Max).Scale(0.5)
+}
+
+// Extent returns the size of the box along each axis
+func (b BoundingBox) Extent() Vector3 {
+    return b.Max.Subtract(b.Min)
+}
+
+// LargestAxis returns 0, 1, or 2 for whichever of X, Y, or Z the box is longest along
+func (b BoundingBox) LargestAxis() int {
+    e := b.Extent()
+    if (e.X >= e.Y && e.X >= e.Z) {
+        return 0
+    } else if (e.Y >= e.Z) {
+        return 1
+    }
+
+    return 2
+}
+
+// TestIntersection tests if the ray passes through the box between tMin and tMax using the slab method
+func (b BoundingBox) TestIntersection(r Ray, tMin, tMax float32) bool {
+    origin := [3]float32{ r.Origin.X, r.Origin.Y, r.Origin.Z }
+    direction := [3]float32{ r.Direction.X, r.Direction.Y, r.Direction.Z }
+    boxMin := [3]float32{ b.Min.X, b.Min.Y, b.Min.Z }
+    boxMax := [3]float32{ b.Max.X, b.Max.Y, b.Max.Z }
+
+    for axis := 0; axis < 3; axis++ {
+        invD := 1.0 / direction[axis]
+        t0 := (boxMin[axis] - origin[axis]) * invD
+        t1 := (boxMax[axis] - origin[axis]) * invD
+        if (invD < 0.0) {
+            t0, t1 = t1, t0
+        }
+
+        if (t0 > tMin) {
+            tMin = t0
+        }
+
+        if (t1 < tMax) {
+            tMax = t1
+        }
+
+        if (tMax < tMin) {
+            return false
+        }
+    }
+
+    return true
+}
+
+// component returns the X, Y, or Z value of v for axis 0, 1, or 2
+func component(v Vector3, axis int) float32 {
+    switch axis {
+        case 0:
+            return v.X
+        case 1:
+            return v.Y
+    }
+
+    return v.Z
+}
@@ -0,0 +1,114 @@
+package raytracer
+
+import
+(
+    "sort"
+)
+
+// maxObjectsPerLeaf is the number of objects a hierarchy node holds before it is split
+const maxObjectsPerLeaf = 4
+
+// bvhNode is a node of the bounding volume hierarchy, leaves reference a range of the ordered object list
+type bvhNode struct {
+    bounds BoundingBox
+    left, right *bvhNode
+    first, count int
+}
+
+// hierarchyOrder sorts objects along an axis and moves their indices with them
+type hierarchyOrder struct {
+    objects []CollidableObject
+    indices []int
+    axis int
+}
+
+// Len returns the number of objects
+func (h hierarchyOrder) Len() int {
+    return len(h.objects)
+}
+
+// Less compares the centers of two objects along the axis
+func (h hierarchyOrder) Less(a, b int) bool {
+    return component(h.objects[a].BoundingBox().Center(), h.axis) < component(h.objects[b].BoundingBox().Center(), h.axis)
+}
+
+// Swap swaps two objects and their indices
+func (h hierarchyOrder) Swap(a, b int) {
+    h.objects[a], h.objects[b] = h.objects[b], h.objects[a]
+    h.indices[a], h.indices[b] = h.indices[b], h.indices[a]
+}
+
+// buildHierarchy builds a bounding volume hierarchy over objects, reordering the slice so every leaf covers a contiguous range.
+// indices holds the original index of each object and is reordered the same way
+func buildHierarchy(objects []CollidableObject, indices []int, first int) *bvhNode {
+    node := &bvhNode {
+        bounds: EmptyBoundingBox(),
+        first: first,
+        count: len(objects) }
+
+    centroids := EmptyBoundingBox()
+    for _, obj := range objects {
+        box := obj.BoundingBox()
+        node.bounds = node.bounds.Union(box)
+        centroids = centroids.AddPoint(box.Center())
+    }
+
+    if (len(objects) <= maxObjectsPerLeaf) {
+        return node
+    }
+
+    // Split at the median centroid along the axis the centroids are most spread out on
+    axis := centroids.LargestAxis()
+    sort.Stable(hierarchyOrder{ objects: objects, indices: indices, axis: axis })
+
+    middle := len(objects) / 2
+    node.left = buildHierarchy(objects[:middle], indices[:middle], first)
+    node.right = buildHierarchy(objects[middle:], indices[middle:], first + middle)
+    node.count = 0
+    return node
+}
+
+// traverseHierarchy finds the closest object in the hierarchy hit by the ray, counting every box and object test in counters when it is not nil.
+// indices maps each object to its original index for the per object counts
+func traverseHierarchy(root *bvhNode, objects []CollidableObject, indices []int, r Ray, tMin, tMax float32, counters *collisionCounters) (bool, IntersectionRecord) {
+    collisionDetected := false
+    var closestHitRecord IntersectionRecord
+    closestT := tMax
+
+    stack := make([]*bvhNode, 0, 64)
+    stack = append(stack, root)
+
+    for len(stack) > 0 {
+        node := stack[len(stack) - 1]
+        stack = stack[:len(stack) - 1]
+
+        if (counters != nil) {
+            counters.Tests++
+        }
+
+        if (false == node.bounds.TestIntersection(r, tMin, closestT)) {
+            continue
+        }
+
+        if (node.left == nil) {
+            for i := node.first; i < node.first + node.count; i++ {
+                isColliding, hitRecord := objects[i].TestIntersection(r, tMin, closestT)
+                if (counters != nil) {
+                    counters.count(indices[i], isColliding)
+                }
+
+                if (isColliding) {
+                    collisionDetected = true
+                    closestHitRecord = hitRecord
+                    closestT = hitRecord.T
+                }
+            }
+
+            continue
+        }
+
+        stack = append(stack, node.right, node.left)
+    }
+
+    return collisionDetected, closestHitRecord
+}
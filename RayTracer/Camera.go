@@ -4,18 +4,122 @@ import
 (
     "encoding/json"
     "io"
+    "log"
     "math"
     "os"
 )
 
-// Camera is a struct to contain info about our virtual camera
-type Camera struct {
+// Camera is an interface for projections that turn a position on the image into a ray
+type Camera interface {
+    // GetRay returns the ray through the image at u, v where 0, 0 is the upper left corner and 1, 1 the lower right.
+    // It returns false if the projection does not cover that part of the image
+    // The sampler picks the point on the lens and the time during the exposure
+    GetRay(u, v float32, s Sampler) (Ray, bool)
+}
+
+// PerspectiveCamera is a pinhole or thin lens camera, it is a struct to contain info about our virtual camera
+type PerspectiveCamera struct {
     Origin, ImagePlaneHorizontal, ImagePlaneVertical, UpperLeftCorner Vector3
+
+    // LensRadius is the radius of the aperture, 0 for a pinhole.  Points FocusDistance in front of the camera are in focus
+    LensRadius, FocusDistance float32
+    Right, Up Vector3
+
+    // MotionBlur spreads rays over the time the shutter is open
+    MotionBlur bool
+}
+
+// OrthographicCamera shoots parallel rays from a rectangle, useful for technical drawings
+type OrthographicCamera struct {
+    Direction, ImagePlaneHorizontal, ImagePlaneVertical, UpperLeftCorner Vector3
+    MotionBlur bool
 }
 
+// FisheyeCamera maps the angle from the view direction to the distance from the image center, covering Fov degrees across the image circle
+type FisheyeCamera struct {
+    Origin, Forward, Right, Up Vector3
+    Fov float32
+    Mapping string
+    AspectRatio float32
+    ShiftX, ShiftY float32
+    MotionBlur bool
+}
+
+// EquirectangularCamera is a full 360 degree panorama with longitude along the width and latitude along the height
+type EquirectangularCamera struct {
+    Origin, Forward, Right, Up Vector3
+    MotionBlur bool
+}
+
+const (
+    // PerspectiveCameraType is a pinhole camera
+    PerspectiveCameraType = "perspective"
+
+    // OrthographicCameraType is a camera with parallel rays
+    OrthographicCameraType = "orthographic"
+
+    // FisheyeCameraType is a circular fisheye lens
+    FisheyeCameraType = "fisheye"
+
+    // EquirectangularCameraType is a 360 degree panorama
+    EquirectangularCameraType = "equirectangular"
+
+    // EquidistantFisheyeMapping makes distance from the image center proportional to the angle
+    EquidistantFisheyeMapping = "equidistant"
+
+    // EquisolidFisheyeMapping makes image area proportional to solid angle
+    EquisolidFisheyeMapping = "equisolid"
+
+    // VerticalFovAxis means Fov spans the image height
+    VerticalFovAxis = "vertical"
+
+    // HorizontalFovAxis means Fov spans the image width
+    HorizontalFovAxis = "horizontal"
+)
+
 type cameraConfig struct {
     LookFrom, LookAt Vector3
     Fov float32
+
+    // Up is the direction that appears upwards in the image.  Defaults to 0, 1, 0
+    Up Vector3
+
+    // Roll banks the camera clockwise around its view direction by this many degrees
+    Roll float32 `json:",omitempty"`
+
+    // FovAxis is vertical or horizontal, the image axis Fov spans.  Defaults to vertical
+    FovAxis string `json:",omitempty"`
+
+    // ShiftX and ShiftY move the image right and up by a fraction of its width and height without changing perspective.  Ignored by equirectangular cameras
+    ShiftX float32 `json:",omitempty"`
+    ShiftY float32 `json:",omitempty"`
+
+    // ISO, ShutterSpeed in seconds and FNumber describe a physical camera.  When all three are set radiance is in cd/m^2 and is scaled by the resulting exposure.
+    // FNumber alone sets the aperture for depth of field and ShutterSpeed alone turns on motion blur
+    ISO float32 `json:",omitempty"`
+    ShutterSpeed float32 `json:",omitempty"`
+    FNumber float32 `json:",omitempty"`
+
+    // SensorHeight in millimetres is used with Fov to find the focal length for depth of field.  Defaults to 24
+    SensorHeight float32 `json:",omitempty"`
+
+    // FocusDistance is the distance to the plane in focus.  Defaults to the distance to LookAt
+    FocusDistance float32 `json:",omitempty"`
+
+    // MetersPerUnit is the size of one scene unit, used to size the aperture.  Defaults to 1
+    MetersPerUnit float32 `json:",omitempty"`
+
+    // WhiteBalance is the color temperature in kelvin that appears white in the image.  Defaults to 6500
+    WhiteBalance float32 `json:",omitempty"`
+
+    // Type is the projection, one of perspective, orthographic, fisheye or equirectangular.  Defaults to perspective
+    Type string `json:",omitempty"`
+
+    // OrthographicHeight is the height of the view in world units for orthographic cameras.  Defaults to the height a perspective camera would see at LookAt
+    OrthographicHeight float32 `json:",omitempty"`
+
+    // FisheyeMapping is equidistant or equisolid for fisheye cameras.  Defaults to equidistant
+    FisheyeMapping string `json:",omitempty"`
 }
 
 // GlobalCamera is the main camera object used in rendering
@@ -30,12 +134,12 @@ func ConvertDegreesToRadians(degrees float32) float32 {
 }
 
 // CreateCamera will create a camera object with the vertical feild of view and aspect ratio requested
-func CreateCamera(vFov, aspectRatio float32) Camera {
+func CreateCamera(vFov, aspectRatio float32) PerspectiveCamera {
     theta := ConvertDegreesToRadians(vFov)
     halfHeight := float32(math.Tan(float64(theta / 2.0)))
     halfWidth := aspectRatio * halfHeight
     
-    return Camera {
+    return PerspectiveCamera {
         Origin: Vector3 {
             X: 0.0,
             Y: 0.0,
@@ -54,26 +158,232 @@ func CreateCamera(vFov, aspectRatio float32) Camera {
             Z: -1.0 } }
 }
 
+// cameraBasis returns the unit forward, right, and up vectors of a camera looking at a point from another point, banked clockwise by roll degrees.
+// When upVec is parallel to the view direction the camera is treated as tilted straight up or down from the horizon instead
+func cameraBasis(lookat, lookfrom, upVec Vector3, roll float32) (Vector3, Vector3, Vector3) {
+    w := lookat.Subtract(lookfrom).UnitVector()
+    upVec = upVec.UnitVector()
+    
+    if (w.Cross(upVec).Length() < 1e-4) {
+        tangent, _ := orthonormalBasis(upVec)
+        horizontalForward := tangent.Scale(-1.0)
+        if (w.Dot(upVec) > 0.0) {
+            upVec = horizontalForward.Scale(-1.0)
+        } else {
+            upVec = horizontalForward
+        }
+    }
+    
+    u := upVec.Cross(w).UnitVector()
+    v := u.Cross(w).UnitVector()
+    right := u.Scale(-1.0)
+    up := v.Scale(-1.0)
+    
+    if (roll != 0.0) {
+        theta := float64(ConvertDegreesToRadians(roll))
+        cos := float32(math.Cos(theta))
+        sin := float32(math.Sin(theta))
+        right, up = right.Scale(cos).Subtract(up.Scale(sin)), up.Scale(cos).Add(right.Scale(sin))
+    }
+    
+    return w, right, up
+}
+
+// verticalFov returns the vertical field of view of config in degrees, converting it if Fov was given horizontally
+func verticalFov(config cameraConfig, aspectRatio float32) float32 {
+    switch config.FovAxis {
+        case "", VerticalFovAxis:
+            return config.Fov
+        case HorizontalFovAxis:
+            halfWidth := math.Tan(float64(ConvertDegreesToRadians(config.Fov)) / 2.0)
+            return float32(2.0 * math.Atan(halfWidth / float64(aspectRatio)) * 180.0 / math.Pi)
+    }
+    
+    log.Fatalf("Unknown fov axis %q", config.FovAxis)
+    return 0.0
+}
+
+// defaultUpVector is the up vector used when a camera does not specify one
+var defaultUpVector = Vector3 {
+    X: 0.0,
+    Y: 1.0,
+    Z: 0.0 }
+
 // CreateCameraFromPos will create a camera looking at a point from another point
-func CreateCameraFromPos(lookat, lookfrom, upVec Vector3, vFov, aspectRatio float32) Camera {
-    cameraSettings.LookAt = lookat
-    cameraSettings.LookFrom = lookfrom
-    cameraSettings.Fov = vFov
+func CreateCameraFromPos(lookat, lookfrom, upVec Vector3, vFov, aspectRatio float32) PerspectiveCamera {
+    cameraSettings = cameraConfig {
+        LookAt: lookat,
+        LookFrom: lookfrom,
+        Up: upVec,
+        Fov: vFov }
     
-    theta := ConvertDegreesToRadians(vFov)
+    return createPerspectiveCamera(cameraSettings, aspectRatio)
+}
+
+// createPerspectiveCamera creates a pinhole camera looking at a point from another point
+func createPerspectiveCamera(config cameraConfig, aspectRatio float32) PerspectiveCamera {
+    theta := ConvertDegreesToRadians(verticalFov(config, aspectRatio))
     halfHeight := float32(math.Tan(float64(theta / 2.0)))
     halfWidth := aspectRatio * halfHeight
-    w := lookat.Subtract(lookfrom).UnitVector()
-    u := upVec.Cross(w).UnitVector()
-    v := u.Cross(w).UnitVector()
-    imageVert := v.Scale(2.0 * halfHeight)
-    imageHoriz := u.Scale(-2.0 * halfWidth)
-    corner := lookfrom.Subtract(v.Scale(halfHeight)).Subtract(u.Scale(-halfWidth)).Add(w)
-    return Camera {
-        Origin: lookfrom,
+    w, right, up := cameraBasis(config.LookAt, config.LookFrom, config.Up, config.Roll)
+    imageVert := up.Scale(-2.0 * halfHeight)
+    imageHoriz := right.Scale(2.0 * halfWidth)
+    corner := config.LookFrom.Add(up.Scale(halfHeight)).Subtract(right.Scale(halfWidth)).Add(w)
+    
+    // Shifting the lens slides the image plane without rotating the camera
+    corner = corner.Add(right.Scale(config.ShiftX * 2.0 * halfWidth)).Add(up.Scale(config.ShiftY * 2.0 * halfHeight))
+    
+    focusDistance := config.FocusDistance
+    if (focusDistance <= 0.0) {
+        focusDistance = float32(config.LookAt.Subtract(config.LookFrom).Length())
+    }
+    
+    return PerspectiveCamera {
+        Origin: config.LookFrom,
         ImagePlaneHorizontal: imageHoriz,
         ImagePlaneVertical: imageVert,
-        UpperLeftCorner: corner }       
+        UpperLeftCorner: corner,
+        LensRadius: lensRadius(config, aspectRatio),
+        FocusDistance: focusDistance,
+        Right: right,
+        Up: up,
+        MotionBlur: config.ShutterSpeed > 0.0 }       
+}
+
+// createOrthographicCamera creates a camera with parallel rays covering OrthographicHeight world units vertically
+func createOrthographicCamera(config cameraConfig, aspectRatio float32) OrthographicCamera {
+    height := config.OrthographicHeight
+    if (height <= 0.0) {
+        distance := float32(config.LookAt.Subtract(config.LookFrom).Length())
+        height = 2.0 * distance * float32(math.Tan(float64(ConvertDegreesToRadians(verticalFov(config, aspectRatio)) / 2.0)))
+    }
+    
+    w, right, up := cameraBasis(config.LookAt, config.LookFrom, config.Up, config.Roll)
+    halfHeight := height / 2.0
+    halfWidth := aspectRatio * halfHeight
+    corner := config.LookFrom.Add(up.Scale(halfHeight)).Subtract(right.Scale(halfWidth))
+    corner = corner.Add(right.Scale(config.ShiftX * 2.0 * halfWidth)).Add(up.Scale(config.ShiftY * 2.0 * halfHeight))
+    return OrthographicCamera {
+        Direction: w,
+        ImagePlaneHorizontal: right.Scale(2.0 * halfWidth),
+        ImagePlaneVertical: up.Scale(-2.0 * halfHeight),
+        UpperLeftCorner: corner,
+        MotionBlur: config.ShutterSpeed > 0.0 }
+}
+
+// createCamera creates the camera described by config for an image with the given aspect ratio
+func createCamera(config cameraConfig, aspectRatio float32) Camera {
+    switch config.Type {
+        case "", PerspectiveCameraType:
+            return createPerspectiveCamera(config, aspectRatio)
+        case OrthographicCameraType:
+            return createOrthographicCamera(config, aspectRatio)
+        case FisheyeCameraType:
+            forward, right, up := cameraBasis(config.LookAt, config.LookFrom, config.Up, config.Roll)
+            mapping := config.FisheyeMapping
+            if (mapping == "") {
+                mapping = EquidistantFisheyeMapping
+            } else if (mapping != EquidistantFisheyeMapping && mapping != EquisolidFisheyeMapping) {
+                log.Fatalf("Unknown fisheye mapping %q", mapping)
+            }
+            return FisheyeCamera {
+                Origin: config.LookFrom,
+                Forward: forward,
+                Right: right,
+                Up: up,
+                Fov: config.Fov,
+                Mapping: mapping,
+                AspectRatio: aspectRatio,
+                ShiftX: config.ShiftX,
+                ShiftY: config.ShiftY,
+                MotionBlur: config.ShutterSpeed > 0.0 }
+        case EquirectangularCameraType:
+            forward, right, up := cameraBasis(config.LookAt, config.LookFrom, config.Up, config.Roll)
+            return EquirectangularCamera {
+                Origin: config.LookFrom,
+                Forward: forward,
+                Right: right,
+                Up: up,
+                MotionBlur: config.ShutterSpeed > 0.0 }
+    }
+
+    log.Fatalf("Unknown camera type %q", config.Type)
+    return nil
+}
+
+// GetRay returns the ray from a point on the lens through the point on the focus plane seen at u, v
+func (c PerspectiveCamera) GetRay(u, v float32, s Sampler) (Ray, bool) {
+    time := shutterTime(c.MotionBlur, s)
+    direction := c.UpperLeftCorner.Add(c.ImagePlaneHorizontal.Scale(u)).Add(c.ImagePlaneVertical.Scale(v)).Subtract(c.Origin)
+    
+    if (c.LensRadius <= 0.0) {
+        return Ray {
+            Origin: c.Origin,
+            Direction: direction.UnitVector(),
+            Time: time }, true
+    }
+    
+    // The image plane is one unit in front of the camera so scaling by the focus distance lands on the focus plane
+    focusPoint := c.Origin.Add(direction.Scale(c.FocusDistance))
+    lensX, lensY := sampleUnitDisk(s)
+    origin := c.Origin.Add(c.Right.Scale(lensX * c.LensRadius)).Add(c.Up.Scale(lensY * c.LensRadius))
+    return Ray {
+        Origin: origin,
+        Direction: focusPoint.Subtract(origin).UnitVector(),
+        Time: time }, true
+}
+
+// GetRay returns the ray starting on the image rectangle going in the view direction
+func (c OrthographicCamera) GetRay(u, v float32, s Sampler) (Ray, bool) {
+    return Ray {
+        Origin: c.UpperLeftCorner.Add(c.ImagePlaneHorizontal.Scale(u)).Add(c.ImagePlaneVertical.Scale(v)),
+        Direction: c.Direction,
+        Time: shutterTime(c.MotionBlur, s) }, true
+}
+
+// GetRay returns the ray for a point inside the image circle, the circle fills the image height
+func (c FisheyeCamera) GetRay(u, v float32, s Sampler) (Ray, bool) {
+    x := (2.0 * u - 1.0 - 2.0 * c.ShiftX) * c.AspectRatio
+    y := 1.0 - 2.0 * v - 2.0 * c.ShiftY
+    radius := math.Sqrt(float64(x * x + y * y))
+    if (radius > 1.0) {
+        return Ray{}, false
+    }
+
+    maxTheta := float64(ConvertDegreesToRadians(c.Fov)) / 2.0
+    var theta float64
+    if (c.Mapping == EquisolidFisheyeMapping) {
+        theta = 2.0 * math.Asin(radius * math.Sin(maxTheta / 2.0))
+    } else {
+        theta = radius * maxTheta
+    }
+
+    phi := math.Atan2(float64(y), float64(x))
+    sinTheta := float32(math.Sin(theta))
+    direction := c.Right.Scale(sinTheta * float32(math.Cos(phi))).
+        Add(c.Up.Scale(sinTheta * float32(math.Sin(phi)))).
+        Add(c.Forward.Scale(float32(math.Cos(theta))))
+
+    return Ray {
+        Origin: c.Origin,
+        Direction: direction.UnitVector(),
+        Time: shutterTime(c.MotionBlur, s) }, true
+}
+
+// GetRay returns the ray for the longitude and latitude at u, v, the center of the image looks forward
+func (c EquirectangularCamera) GetRay(u, v float32, s Sampler) (Ray, bool) {
+    phi := (float64(u) - 0.5) * 2.0 * math.Pi
+    theta := (0.5 - float64(v)) * math.Pi
+    cosTheta := float32(math.Cos(theta))
+
+    direction := c.Right.Scale(cosTheta * float32(math.Sin(phi))).
+        Add(c.Up.Scale(float32(math.Sin(theta)))).
+        Add(c.Forward.Scale(cosTheta * float32(math.Cos(phi))))
+
+    return Ray {
+        Origin: c.Origin,
+        Direction: direction.UnitVector(),
+        Time: shutterTime(c.MotionBlur, s) }, true
 }
 
 // ExportCamera will export the current global camera
@@ -104,16 +414,26 @@ func ImportCamera(filename string) {
         checkError(err)   
     }
 
-    err = json.Unmarshal(contents, &cameraSettings)
+    ImportCameraJSON(contents)
+}
+
+// ImportCameraJSON will import the contents of a camera json file into the global camera
+func ImportCameraJSON(contents []byte) {
+    err := json.Unmarshal(contents, &cameraSettings)
     checkError(err)
     
-    GlobalCamera = CreateCameraFromPos(
-        cameraSettings.LookAt, 
-        cameraSettings.LookFrom,
-        Vector3 {
-            X: 0.0,
-            Y: 1.0,
-            Z: 0.0 },
-       cameraSettings.Fov,
-       float32(Settings.WidthInPixels) / float32(Settings.HeightInPixels))
+    if (cameraSettings.Up == Vector3{}) {
+        cameraSettings.Up = defaultUpVector
+    }
+    
+    setGlobalCamera(cameraSettings)
+}
+
+// setGlobalCamera makes config the current camera settings and creates the global camera and exposure from it
+func setGlobalCamera(config cameraConfig) {
+    cameraSettings = config
+    GlobalCamera = createCamera(
+        cameraSettings,
+        float32(Settings.WidthInPixels) / float32(Settings.HeightInPixels))
+    GlobalExposure = createExposure(cameraSettings)
 }
\ No newline at end of file
@@ -0,0 +1,178 @@
+package raytracer
+
+import
+(
+    "bufio"
+    "compress/gzip"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+)
+
+// checkpointMagic starts every checkpoint file
+const checkpointMagic = "GTCKPT"
+
+// checkpointVersion is bumped whenever the checkpoint layout changes
+const checkpointVersion = 1
+
+// checkpointHeader is written at the start of a checkpoint, ahead of the film's pixel sums
+type checkpointHeader struct {
+    Version uint32
+
+    // InputHash is RenderInputHash of the render the checkpoint was taken from
+    InputHash [sha256.Size]byte
+
+    Width, Height int32
+
+    // SamplesPerPixel is the number of samples already in the film, the next pass starts at this sample index.
+    // Sample values only depend on the seed, pixel and sample index so this and the seed are the whole sampler state
+    SamplesPerPixel uint32
+    Seed uint64
+}
+
+// RenderInputHash returns a hash of everything that decides the rendered image: the config, the scene objects and the camera
+func RenderInputHash() [sha256.Size]byte {
+    hash := sha256.New()
+    for _, v := range []interface{} { Settings, cameraSettings } {
+        b, err := json.Marshal(v)
+        checkError(err)
+
+        hash.Write(b)
+        hash.Write([]byte{ 0 })
+    }
+
+    // Objects are printed with their types, json would not tell a Lambertian from a Metal with the same fields
+    names := make([]string, 0, len(Scene.Scene.collisionList))
+    for name := range Scene.Scene.collisionList {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        fmt.Fprintf(hash, "%q %#v\x00", name, Scene.Scene.collisionList[name])
+    }
+
+    var sum [sha256.Size]byte
+    copy(sum[:], hash.Sum(nil))
+    return sum
+}
+
+// SaveCheckpoint writes the renderer's film and sample count to filename so the render can be resumed later.
+// The checkpoint is written to a temporary file first and renamed over filename so a crash never leaves a half written checkpoint
+func (r *Renderer) SaveCheckpoint(filename string) error {
+    if (r.partialPass) {
+        return errors.New("Cannot checkpoint a render that was stopped part way through a pass")
+    }
+
+    tempFilename := filename + ".tmp"
+    checkpointFile, err := os.Create(tempFilename)
+    if (err != nil) {
+        return err
+    }
+
+    err = r.writeCheckpoint(checkpointFile)
+    closeErr := checkpointFile.Close()
+    if (err == nil) {
+        err = closeErr
+    }
+    if (err != nil) {
+        os.Remove(tempFilename)
+        return err
+    }
+
+    return os.Rename(tempFilename, filename)
+}
+
+// writeCheckpoint writes the compressed checkpoint to file
+func (r *Renderer) writeCheckpoint(file *os.File) error {
+    buffered := bufio.NewWriter(file)
+    compressed, err := gzip.NewWriterLevel(buffered, gzip.BestSpeed)
+    if (err != nil) {
+        return err
+    }
+
+    header := checkpointHeader {
+        Version: checkpointVersion,
+        InputHash: RenderInputHash(),
+        Width: int32(r.Film.Width),
+        Height: int32(r.Film.Height),
+        SamplesPerPixel: r.SamplesPerPixel,
+        Seed: Settings.Seed }
+
+    _, err = compressed.Write([]byte(checkpointMagic))
+    if (err != nil) {
+        return err
+    }
+
+    err = binary.Write(compressed, binary.LittleEndian, header)
+    if (err != nil) {
+        return err
+    }
+
+    err = binary.Write(compressed, binary.LittleEndian, r.Film.pixels)
+    if (err != nil) {
+        return err
+    }
+
+    err = compressed.Close()
+    if (err != nil) {
+        return err
+    }
+
+    return buffered.Flush()
+}
+
+// LoadCheckpoint replaces the renderer's film and sample count with those saved in filename.
+// It returns an error without changing the renderer if the checkpoint was taken with a different config, scene or camera
+func (r *Renderer) LoadCheckpoint(filename string) error {
+    checkpointFile, err := os.Open(filename)
+    if (err != nil) {
+        return err
+    }
+    defer checkpointFile.Close()
+
+    compressed, err := gzip.NewReader(bufio.NewReader(checkpointFile))
+    if (err != nil) {
+        return fmt.Errorf("%v is not a checkpoint: %v", filename, err)
+    }
+    defer compressed.Close()
+
+    magic := make([]byte, len(checkpointMagic))
+    _, err = io.ReadFull(compressed, magic)
+    if (err != nil || string(magic) != checkpointMagic) {
+        return fmt.Errorf("%v is not a checkpoint", filename)
+    }
+
+    var header checkpointHeader
+    err = binary.Read(compressed, binary.LittleEndian, &header)
+    if (err != nil) {
+        return fmt.Errorf("Failed to read checkpoint %v: %v", filename, err)
+    }
+
+    if (header.Version != checkpointVersion) {
+        return fmt.Errorf("Checkpoint %v has version %v, expected %v", filename, header.Version, checkpointVersion)
+    }
+
+    if (header.InputHash != RenderInputHash()) {
+        return errors.New("Checkpoint " + filename + " was rendered with a different config, scene or camera")
+    }
+
+    if (int(header.Width) != r.Film.Width || int(header.Height) != r.Film.Height || header.Seed != Settings.Seed) {
+        return fmt.Errorf("Checkpoint %v does not match the film size or seed", filename)
+    }
+
+    pixels := make([]filmPixel, len(r.Film.pixels))
+    err = binary.Read(compressed, binary.LittleEndian, pixels)
+    if (err != nil) {
+        return fmt.Errorf("Failed to read checkpoint %v: %v", filename, err)
+    }
+
+    r.Film.pixels = pixels
+    r.SamplesPerPixel = header.SamplesPerPixel
+    return nil
+}
@@ -1,14 +1,9 @@
 package raytracer
 
-import
-(
-    "image/color"
-)
-
 // CollidableObject is an interface for objects that want to be able to collide with rays
 type CollidableObject interface {
     TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionRecord)
-    GetColor(r Ray, i IntersectionRecord, bounces uint32) color.RGBA
+    BoundingBox() BoundingBox
 }
 
 // IntersectionRecord is an object that contains data about where a ray hit an object
@@ -16,5 +11,7 @@ type IntersectionRecord struct {
     T float32
     Point Vector3
     Normal Vector3
+    U, V float32
     Object CollidableObject
+    Material Material
 }
\ No newline at end of file
@@ -3,6 +3,19 @@ package raytracer
 // CollisionList is a struct used to abstract the collision test loop
 type CollisionList struct {
     collisionList map[string]CollidableObject
+
+    // objects holds the same objects in the order they were added so collision tests always run in the same order
+    objects []CollidableObject
+    names []string
+
+    // hierarchy is the bounding volume hierarchy over hierarchyObjects, it is nil until buildHierarchy is called and after any object is added.
+    // hierarchyIndices holds the index in objects of each of the reordered hierarchyObjects
+    hierarchy *bvhNode
+    hierarchyObjects []CollidableObject
+    hierarchyIndices []int
+
+    // lights caches the emissive spheres while the hierarchy is built
+    lights []Sphere
 }
 
 // AddObject adds a collidableobject to the collision map
@@ -10,24 +23,103 @@ func (c *CollisionList) addObject(name string, obj CollidableObject) {
     if (nil == c.collisionList) {
         c.collisionList = make(map[string]CollidableObject)
     }
-    
+
+    if _, exists := c.collisionList[name]; exists {
+        for i, existing := range c.names {
+            if (existing == name) {
+                c.objects[i] = obj
+            }
+        }
+    } else {
+        c.names = append(c.names, name)
+        c.objects = append(c.objects, obj)
+    }
+
     c.collisionList[name] = obj
+    c.hierarchy = nil
+    c.hierarchyObjects = nil
+    c.hierarchyIndices = nil
+    c.lights = nil
+}
+
+// buildHierarchy builds the bounding volume hierarchy used to speed up collision tests
+func (c *CollisionList) buildHierarchy() {
+    if (len(c.objects) == 0) {
+        return
+    }
+
+    c.lights = c.emissiveSpheres()
+    c.hierarchyObjects = append([]CollidableObject{}, c.objects...)
+    c.hierarchyIndices = make([]int, len(c.objects))
+    for i := range c.hierarchyIndices {
+        c.hierarchyIndices[i] = i
+    }
+    c.hierarchy = buildHierarchy(c.hierarchyObjects, c.hierarchyIndices, 0)
 }
 
 // TestCollision loops through all the objects testing if the ray is colliding with any and returns the nearest object
 func (c CollisionList) testCollision(r Ray, tMin, tMax float32) (bool, IntersectionRecord) {
+    return c.testCollisionWithCounters(r, tMin, tMax, nil)
+}
+
+// testCollisionWithCounters is testCollision that also counts the bounding box and object tests performed in counters
+func (c CollisionList) testCollisionWithCounters(r Ray, tMin, tMax float32, counters *collisionCounters) (bool, IntersectionRecord) {
+    if (c.hierarchy != nil) {
+        return traverseHierarchy(c.hierarchy, c.hierarchyObjects, c.hierarchyIndices, r, tMin, tMax, counters)
+    }
+
     collisionDetected := false
     var closestHitRecord IntersectionRecord
     closestT := tMax
-    
-    for _, obj := range c.collisionList {
-        isColliding, hitRecord := obj.TestIntersection(r, tMin, closestT) 
+
+    for i, obj := range c.objects {
+        isColliding, hitRecord := obj.TestIntersection(r, tMin, closestT)
+        if (counters != nil) {
+            counters.count(i, isColliding)
+        }
+
         if (isColliding) {
             collisionDetected = true
             closestHitRecord = hitRecord
             closestT = hitRecord.T
         }
     }
-    
+
     return collisionDetected, closestHitRecord
+}
+
+// collisionCounters count the tests made by a collision test
+type collisionCounters struct {
+    // Tests is the number of bounding box and object tests
+    Tests uint32
+
+    // objectTests and objectHits count the intersection tests of each object and how many of them hit, by the object's index in the list.  nil when objects are not counted
+    objectTests, objectHits []uint64
+}
+
+// count counts an intersection test of the object at index
+func (c *collisionCounters) count(index int, hit bool) {
+    c.Tests++
+    if (c.objectTests != nil) {
+        c.objectTests[index]++
+        if (hit) {
+            c.objectHits[index]++
+        }
+    }
+}
+
+// emissiveSpheres returns every sphere with an emissive material, these are the lights used by direct lighting
+func (c CollisionList) emissiveSpheres() []Sphere {
+    if (c.hierarchy != nil) {
+        return c.lights
+    }
+
+    var lights []Sphere
+    for _, obj := range c.objects {
+        if sphere, ok := obj.(Sphere); ok && sphere.Properties != nil && sphere.Properties.IsEmissive() {
+            lights = append(lights, sphere)
+        }
+    }
+
+    return lights
 }
\ No newline at end of file
@@ -0,0 +1,104 @@
+package raytracer
+
+import
+(
+    "image"
+    "image/color"
+    "log"
+    "math"
+)
+
+// CropWindow is a region of the image to render.  A window with no area renders the whole image
+type CropWindow struct {
+    // MinX, MinY, MaxX and MaxY bound the window, the max edges are exclusive
+    MinX, MinY, MaxX, MaxY float32
+
+    // Normalized is true when the bounds are fractions of the image size in [0, 1] rather than pixels
+    Normalized bool
+}
+
+const (
+    // CroppedOutput writes only the pixels inside the crop window
+    CroppedOutput = "cropped"
+
+    // FullFrameOutput writes the whole image with the pixels outside of the crop window left black
+    FullFrameOutput = "full"
+)
+
+// IsEmpty returns true if the window has no area and so does not crop anything
+func (c CropWindow) IsEmpty() bool {
+    return c.MaxX <= c.MinX || c.MaxY <= c.MinY
+}
+
+// Bounds returns the pixels covered by the window in an image of the given size.
+// A normalized window covers every pixel it touches, so windows that share an edge do not leave a gap between them
+func (c CropWindow) Bounds(width, height int) image.Rectangle {
+    full := image.Rect(0, 0, width, height)
+    if (c.IsEmpty()) {
+        return full
+    }
+
+    var bounds image.Rectangle
+    if (c.Normalized) {
+        bounds = image.Rect(
+            int(math.Floor(float64(c.MinX) * float64(width))),
+            int(math.Floor(float64(c.MinY) * float64(height))),
+            int(math.Ceil(float64(c.MaxX) * float64(width))),
+            int(math.Ceil(float64(c.MaxY) * float64(height))))
+    } else {
+        bounds = image.Rect(
+            int(math.Floor(float64(c.MinX))),
+            int(math.Floor(float64(c.MinY))),
+            int(math.Ceil(float64(c.MaxX))),
+            int(math.Ceil(float64(c.MaxY))))
+    }
+
+    bounds = bounds.Intersect(full)
+    if (bounds.Empty()) {
+        log.Fatalf("Crop window %+v does not overlap the %v x %v image", c, width, height)
+    }
+
+    return bounds
+}
+
+// sampleBounds returns the pixels that must be sampled to reconstruct region exactly as a full render would.
+// Samples in pixels next to the region reach into it through the filter, so the region is grown by the filter radius.
+// By the same reasoning it is also every pixel that samples taken inside region can reach
+func (f *Film) sampleBounds(region image.Rectangle) image.Rectangle {
+    margin := int(math.Ceil(float64(f.Filter.Radius() - 0.5)))
+    if (margin < 0) {
+        margin = 0
+    }
+
+    return region.Inset(-margin).Intersect(image.Rect(0, 0, f.Width, f.Height))
+}
+
+// RegionImage develops the pixels of region using the global exposure.
+// With full frame output the image is the size of the film and black outside of region, otherwise it only holds region
+func (f *Film) RegionImage(region image.Rectangle, output string) *image.RGBA {
+    var frame *image.RGBA
+    var offset image.Point
+    switch output {
+        case "", CroppedOutput:
+            frame = image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+            offset = region.Min
+        case FullFrameOutput:
+            frame = image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+            black := color.RGBA{ 0, 0, 0, 255 }
+            for y := 0; y < f.Height; y++ {
+                for x := 0; x < f.Width; x++ {
+                    frame.SetRGBA(x, y, black)
+                }
+            }
+        default:
+            log.Fatalf("Unknown crop output %q", output)
+    }
+
+    for y := region.Min.Y; y < region.Max.Y; y++ {
+        for x := region.Min.X; x < region.Max.X; x++ {
+            frame.SetRGBA(x - offset.X, y - offset.Y, ApplyExposure(f.GetPixel(x, y)).AsColor())
+        }
+    }
+
+    return frame
+}
@@ -0,0 +1,319 @@
+package raytracer
+
+import
+(
+    "context"
+    "encoding/gob"
+    "encoding/json"
+    "fmt"
+    "image"
+    "io"
+    "log"
+    "net"
+    "time"
+)
+
+// renderInputs is the config, scene and camera json a worker needs to render exactly what the coordinator would
+type renderInputs struct {
+    Config, Scene, Camera []byte
+}
+
+// tileRequest asks a worker to take samples FirstSample to FirstSample + Samples of every pixel in Tile.
+// A request with Done set tells the worker there is no more work
+type tileRequest struct {
+    Tile image.Rectangle
+    FirstSample, Samples uint32
+    Done bool
+
+    // Statistics asks the worker to collect render statistics for the tile
+    Statistics bool
+}
+
+// tileResult holds the film sums a worker rendered for a tile.  Window is the tile grown by the filter radius, every pixel its samples reach.
+// The sums are sent in the film's fixed point rather than as floats so the assembled image matches a local render bit for bit
+type tileResult struct {
+    Tile, Window image.Rectangle
+    Pixels []filmPixel
+
+    // Counters hold the work the worker did for the tile
+    Counters renderCounters
+}
+
+// CoordinatorOptions control how RenderDistributed hands out work
+type CoordinatorOptions struct {
+    // Network is tcp or unix and Address is the host:port or socket path workers connect to
+    Network, Address string
+
+    // TileSize is the width and height in pixels of the tiles handed to workers.  Defaults to 32
+    TileSize int
+
+    // TileTimeout is how long a worker may take on a tile before it is treated as dead and its tile given to another worker, 0 waits forever
+    TileTimeout time.Duration
+}
+
+// coordinator hands out tiles to connected workers and collects their results
+type coordinator struct {
+    inputs renderInputs
+    film *Film
+    options CoordinatorOptions
+    firstSample, samples uint32
+    statistics bool
+
+    // queue holds the tiles waiting for a worker, tiles from workers that die are put back on it
+    queue chan image.Rectangle
+    results chan tileResult
+
+    // stop is closed when the render is cancelled so workers stop waiting to hand in results
+    stop <-chan struct{}
+}
+
+// RenderDistributed adds samples more samples to every pixel by splitting the region into tiles and handing them to worker processes.
+// Workers connect with RunWorker and may come and go during the render, a tile is given to another worker if its worker dies.
+// If ctx is done before every tile is back an IncompleteRenderError is returned and the film holds the tiles finished so far
+func (r *Renderer) RenderDistributed(ctx context.Context, samples uint32, options CoordinatorOptions) error {
+    if (options.TileSize <= 0) {
+        options.TileSize = 32
+    }
+
+    inputs, err := currentRenderInputs()
+    if (err != nil) {
+        return err
+    }
+
+    bounds := r.Film.sampleBounds(r.Region)
+    tiles := splitTiles(bounds, options.TileSize)
+    c := coordinator {
+        inputs: inputs,
+        film: r.Film,
+        options: options,
+        firstSample: r.SamplesPerPixel,
+        samples: samples,
+        statistics: r.CollectStatistics,
+        queue: make(chan image.Rectangle, len(tiles)),
+        results: make(chan tileResult),
+        stop: ctx.Done() }
+
+    for _, tile := range tiles {
+        c.queue <- tile
+    }
+
+    listener, err := net.Listen(options.Network, options.Address)
+    if (err != nil) {
+        return err
+    }
+    defer listener.Close()
+
+    log.Printf("Waiting for workers on %v %v", options.Network, listener.Addr())
+    go c.acceptWorkers(listener)
+
+    r.progress.begin(r.Progress, len(tiles), uint64(bounds.Dx() * bounds.Dy()) * uint64(samples), 0)
+    defer r.progress.end()
+
+    pixelsRendered := 0
+    for range tiles {
+        var result tileResult
+        select {
+            case result = <-c.results:
+            case <-ctx.Done():
+                r.partialPass = true
+                return &IncompleteRenderError {
+                    Err: ctx.Err(),
+                    SamplesPerPixel: r.SamplesPerPixel,
+                    PixelsRendered: pixelsRendered,
+                    Pixels: bounds.Dx() * bounds.Dy() }
+        }
+
+        tilePixels := result.Tile.Dx() * result.Tile.Dy()
+        pixelsRendered += tilePixels
+        r.Film.addFilm(&Film {
+            Width: r.Film.Width,
+            Height: r.Film.Height,
+            Filter: r.Film.Filter,
+            window: result.Window,
+            pixels: result.Pixels })
+
+        r.progress.tileFinished(uint64(tilePixels) * uint64(samples), result.Counters)
+    }
+
+    // Every tile is finished so nothing can be put back on the queue, closing it tells idle workers to stop
+    close(c.queue)
+    r.SamplesPerPixel += samples
+    return nil
+}
+
+// currentRenderInputs returns the current config, scene and camera as json
+func currentRenderInputs() (renderInputs, error) {
+    var inputs renderInputs
+    var err error
+
+    inputs.Config, err = json.Marshal(Settings)
+    if (err != nil) {
+        return inputs, err
+    }
+
+    inputs.Scene, err = json.Marshal(Scene.Scene.collisionList)
+    if (err != nil) {
+        return inputs, err
+    }
+
+    inputs.Camera, err = json.Marshal(cameraSettings)
+    return inputs, err
+}
+
+// splitTiles splits bounds into tiles of at most size x size pixels in scanline order
+func splitTiles(bounds image.Rectangle, size int) []image.Rectangle {
+    var tiles []image.Rectangle
+    for y := bounds.Min.Y; y < bounds.Max.Y; y += size {
+        for x := bounds.Min.X; x < bounds.Max.X; x += size {
+            tiles = append(tiles, image.Rect(x, y, x + size, y + size).Intersect(bounds))
+        }
+    }
+
+    return tiles
+}
+
+// acceptWorkers serves every worker that connects until the listener is closed
+func (c *coordinator) acceptWorkers(listener net.Listener) {
+    for id := 1; ; id++ {
+        conn, err := listener.Accept()
+        if (err != nil) {
+            return
+        }
+
+        go c.serveWorker(conn, id)
+    }
+}
+
+// serveWorker sends the render inputs to a worker and then hands it tiles one at a time until the queue is closed.
+// If the worker fails or times out its tile goes back on the queue for another worker
+func (c *coordinator) serveWorker(conn net.Conn, id int) {
+    defer conn.Close()
+    worker := fmt.Sprintf("%v (%v)", id, conn.RemoteAddr())
+    log.Printf("Worker %v connected", worker)
+
+    encoder := gob.NewEncoder(conn)
+    decoder := gob.NewDecoder(conn)
+    c.setDeadline(conn)
+    if err := encoder.Encode(c.inputs); err != nil {
+        log.Printf("Worker %v failed: %v", worker, err)
+        return
+    }
+
+    for {
+        var tile image.Rectangle
+        var ok bool
+        select {
+            case tile, ok = <-c.queue:
+            case <-c.stop:
+                return
+        }
+
+        if (false == ok) {
+            encoder.Encode(tileRequest{ Done: true })
+            return
+        }
+
+        c.setDeadline(conn)
+        result, err := c.renderTile(encoder, decoder, tile)
+        if (err != nil) {
+            log.Printf("Worker %v failed, reassigning tile %v: %v", worker, tile, err)
+            c.queue <- tile
+            return
+        }
+
+        select {
+            case c.results <- result:
+            case <-c.stop:
+                return
+        }
+    }
+}
+
+// setDeadline limits how long the next exchange with a worker can take
+func (c *coordinator) setDeadline(conn net.Conn) {
+    if (c.options.TileTimeout > 0) {
+        conn.SetDeadline(time.Now().Add(c.options.TileTimeout))
+    }
+}
+
+// renderTile sends a tile to a worker and waits for its result
+func (c *coordinator) renderTile(encoder *gob.Encoder, decoder *gob.Decoder, tile image.Rectangle) (tileResult, error) {
+    var result tileResult
+    err := encoder.Encode(tileRequest {
+        Tile: tile,
+        FirstSample: c.firstSample,
+        Samples: c.samples,
+        Statistics: c.statistics })
+    if (err != nil) {
+        return result, err
+    }
+
+    err = decoder.Decode(&result)
+    if (err != nil) {
+        return result, err
+    }
+
+    if (result.Tile != tile || result.Window != c.film.sampleBounds(tile) || len(result.Pixels) != result.Window.Dx() * result.Window.Dy()) {
+        return result, fmt.Errorf("Result for tile %v does not match the request", result.Tile)
+    }
+
+    return result, nil
+}
+
+// RunWorker connects to a coordinator, loads the scene it sends and renders tiles for it until it has no more work.
+// It keeps trying to connect for up to connectTimeout so workers can be started before the coordinator
+func RunWorker(network, address string, connectTimeout time.Duration) error {
+    conn, err := net.Dial(network, address)
+    for giveUp := time.Now().Add(connectTimeout); err != nil && time.Now().Before(giveUp); {
+        time.Sleep(time.Second)
+        conn, err = net.Dial(network, address)
+    }
+    if (err != nil) {
+        return err
+    }
+    defer conn.Close()
+
+    encoder := gob.NewEncoder(conn)
+    decoder := gob.NewDecoder(conn)
+
+    var inputs renderInputs
+    err = decoder.Decode(&inputs)
+    if (err != nil) {
+        return err
+    }
+
+    ImportRenderInputs(inputs.Config, inputs.Scene, inputs.Camera)
+
+    integrator := CreateIntegrator(Settings.Integrator)
+    sampler := CreateSampler(Settings.Sampler, Settings.MaxAntialiasRays, Settings.Seed)
+    filter := CreateFilter(Settings.Filter, Settings.FilterRadius)
+    bounds := &Film{ Width: Settings.WidthInPixels, Height: Settings.HeightInPixels, Filter: filter }
+
+    for {
+        var request tileRequest
+        err = decoder.Decode(&request)
+        if (err == io.EOF || (err == nil && request.Done)) {
+            return nil
+        }
+        if (err != nil) {
+            return err
+        }
+
+        window := bounds.sampleBounds(request.Tile)
+        renderer := Renderer {
+            Film: createFilmWindow(Settings.WidthInPixels, Settings.HeightInPixels, filter, window),
+            Integrator: integrator,
+            Sampler: sampler,
+            CollectStatistics: request.Statistics }
+        renderer.renderPixels(context.Background(), request.Tile, request.FirstSample, request.Samples)
+
+        err = encoder.Encode(tileResult {
+            Tile: request.Tile,
+            Window: window,
+            Pixels: renderer.Film.pixels,
+            Counters: renderer.progress.counters })
+        if (err != nil) {
+            return err
+        }
+    }
+}
@@ -0,0 +1,160 @@
+package raytracer
+
+import
+(
+    "math"
+)
+
+// defaultWhiteBalance is the color temperature in kelvin that the sRGB white point corresponds to
+const defaultWhiteBalance = 6500.0
+
+// defaultSensorHeight is the height of a full frame sensor in millimetres
+const defaultSensorHeight = 24.0
+
+// GlobalExposure is multiplied with the radiance of every pixel before it is written, it combines the camera exposure and white balance
+var GlobalExposure = NewVector3(1.0, 1.0, 1.0)
+
+// ApplyExposure converts the radiance arriving at a pixel to the value written to the image
+func ApplyExposure(radiance Vector3) Vector3 {
+    return radiance.Multiply(GlobalExposure)
+}
+
+// hasPhysicalExposure returns true when the camera describes a physical exposure with ISO, shutter speed and f-number
+func (c cameraConfig) hasPhysicalExposure() bool {
+    return c.ISO > 0.0 && c.ShutterSpeed > 0.0 && c.FNumber > 0.0
+}
+
+// exposureScale returns the factor that maps radiance in cd/m^2 to [0, 1] for the camera's ISO, shutter speed and f-number.
+// It uses the saturation based sensitivity, so a luminance of 1.2 * 2^EV100 just reaches white
+func exposureScale(config cameraConfig) float32 {
+    if (false == config.hasPhysicalExposure()) {
+        return 1.0
+    }
+
+    ev100 := math.Log2(float64(config.FNumber * config.FNumber) / float64(config.ShutterSpeed) * 100.0 / float64(config.ISO))
+    return float32(1.0 / (1.2 * math.Pow(2.0, ev100)))
+}
+
+// createExposure returns the per channel exposure for the camera settings
+func createExposure(config cameraConfig) Vector3 {
+    return whiteBalanceGains(config.WhiteBalance).Scale(exposureScale(config))
+}
+
+// whiteBalanceGains returns the channel gains that make light of the given color temperature appear white
+func whiteBalanceGains(kelvin float32) Vector3 {
+    if (kelvin <= 0.0 || kelvin == defaultWhiteBalance) {
+        return NewVector3(1.0, 1.0, 1.0)
+    }
+
+    reference := blackbodyColor(defaultWhiteBalance)
+    illuminant := blackbodyColor(kelvin)
+    gains := reference.Divide(illuminant)
+
+    // Keep the green channel fixed so changing the white balance does not change the brightness much
+    return gains.Scale(1.0 / gains.Y)
+}
+
+// blackbodyColor returns the linear sRGB color of a black body at the given temperature, scaled so green is 1.
+// The chromaticity follows Kim et al.'s cubic fit of the Planckian locus, valid from 1667K to 25000K
+func blackbodyColor(kelvin float32) Vector3 {
+    t := math.Max(1667.0, math.Min(25000.0, float64(kelvin)))
+    t2 := t * t
+    t3 := t2 * t
+
+    var x float64
+    if (t <= 4000.0) {
+        x = -0.2661239e9 / t3 - 0.2343589e6 / t2 + 0.8776956e3 / t + 0.179910
+    } else {
+        x = -3.0258469e9 / t3 + 2.1070379e6 / t2 + 0.2226347e3 / t + 0.240390
+    }
+
+    x2 := x * x
+    x3 := x2 * x
+
+    var y float64
+    if (t <= 2222.0) {
+        y = -1.1063814 * x3 - 1.34811020 * x2 + 2.18555832 * x - 0.20219683
+    } else if (t <= 4000.0) {
+        y = -0.9549476 * x3 - 1.37418593 * x2 + 2.09137015 * x - 0.16748867
+    } else {
+        y = 3.0817580 * x3 - 5.87338670 * x2 + 3.75112997 * x - 0.37001483
+    }
+
+    // Convert xyY with Y = 1 to XYZ and then to linear sRGB
+    bigX := x / y
+    bigZ := (1.0 - x - y) / y
+    r := 3.2404542 * bigX - 1.5371385 - 0.4985314 * bigZ
+    g := -0.9692660 * bigX + 1.8760108 + 0.0415560 * bigZ
+    b := 0.0556434 * bigX - 0.2040259 + 1.0572252 * bigZ
+
+    return NewVector3(
+        float32(math.Max(r, 1e-4) / g),
+        1.0,
+        float32(math.Max(b, 1e-4) / g))
+}
+
+// lensRadius returns the radius of the aperture in world units for a physical camera, or 0 for a pinhole
+func lensRadius(config cameraConfig, aspectRatio float32) float32 {
+    if (config.FNumber <= 0.0) {
+        return 0.0
+    }
+
+    sensorHeight := defaultFloat(config.SensorHeight, defaultSensorHeight)
+    metersPerUnit := defaultFloat(config.MetersPerUnit, 1.0)
+    halfAngle := float64(ConvertDegreesToRadians(verticalFov(config, aspectRatio))) / 2.0
+
+    // The focal length in millimetres that gives the field of view on this sensor
+    focalLength := float64(sensorHeight) / 2.0 / math.Tan(halfAngle)
+    apertureDiameter := focalLength / float64(config.FNumber) / 1000.0
+    return float32(apertureDiameter / 2.0 / float64(metersPerUnit))
+}
+
+// SetCameraAperture gives the global camera a lens aperture diameter in scene units with the plane focusDistance away in focus, it picks the FNumber that gives that aperture at the camera's field of view.
+// A diameter of 0 makes the camera a pinhole again
+func SetCameraAperture(diameter, focusDistance float32) {
+    config := cameraSettings
+    config.FocusDistance = focusDistance
+    config.FNumber = 0.0
+
+    if (diameter > 0.0) {
+        aspectRatio := float32(Settings.WidthInPixels) / float32(Settings.HeightInPixels)
+        sensorHeight := defaultFloat(config.SensorHeight, defaultSensorHeight)
+        metersPerUnit := defaultFloat(config.MetersPerUnit, 1.0)
+        halfAngle := float64(ConvertDegreesToRadians(verticalFov(config, aspectRatio))) / 2.0
+
+        focalLength := float64(sensorHeight) / 2.0 / math.Tan(halfAngle)
+        config.FNumber = float32(focalLength / 1000.0 / (float64(diameter) * float64(metersPerUnit)))
+    }
+
+    setGlobalCamera(config)
+}
+
+// shutterTime returns when during the exposure a camera ray is taken, in [0, 1), or 0 if the camera has no shutter
+func shutterTime(motionBlur bool, s Sampler) float32 {
+    if (false == motionBlur) {
+        return 0.0
+    }
+
+    return s.Get1D()
+}
+
+// sampleUnitDisk maps two sample values to a point in the unit disk using the concentric mapping
+func sampleUnitDisk(s Sampler) (float32, float32) {
+    u, v := s.Get2D()
+    a := 2.0 * float64(u) - 1.0
+    b := 2.0 * float64(v) - 1.0
+    if (a == 0.0 && b == 0.0) {
+        return 0.0, 0.0
+    }
+
+    var radius, theta float64
+    if (math.Abs(a) > math.Abs(b)) {
+        radius = a
+        theta = (math.Pi / 4.0) * (b / a)
+    } else {
+        radius = b
+        theta = (math.Pi / 2.0) - (math.Pi / 4.0) * (a / b)
+    }
+
+    return float32(radius * math.Cos(theta)), float32(radius * math.Sin(theta))
+}
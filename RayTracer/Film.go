@@ -0,0 +1,151 @@
+package raytracer
+
+import
+(
+    "image"
+    "math"
+    "sync/atomic"
+)
+
+// Film accumulates filtered samples into pixels.
+// A sample is splatted into every pixel its filter reaches, so samples from different goroutines can land on the same pixel.
+// Sums are kept in fixed point and added atomically, integer addition does not depend on order so the result is the same however the work was scheduled
+type Film struct {
+    // Width and Height are the size of the whole image
+    Width, Height int
+    Filter Filter
+
+    // window is the part of the image the film holds pixels for, samples reaching outside of it are dropped
+    window image.Rectangle
+    pixels []filmPixel
+}
+
+// filmPixel is the weighted sum of the samples that reached a pixel in fixed point
+type filmPixel struct {
+    R, G, B int64
+    Weight int64
+}
+
+// filmFixedPointScale is the fixed point scale of film sums, it keeps about 7 decimal digits below 1 and sums up to about 5e11
+const filmFixedPointScale = 1 << 24
+
+// toFilmFixedPoint converts a value to the film's fixed point representation
+func toFilmFixedPoint(v float32) int64 {
+    return int64(math.Round(float64(v) * filmFixedPointScale))
+}
+
+// fromFilmFixedPoint converts a film fixed point value back to a float
+func fromFilmFixedPoint(v int64) float64 {
+    return float64(v) / filmFixedPointScale
+}
+
+// CreateFilm creates an empty film of the given size that reconstructs pixels with filter
+func CreateFilm(width, height int, filter Filter) *Film {
+    return createFilmWindow(width, height, filter, image.Rect(0, 0, width, height))
+}
+
+// createFilmWindow creates an empty film for an image of the given size that only holds the pixels in window
+func createFilmWindow(width, height int, filter Filter, window image.Rectangle) *Film {
+    return &Film {
+        Width: width,
+        Height: height,
+        Filter: filter,
+        window: window,
+        pixels: make([]filmPixel, window.Dx() * window.Dy()) }
+}
+
+// pixel returns the sums of the pixel at x, y which must be inside the film's window
+func (f *Film) pixel(x, y int) *filmPixel {
+    return &f.pixels[(y - f.window.Min.Y) * f.window.Dx() + (x - f.window.Min.X)]
+}
+
+// CreateFilmFromSettings creates a film sized and filtered by the current config
+func CreateFilmFromSettings() *Film {
+    return CreateFilm(
+        Settings.WidthInPixels,
+        Settings.HeightInPixels,
+        CreateFilter(Settings.Filter, Settings.FilterRadius))
+}
+
+// AddSample splats the radiance of a sample taken at film position x, y into the pixels the filter reaches.
+// Pixel px, py covers [px, px + 1) x [py, py + 1) and its center is at px + 0.5, py + 0.5
+func (f *Film) AddSample(x, y float32, radiance Vector3) {
+    radius := f.Filter.Radius()
+    minX := int(math.Ceil(float64(x - 0.5 - radius)))
+    maxX := int(math.Floor(float64(x - 0.5 + radius)))
+    minY := int(math.Ceil(float64(y - 0.5 - radius)))
+    maxY := int(math.Floor(float64(y - 0.5 + radius)))
+
+    if (minX < f.window.Min.X) {
+        minX = f.window.Min.X
+    }
+    if (minY < f.window.Min.Y) {
+        minY = f.window.Min.Y
+    }
+    if (maxX >= f.window.Max.X) {
+        maxX = f.window.Max.X - 1
+    }
+    if (maxY >= f.window.Max.Y) {
+        maxY = f.window.Max.Y - 1
+    }
+
+    for py := minY; py <= maxY; py++ {
+        dy := y - (float32(py) + 0.5)
+
+        for px := minX; px <= maxX; px++ {
+            weight := f.Filter.Evaluate(x - (float32(px) + 0.5), dy)
+            if (weight == 0.0) {
+                continue
+            }
+
+            pixel := f.pixel(px, py)
+            atomic.AddInt64(&pixel.R, toFilmFixedPoint(radiance.X * weight))
+            atomic.AddInt64(&pixel.G, toFilmFixedPoint(radiance.Y * weight))
+            atomic.AddInt64(&pixel.B, toFilmFixedPoint(radiance.Z * weight))
+            atomic.AddInt64(&pixel.Weight, toFilmFixedPoint(weight))
+        }
+    }
+}
+
+// GetPixel returns the reconstructed radiance of the pixel at x, y
+func (f *Film) GetPixel(x, y int) Vector3 {
+    pixel := f.pixel(x, y)
+    weight := fromFilmFixedPoint(atomic.LoadInt64(&pixel.Weight))
+    if (weight <= 0.0) {
+        return Vector3{}
+    }
+
+    // Filters with negative lobes can push a pixel below zero next to a bright edge
+    return NewVector3(
+        float32(math.Max(0.0, fromFilmFixedPoint(atomic.LoadInt64(&pixel.R)) / weight)),
+        float32(math.Max(0.0, fromFilmFixedPoint(atomic.LoadInt64(&pixel.G)) / weight)),
+        float32(math.Max(0.0, fromFilmFixedPoint(atomic.LoadInt64(&pixel.B)) / weight)))
+}
+
+// addFilm adds the sums of every pixel of other to the same pixels of this film.
+// Sums are exact, so a film assembled from films that each sampled different pixels is the same as one that sampled them all
+func (f *Film) addFilm(other *Film) {
+    window := other.window.Intersect(f.window)
+    for y := window.Min.Y; y < window.Max.Y; y++ {
+        for x := window.Min.X; x < window.Max.X; x++ {
+            source := other.pixel(x, y)
+            pixel := f.pixel(x, y)
+            atomic.AddInt64(&pixel.R, source.R)
+            atomic.AddInt64(&pixel.G, source.G)
+            atomic.AddInt64(&pixel.B, source.B)
+            atomic.AddInt64(&pixel.Weight, source.Weight)
+        }
+    }
+}
+
+// Image develops the film into an image using the global exposure
+func (f *Film) Image() *image.RGBA {
+    frame := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+    for y := 0; y < f.Height; y++ {
+        for x := 0; x < f.Width; x++ {
+            frame.SetRGBA(x, y, ApplyExposure(f.GetPixel(x, y)).AsColor())
+        }
+    }
+
+    return frame
+}
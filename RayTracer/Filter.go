@@ -0,0 +1,181 @@
+package raytracer
+
+import
+(
+    "log"
+    "math"
+)
+
+// Filter is an interface for pixel reconstruction filters that weigh how much a sample contributes to nearby pixels
+type Filter interface {
+    // Radius returns how far in pixels from a sample the filter reaches
+    Radius() float32
+
+    // Evaluate returns the weight of a sample at offset x, y from a pixel center
+    Evaluate(x, y float32) float32
+}
+
+const (
+    // BoxFilterType weighs every sample inside the radius equally
+    BoxFilterType = "box"
+
+    // TentFilterType falls off linearly from the pixel center
+    TentFilterType = "tent"
+
+    // GaussianFilterType falls off smoothly, it is soft but never rings
+    GaussianFilterType = "gaussian"
+
+    // MitchellFilterType is the Mitchell-Netravali cubic, a balance of sharpness and ringing
+    MitchellFilterType = "mitchell"
+
+    // LanczosFilterType is a windowed sinc, the sharpest filter but it can ring around edges
+    LanczosFilterType = "lanczos"
+)
+
+// BoxFilter gives every sample within Size of the pixel center the same weight
+type BoxFilter struct {
+    Size float32
+}
+
+// TentFilter weighs samples by how close they are to the pixel center, reaching 0 at Size
+type TentFilter struct {
+    Size float32
+}
+
+// GaussianFilter weighs samples by a Gaussian with standard deviation Sigma, shifted to reach 0 at Size
+type GaussianFilter struct {
+    Size, Sigma float32
+}
+
+// MitchellFilter is the Mitchell-Netravali cubic with parameters B and C stretched over Size
+type MitchellFilter struct {
+    Size, B, C float32
+}
+
+// LanczosFilter is a sinc windowed by a wider sinc, Tau is the number of lobes in Size
+type LanczosFilter struct {
+    Size, Tau float32
+}
+
+// CreateFilter creates the filter named by filterType.  A radius of 0 uses the filter's usual radius
+func CreateFilter(filterType string, radius float32) Filter {
+    switch filterType {
+        case "", BoxFilterType:
+            return BoxFilter{ Size: defaultFloat(radius, 0.5) }
+        case TentFilterType:
+            return TentFilter{ Size: defaultFloat(radius, 1.0) }
+        case GaussianFilterType:
+            size := defaultFloat(radius, 1.5)
+            return GaussianFilter{ Size: size, Sigma: size / 3.0 }
+        case MitchellFilterType:
+            return MitchellFilter{ Size: defaultFloat(radius, 2.0), B: 1.0 / 3.0, C: 1.0 / 3.0 }
+        case LanczosFilterType:
+            return LanczosFilter{ Size: defaultFloat(radius, 3.0), Tau: 3.0 }
+    }
+
+    log.Fatalf("Unknown filter type %q", filterType)
+    return nil
+}
+
+// Radius returns the half width of the box
+func (f BoxFilter) Radius() float32 {
+    return f.Size
+}
+
+// Evaluate returns 1 inside the box.  The box is half open so a sample on the border between pixels only counts once
+func (f BoxFilter) Evaluate(x, y float32) float32 {
+    if (x >= -f.Size && x < f.Size && y >= -f.Size && y < f.Size) {
+        return 1.0
+    }
+
+    return 0.0
+}
+
+// Radius returns where the tent reaches 0
+func (f TentFilter) Radius() float32 {
+    return f.Size
+}
+
+// Evaluate returns the product of the tent in x and in y
+func (f TentFilter) Evaluate(x, y float32) float32 {
+    tx := f.Size - float32(math.Abs(float64(x)))
+    ty := f.Size - float32(math.Abs(float64(y)))
+    if (tx <= 0.0 || ty <= 0.0) {
+        return 0.0
+    }
+
+    return tx * ty
+}
+
+// Radius returns where the Gaussian is cut off
+func (f GaussianFilter) Radius() float32 {
+    return f.Size
+}
+
+// Evaluate returns the product of the Gaussian in x and in y
+func (f GaussianFilter) Evaluate(x, y float32) float32 {
+    return f.gaussian(x) * f.gaussian(y)
+}
+
+// gaussian returns the one dimensional Gaussian minus its value at the radius so it reaches 0 there
+func (f GaussianFilter) gaussian(d float32) float32 {
+    edge := math.Exp(-float64(f.Size * f.Size) / (2.0 * float64(f.Sigma * f.Sigma)))
+    value := math.Exp(-float64(d * d) / (2.0 * float64(f.Sigma * f.Sigma)))
+    return float32(math.Max(0.0, value - edge))
+}
+
+// Radius returns the reach of the cubic
+func (f MitchellFilter) Radius() float32 {
+    return f.Size
+}
+
+// Evaluate returns the product of the cubic in x and in y
+func (f MitchellFilter) Evaluate(x, y float32) float32 {
+    return f.mitchell(x / f.Size) * f.mitchell(y / f.Size)
+}
+
+// mitchell evaluates the cubic with x scaled so the radius is 1
+func (f MitchellFilter) mitchell(x float32) float32 {
+    x = 2.0 * float32(math.Abs(float64(x)))
+    b := f.B
+    c := f.C
+
+    if (x > 2.0) {
+        return 0.0
+    } else if (x > 1.0) {
+        return ((-b - 6.0 * c) * x * x * x + (6.0 * b + 30.0 * c) * x * x + (-12.0 * b - 48.0 * c) * x + (8.0 * b + 24.0 * c)) / 6.0
+    }
+
+    return ((12.0 - 9.0 * b - 6.0 * c) * x * x * x + (-18.0 + 12.0 * b + 6.0 * c) * x * x + (6.0 - 2.0 * b)) / 6.0
+}
+
+// Radius returns where the window reaches 0
+func (f LanczosFilter) Radius() float32 {
+    return f.Size
+}
+
+// Evaluate returns the product of the windowed sinc in x and in y
+func (f LanczosFilter) Evaluate(x, y float32) float32 {
+    return f.windowedSinc(x) * f.windowedSinc(y)
+}
+
+// windowedSinc returns sinc(x) * sinc(x / tau) with x scaled so Tau lobes fit in the radius
+func (f LanczosFilter) windowedSinc(x float32) float32 {
+    x = float32(math.Abs(float64(x))) / f.Size
+    if (x >= 1.0) {
+        return 0.0
+    }
+
+    x *= f.Tau
+    return sinc(x) * sinc(x / f.Tau)
+}
+
+// sinc returns sin(pi x) / (pi x)
+func sinc(x float32) float32 {
+    if (x < 1e-5) {
+        return 1.0
+    }
+
+    px := math.Pi * float64(x)
+    return float32(math.Sin(px) / px)
+}
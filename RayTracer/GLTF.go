@@ -0,0 +1,812 @@
+package raytracer
+
+import
+(
+    "bytes"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "image"
+    _ "image/jpeg"
+    _ "image/png"
+    "io/ioutil"
+    "log"
+    "math"
+    "net/url"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+const (
+    // glbMagic is "glTF" at the start of a binary glTF file
+    glbMagic = 0x46546C67
+
+    // glbJSONChunk and glbBinaryChunk are the types of the chunks in a binary glTF file
+    glbJSONChunk = 0x4E4F534A
+    glbBinaryChunk = 0x004E4942
+
+    // gltfTriangles is the primitive mode for triangle lists, the only mode imported
+    gltfTriangles = 4
+
+    // gltfLightRadius is the radius of the spheres that stand in for point and spot lights
+    gltfLightRadius = 0.05
+
+    // gltfSunDistance is how far from the origin the sphere that stands in for a directional light is, and gltfSunAngle the angle in radians between its center and its edge seen from the origin.
+    // The sun's own half a degree would be found too rarely by scattered rays, so a wider disc gives the same light with less noise
+    gltfSunDistance = 1000.0
+    gltfSunAngle = 10.0 * math.Pi / 180.0
+
+    // maxZeroAccessorCount is the most elements an accessor without a buffer view may have
+    maxZeroAccessorCount = 1 << 24
+)
+
+// gltfExtensions are the glTF extensions the importer understands, a file that requires any other is rejected
+var gltfExtensions = map[string]bool {
+    "KHR_lights_punctual": true,
+    "KHR_materials_emissive_strength": true,
+    "KHR_materials_ior": true,
+    "KHR_materials_transmission": true }
+
+// gltfDocument is the json part of a glTF file, only the fields the importer uses are listed
+type gltfDocument struct {
+    ExtensionsRequired []string
+    Scene *int
+    Scenes []struct {
+        Nodes []int
+    }
+    Nodes []gltfNode
+    Meshes []struct {
+        Name string
+        Primitives []gltfPrimitive
+    }
+    Accessors []gltfAccessor
+    BufferViews []gltfBufferView
+    Buffers []struct {
+        URI string
+        ByteLength int
+    }
+    Materials []gltfMaterial
+    Textures []struct {
+        Source *int
+    }
+    Images []struct {
+        URI string
+        BufferView *int
+    }
+    Cameras []gltfCamera
+    Extensions struct {
+        LightsPunctual struct {
+            Lights []gltfLight
+        } `json:"KHR_lights_punctual"`
+    }
+}
+
+// gltfNode is a node of the scene hierarchy, its transform is a matrix or a translation, rotation and scale
+type gltfNode struct {
+    Name string
+    Children []int
+    Matrix []float64
+    Translation []float64
+    Rotation []float64
+    Scale []float64
+    Mesh *int
+    Camera *int
+    Extensions struct {
+        LightsPunctual *struct {
+            Light int
+        } `json:"KHR_lights_punctual"`
+    }
+}
+
+// gltfPrimitive is a part of a mesh with a single material
+type gltfPrimitive struct {
+    Attributes map[string]int
+    Indices *int
+    Material *int
+    Mode *int
+}
+
+// gltfAccessor describes how to read an array of numbers from a buffer view
+type gltfAccessor struct {
+    BufferView *int
+    ByteOffset int
+    ComponentType int
+    Normalized bool
+    Count int
+    Type string
+    Sparse json.RawMessage
+}
+
+// gltfBufferView is a range of a buffer
+type gltfBufferView struct {
+    Buffer int
+    ByteOffset int
+    ByteLength int
+    ByteStride int
+}
+
+// gltfTextureReference refers to a texture and the texture coordinates that map it
+type gltfTextureReference struct {
+    Index int
+    TexCoord int
+}
+
+// gltfMaterial is a physically based metallic roughness material
+type gltfMaterial struct {
+    PbrMetallicRoughness struct {
+        BaseColorFactor []float64
+        BaseColorTexture *gltfTextureReference
+        MetallicFactor *float64
+        RoughnessFactor *float64
+    }
+    EmissiveFactor []float64
+    Extensions struct {
+        EmissiveStrength *struct {
+            EmissiveStrength float64
+        } `json:"KHR_materials_emissive_strength"`
+        IOR *struct {
+            IOR *float64
+        } `json:"KHR_materials_ior"`
+        Transmission *struct {
+            TransmissionFactor float64
+        } `json:"KHR_materials_transmission"`
+    }
+}
+
+// gltfCamera is a perspective or orthographic camera
+type gltfCamera struct {
+    Type string
+    Perspective *struct {
+        Yfov float64
+    }
+    Orthographic *struct {
+        Ymag float64
+    }
+}
+
+// gltfLight is a light from the KHR_lights_punctual extension
+type gltfLight struct {
+    Type string
+    Color []float64
+    Intensity *float64
+}
+
+// gltfLoader holds a glTF file while it is being imported
+type gltfLoader struct {
+    document gltfDocument
+    directory string
+    prefix string
+    buffers [][]byte
+    textures map[int]*Texture
+    nodeNames []string
+    model loadedModel
+}
+
+// ImportGLTF adds the meshes and lights of a .gltf or .glb file to the global scene and returns its cameras by name.
+// Objects and cameras are named after the file and the node they came from, such as Chair in room.glb becoming room/Chair
+func ImportGLTF(filename string) map[string]cameraConfig {
+    model, err := loadGLTF(filename)
+    checkError(err)
+
+    names := make([]string, 0, len(model.Objects))
+    for name := range model.Objects {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        Scene.AddObject(name, model.Objects[name])
+    }
+    Scene.addModel(documentModel{ File: filename }, names)
+    Scene.BuildHierarchy()
+
+    return model.Cameras
+}
+
+// loadGLTF reads a .gltf or .glb file
+func loadGLTF(filename string) (loadedModel, error) {
+    loader := gltfLoader {
+        directory: filepath.Dir(filename),
+        prefix: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+        textures: make(map[int]*Texture),
+        model: loadedModel {
+            Objects: make(map[string]CollidableObject),
+            Cameras: make(map[string]cameraConfig) } }
+
+    contents := readFile(filename)
+    var binaryChunk []byte
+    if (len(contents) >= 4 && binary.LittleEndian.Uint32(contents) == glbMagic) {
+        var err error
+        contents, binaryChunk, err = splitGLB(contents)
+        if (err != nil) {
+            return loader.model, fmt.Errorf("%v: %v", filename, err)
+        }
+    }
+
+    if err := json.Unmarshal(contents, &loader.document); err != nil {
+        return loader.model, fmt.Errorf("%v: %v", filename, err)
+    }
+
+    if err := loader.load(binaryChunk); err != nil {
+        return loader.model, fmt.Errorf("%v: %v", filename, err)
+    }
+
+    return loader.model, nil
+}
+
+// splitGLB returns the json and binary chunks of a binary glTF file
+func splitGLB(contents []byte) ([]byte, []byte, error) {
+    if (len(contents) < 12) {
+        return nil, nil, fmt.Errorf("binary glTF header is truncated")
+    }
+    if version := binary.LittleEndian.Uint32(contents[4:]); version != 2 {
+        return nil, nil, fmt.Errorf("binary glTF version %v is not supported, only version 2 is", version)
+    }
+
+    var jsonChunk, binaryChunk []byte
+    for offset := 12; offset + 8 <= len(contents); {
+        length := int(binary.LittleEndian.Uint32(contents[offset:]))
+        chunkType := binary.LittleEndian.Uint32(contents[offset + 4:])
+        start := offset + 8
+        if (length < 0 || start + length > len(contents)) {
+            return nil, nil, fmt.Errorf("binary glTF chunk is truncated")
+        }
+
+        switch chunkType {
+            case glbJSONChunk:
+                jsonChunk = contents[start:start + length]
+            case glbBinaryChunk:
+                binaryChunk = contents[start:start + length]
+        }
+        offset = start + length
+    }
+
+    if (jsonChunk == nil) {
+        return nil, nil, fmt.Errorf("binary glTF file has no json chunk")
+    }
+
+    return jsonChunk, binaryChunk, nil
+}
+
+// load reads the buffers and adds every node of the default scene to the model
+func (l *gltfLoader) load(binaryChunk []byte) error {
+    for _, extension := range l.document.ExtensionsRequired {
+        if (false == gltfExtensions[extension]) {
+            return fmt.Errorf("requires the %v extension which is not supported", extension)
+        }
+    }
+
+    l.buffers = make([][]byte, len(l.document.Buffers))
+    for i, buffer := range l.document.Buffers {
+        if (buffer.URI == "") {
+            if (i != 0 || binaryChunk == nil) {
+                return fmt.Errorf("buffer %v has no uri", i)
+            }
+            l.buffers[i] = binaryChunk
+        } else {
+            contents, err := l.readURI(buffer.URI)
+            if (err != nil) {
+                return err
+            }
+            l.buffers[i] = contents
+        }
+
+        if (len(l.buffers[i]) < buffer.ByteLength) {
+            return fmt.Errorf("buffer %v has %v bytes, it should have %v", i, len(l.buffers[i]), buffer.ByteLength)
+        }
+    }
+
+    // Node names are used for objects and cameras, nodes without a unique name are named by their index
+    counts := make(map[string]int)
+    for _, node := range l.document.Nodes {
+        counts[node.Name]++
+    }
+    l.nodeNames = make([]string, len(l.document.Nodes))
+    for i, node := range l.document.Nodes {
+        name := node.Name
+        if (name == "" || counts[name] > 1) {
+            name = fmt.Sprintf("node%v", i)
+        }
+        l.nodeNames[i] = l.prefix + "/" + name
+    }
+
+    var roots []int
+    if (len(l.document.Scenes) > 0) {
+        scene := 0
+        if (l.document.Scene != nil) {
+            scene = *l.document.Scene
+        }
+        if (scene < 0 || scene >= len(l.document.Scenes)) {
+            return fmt.Errorf("scene %v does not exist", scene)
+        }
+        roots = l.document.Scenes[scene].Nodes
+    } else {
+        // Without scenes every node that is not a child of another is drawn
+        isChild := make([]bool, len(l.document.Nodes))
+        for _, node := range l.document.Nodes {
+            for _, child := range node.Children {
+                if (child >= 0 && child < len(isChild)) {
+                    isChild[child] = true
+                }
+            }
+        }
+        for i := range l.document.Nodes {
+            if (false == isChild[i]) {
+                roots = append(roots, i)
+            }
+        }
+    }
+
+    for _, root := range roots {
+        if err := l.loadNode(root, identityTransform, 0); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// readURI returns the contents of a data uri or of a file relative to the glTF file
+func (l *gltfLoader) readURI(uri string) ([]byte, error) {
+    if (strings.HasPrefix(uri, "data:")) {
+        comma := strings.Index(uri, ",")
+        if (comma < 0 || false == strings.HasSuffix(uri[:comma], ";base64")) {
+            return nil, fmt.Errorf("data uri is not base64 encoded")
+        }
+        return base64.StdEncoding.DecodeString(uri[comma + 1:])
+    }
+
+    path, err := url.PathUnescape(uri)
+    if (err != nil) {
+        return nil, err
+    }
+
+    return ioutil.ReadFile(filepath.Join(l.directory, filepath.FromSlash(path)))
+}
+
+// loadNode adds a node and its children to the model, parent is the transform of the node's parent
+func (l *gltfLoader) loadNode(index int, parent transformMatrix, depth int) error {
+    if (index < 0 || index >= len(l.document.Nodes)) {
+        return fmt.Errorf("node %v does not exist", index)
+    }
+    if (depth > len(l.document.Nodes)) {
+        return fmt.Errorf("node %v is its own ancestor", index)
+    }
+
+    node := l.document.Nodes[index]
+    transform := parent.multiply(node.localTransform())
+    name := l.nodeNames[index]
+
+    if (node.Mesh != nil) {
+        if err := l.loadMesh(*node.Mesh, name, transform); err != nil {
+            return err
+        }
+    }
+
+    if (node.Camera != nil) {
+        if (*node.Camera < 0 || *node.Camera >= len(l.document.Cameras)) {
+            return fmt.Errorf("camera %v does not exist", *node.Camera)
+        }
+        l.model.Cameras[name] = l.document.Cameras[*node.Camera].config(transform)
+    }
+
+    if (node.Extensions.LightsPunctual != nil) {
+        lights := l.document.Extensions.LightsPunctual.Lights
+        light := node.Extensions.LightsPunctual.Light
+        if (light < 0 || light >= len(lights)) {
+            return fmt.Errorf("light %v does not exist", light)
+        }
+        if sphere, ok := lights[light].sphere(transform); ok {
+            l.model.Objects[name + ".light"] = sphere
+        } else {
+            log.Printf("Skipping %v light of node %v, only point, spot and directional lights are imported", lights[light].Type, name)
+        }
+    }
+
+    for _, child := range node.Children {
+        if err := l.loadNode(child, transform, depth + 1); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// loadMesh adds each triangle primitive of a mesh as a mesh object with its vertices moved by transform
+func (l *gltfLoader) loadMesh(index int, name string, transform transformMatrix) error {
+    if (index < 0 || index >= len(l.document.Meshes)) {
+        return fmt.Errorf("mesh %v does not exist", index)
+    }
+
+    primitives := l.document.Meshes[index].Primitives
+    normalMatrix := transform.normalMatrix()
+    mirrored := transform.determinant() < 0.0
+
+    for p, primitive := range primitives {
+        if (primitive.Mode != nil && *primitive.Mode != gltfTriangles) {
+            log.Printf("Skipping primitive %v of %v, only triangle lists are imported", p, name)
+            continue
+        }
+
+        positionAccessor, exists := primitive.Attributes["POSITION"]
+        if (false == exists) {
+            return fmt.Errorf("primitive %v of %v has no positions", p, name)
+        }
+        values, err := l.readAccessor(positionAccessor, 3)
+        if (err != nil) {
+            return err
+        }
+        positions := make([]Vector3, len(values) / 3)
+        for i := range positions {
+            positions[i] = transform.transformPoint(values[i * 3], values[i * 3 + 1], values[i * 3 + 2])
+        }
+
+        var normals []Vector3
+        if normalAccessor, exists := primitive.Attributes["NORMAL"]; exists {
+            values, err := l.readAccessor(normalAccessor, 3)
+            if (err != nil) {
+                return err
+            }
+            normals = make([]Vector3, len(values) / 3)
+            for i := range normals {
+                normals[i] = normalMatrix.transformDirection(values[i * 3], values[i * 3 + 1], values[i * 3 + 2]).UnitVector()
+            }
+        }
+
+        material, texture, texCoord, err := l.material(primitive.Material)
+        if (err != nil) {
+            return err
+        }
+
+        var uvs [][2]float32
+        if uvAccessor, exists := primitive.Attributes[fmt.Sprintf("TEXCOORD_%v", texCoord)]; exists {
+            values, err := l.readAccessor(uvAccessor, 2)
+            if (err != nil) {
+                return err
+            }
+            uvs = make([][2]float32, len(values) / 2)
+            for i := range uvs {
+                uvs[i] = [2]float32{ float32(values[i * 2]), float32(values[i * 2 + 1]) }
+            }
+        } else {
+            texture = nil
+        }
+
+        var indices []uint32
+        if (primitive.Indices != nil) {
+            values, err := l.readAccessor(*primitive.Indices, 1)
+            if (err != nil) {
+                return err
+            }
+            indices = make([]uint32, len(values))
+            for i, value := range values {
+                if (value < 0 || int(value) >= len(positions)) {
+                    return fmt.Errorf("primitive %v of %v has index %v past its %v vertices", p, name, value, len(positions))
+                }
+                indices[i] = uint32(value)
+            }
+        } else {
+            indices = make([]uint32, len(positions))
+            for i := range indices {
+                indices[i] = uint32(i)
+            }
+        }
+        indices = indices[:len(indices) - len(indices) % 3]
+
+        // A mirroring transform turns the triangles inside out, swapping two corners turns them back
+        if (mirrored) {
+            for i := 0; i < len(indices); i += 3 {
+                indices[i + 1], indices[i + 2] = indices[i + 2], indices[i + 1]
+            }
+        }
+
+        objectName := name
+        if (len(primitives) > 1) {
+            objectName = fmt.Sprintf("%v.%v", name, p)
+        }
+        if err := checkMeshData(positions, normals, uvs, indices); err != nil {
+            return fmt.Errorf("%v: %v", objectName, err)
+        }
+        l.model.Objects[objectName] = NewMesh(positions, normals, uvs, indices, material, texture)
+    }
+
+    return nil
+}
+
+// readAccessor reads the numbers of an accessor, converting normalized integers to 0 to 1 or -1 to 1.
+// components is the number of numbers each element should have
+func (l *gltfLoader) readAccessor(index int, components int) ([]float64, error) {
+    if (index < 0 || index >= len(l.document.Accessors)) {
+        return nil, fmt.Errorf("accessor %v does not exist", index)
+    }
+
+    accessor := l.document.Accessors[index]
+    if (accessor.Sparse != nil) {
+        return nil, fmt.Errorf("accessor %v is sparse, sparse accessors are not supported", index)
+    }
+
+    elementComponents := map[string]int{ "SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4 }[accessor.Type]
+    if (elementComponents != components) {
+        return nil, fmt.Errorf("accessor %v has type %v, expected %v numbers per element", index, accessor.Type, components)
+    }
+
+    componentSize := map[int]int{ 5120: 1, 5121: 1, 5122: 2, 5123: 2, 5125: 4, 5126: 4 }[accessor.ComponentType]
+    if (componentSize == 0) {
+        return nil, fmt.Errorf("accessor %v has unknown component type %v", index, accessor.ComponentType)
+    }
+
+    if (accessor.Count < 0) {
+        return nil, fmt.Errorf("accessor %v has a negative count of %v", index, accessor.Count)
+    }
+
+    // An accessor without a buffer view is all zeros, its count is all there is to go on so it is limited instead
+    if (accessor.BufferView == nil) {
+        if (accessor.Count > maxZeroAccessorCount) {
+            return nil, fmt.Errorf("accessor %v has %v elements and no buffer view, at most %v are allowed", index, accessor.Count, maxZeroAccessorCount)
+        }
+        return make([]float64, accessor.Count * components), nil
+    }
+
+    if (*accessor.BufferView < 0 || *accessor.BufferView >= len(l.document.BufferViews)) {
+        return nil, fmt.Errorf("buffer view %v does not exist", *accessor.BufferView)
+    }
+    view := l.document.BufferViews[*accessor.BufferView]
+    if (view.Buffer < 0 || view.Buffer >= len(l.buffers)) {
+        return nil, fmt.Errorf("buffer %v does not exist", view.Buffer)
+    }
+    if (view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 || accessor.ByteOffset < 0) {
+        return nil, fmt.Errorf("accessor %v or its buffer view has a negative offset, length or stride", index)
+    }
+
+    stride := view.ByteStride
+    if (stride == 0) {
+        stride = componentSize * components
+    }
+
+    // The count is checked against the bytes the view holds before anything is allocated for it
+    data := l.buffers[view.Buffer]
+    start := view.ByteOffset + accessor.ByteOffset
+    end := view.ByteOffset + view.ByteLength
+    if (end > len(data)) {
+        end = len(data)
+    }
+    if (accessor.Count > 0) {
+        available := end - start - (componentSize * components)
+        if (available < 0 || accessor.Count - 1 > available / stride) {
+            return nil, fmt.Errorf("accessor %v reads past the end of its buffer view", index)
+        }
+    }
+
+    values := make([]float64, accessor.Count * components)
+    for i := 0; i < accessor.Count; i++ {
+        for c := 0; c < components; c++ {
+            values[i * components + c] = readComponent(data[start + i * stride + c * componentSize:], accessor.ComponentType, accessor.Normalized)
+        }
+    }
+
+    return values, nil
+}
+
+// readComponent reads a single little endian number of the given glTF component type
+func readComponent(data []byte, componentType int, normalized bool) float64 {
+    switch componentType {
+        case 5120:
+            value := float64(int8(data[0]))
+            if (normalized) {
+                return math.Max(value / 127.0, -1.0)
+            }
+            return value
+        case 5121:
+            value := float64(data[0])
+            if (normalized) {
+                return value / 255.0
+            }
+            return value
+        case 5122:
+            value := float64(int16(binary.LittleEndian.Uint16(data)))
+            if (normalized) {
+                return math.Max(value / 32767.0, -1.0)
+            }
+            return value
+        case 5123:
+            value := float64(binary.LittleEndian.Uint16(data))
+            if (normalized) {
+                return value / 65535.0
+            }
+            return value
+        case 5125:
+            return float64(binary.LittleEndian.Uint32(data))
+    }
+
+    return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+}
+
+// material converts a glTF material to the closest of the tracer's materials, returning its base color texture and the texture coordinates that map it.
+// Emissive materials become Emissive, transmissive ones Dielectric, metallic ones Metal with the roughness as fuzziness and everything else Lambertian
+func (l *gltfLoader) material(index *int) (Material, *Texture, int, error) {
+    if (index == nil) {
+        return defaultModelMaterial(), nil, 0, nil
+    }
+    if (*index < 0 || *index >= len(l.document.Materials)) {
+        return nil, nil, 0, fmt.Errorf("material %v does not exist", *index)
+    }
+
+    material := l.document.Materials[*index]
+    pbr := material.PbrMetallicRoughness
+
+    // glTF colors are linear, the tracer's are not gamma corrected so they are converted to display values
+    baseColor := NewVector3(1.0, 1.0, 1.0)
+    if (len(pbr.BaseColorFactor) >= 3) {
+        baseColor = linearToDisplay(pbr.BaseColorFactor)
+    }
+
+    var texture *Texture
+    texCoord := 0
+    if (pbr.BaseColorTexture != nil) {
+        var err error
+        texture, err = l.texture(pbr.BaseColorTexture.Index)
+        if (err != nil) {
+            return nil, nil, 0, err
+        }
+        texCoord = pbr.BaseColorTexture.TexCoord
+    }
+
+    emission := NewVector3(0.0, 0.0, 0.0)
+    if (len(material.EmissiveFactor) >= 3) {
+        emission = NewVector3(float32(material.EmissiveFactor[0]), float32(material.EmissiveFactor[1]), float32(material.EmissiveFactor[2]))
+        if (material.Extensions.EmissiveStrength != nil) {
+            emission = emission.Scale(float32(material.Extensions.EmissiveStrength.EmissiveStrength))
+        }
+    }
+    if (emission != Vector3{}) {
+        return Emissive{ Emission: emission }, texture, texCoord, nil
+    }
+
+    if (material.Extensions.Transmission != nil && material.Extensions.Transmission.TransmissionFactor > 0.5) {
+        refractiveIndex := 1.5
+        if (material.Extensions.IOR != nil && material.Extensions.IOR.IOR != nil) {
+            refractiveIndex = *material.Extensions.IOR.IOR
+        }
+        return Dielectric{ RefractiveIndex: float32(refractiveIndex), Attenuation: baseColor }, texture, texCoord, nil
+    }
+
+    // Metallic and roughness default to 1 when they are not given
+    metallic, roughness := 1.0, 1.0
+    if (pbr.MetallicFactor != nil) {
+        metallic = *pbr.MetallicFactor
+    }
+    if (pbr.RoughnessFactor != nil) {
+        roughness = *pbr.RoughnessFactor
+    }
+    if (metallic >= 0.5) {
+        return Metal{ Color: baseColor.AsColor(), Fuzziness: float32(roughness), Attenuation: baseColor }, texture, texCoord, nil
+    }
+
+    return Lambertian{ Color: baseColor.AsColor(), Attenuation: baseColor }, texture, texCoord, nil
+}
+
+// texture returns the image of a texture, each image is only decoded once
+func (l *gltfLoader) texture(index int) (*Texture, error) {
+    if (index < 0 || index >= len(l.document.Textures) || l.document.Textures[index].Source == nil) {
+        return nil, fmt.Errorf("texture %v does not exist or has no image", index)
+    }
+
+    source := *l.document.Textures[index].Source
+    if texture, loaded := l.textures[source]; loaded {
+        return texture, nil
+    }
+    if (source < 0 || source >= len(l.document.Images)) {
+        return nil, fmt.Errorf("image %v does not exist", source)
+    }
+
+    var contents []byte
+    imageSource := l.document.Images[source]
+    if (imageSource.BufferView != nil) {
+        if (*imageSource.BufferView < 0 || *imageSource.BufferView >= len(l.document.BufferViews)) {
+            return nil, fmt.Errorf("buffer view %v does not exist", *imageSource.BufferView)
+        }
+        view := l.document.BufferViews[*imageSource.BufferView]
+        if (view.Buffer < 0 || view.Buffer >= len(l.buffers) || view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset + view.ByteLength > len(l.buffers[view.Buffer])) {
+            return nil, fmt.Errorf("image %v reads past the end of its buffer", source)
+        }
+        contents = l.buffers[view.Buffer][view.ByteOffset:view.ByteOffset + view.ByteLength]
+    } else {
+        var err error
+        contents, err = l.readURI(imageSource.URI)
+        if (err != nil) {
+            return nil, err
+        }
+    }
+
+    img, _, err := image.Decode(bytes.NewReader(contents))
+    if (err != nil) {
+        return nil, fmt.Errorf("image %v: %v", source, err)
+    }
+
+    l.textures[source] = NewTexture(img)
+    return l.textures[source], nil
+}
+
+// linearToDisplay converts the first three values of a linear glTF color to the display values the tracer uses
+func linearToDisplay(c []float64) Vector3 {
+    convert := func(value float64) float32 {
+        return float32(math.Pow(math.Max(value, 0.0), 1.0 / 2.2))
+    }
+
+    return NewVector3(convert(c[0]), convert(c[1]), convert(c[2]))
+}
+
+// config converts a glTF camera placed by transform to camera settings.  glTF cameras look down their -Z axis with +Y up
+func (c gltfCamera) config(transform transformMatrix) cameraConfig {
+    origin := transform.transformPoint(0.0, 0.0, 0.0)
+    config := cameraConfig {
+        LookFrom: origin,
+        LookAt: origin.Add(transform.transformDirection(0.0, 0.0, -1.0).UnitVector()),
+        Up: transform.transformDirection(0.0, 1.0, 0.0).UnitVector() }
+
+    if (c.Type == "orthographic" && c.Orthographic != nil) {
+        config.Type = OrthographicCameraType
+        config.OrthographicHeight = float32(2.0 * c.Orthographic.Ymag)
+    } else if (c.Perspective != nil) {
+        config.Fov = float32(c.Perspective.Yfov * 180.0 / math.Pi)
+    }
+
+    return config
+}
+
+// sphere returns the small emissive sphere that stands in for a point or spot light, spot lights shine in every direction.
+// The emission is chosen so the sphere gives off the light's intensity in candela.
+// A directional light such as Blender's sun becomes a large distant sphere opposite the way it shines, its emission chosen so it lights the scene with the light's intensity in lux
+func (l gltfLight) sphere(transform transformMatrix) (Sphere, bool) {
+    if (l.Type != "point" && l.Type != "spot" && l.Type != "directional") {
+        return Sphere{}, false
+    }
+
+    lightColor := NewVector3(1.0, 1.0, 1.0)
+    if (len(l.Color) >= 3) {
+        lightColor = NewVector3(float32(l.Color[0]), float32(l.Color[1]), float32(l.Color[2]))
+    }
+    intensity := 1.0
+    if (l.Intensity != nil) {
+        intensity = *l.Intensity
+    }
+
+    // Directional lights shine along the node's -Z axis
+    if (l.Type == "directional") {
+        direction := transform.transformDirection(0.0, 0.0, -1.0).UnitVector()
+        sinAngle := math.Sin(gltfSunAngle)
+        return Sphere {
+            Origin: direction.Scale(-gltfSunDistance),
+            Radius: float32(gltfSunDistance * sinAngle),
+            Properties: Emissive{ Emission: lightColor.Scale(float32(intensity / (math.Pi * sinAngle * sinAngle))) } }, true
+    }
+
+    return Sphere {
+        Origin: transform.transformPoint(0.0, 0.0, 0.0),
+        Radius: gltfLightRadius,
+        Properties: Emissive{ Emission: lightColor.Scale(float32(intensity / (math.Pi * gltfLightRadius * gltfLightRadius))) } }, true
+}
+
+// localTransform returns the node's transform relative to its parent
+func (n gltfNode) localTransform() transformMatrix {
+    if (len(n.Matrix) == 16) {
+        var m transformMatrix
+        copy(m[:], n.Matrix)
+        return m
+    }
+
+    m := identityTransform
+    if (len(n.Scale) == 3) {
+        m[0], m[5], m[10] = n.Scale[0], n.Scale[1], n.Scale[2]
+    }
+    if (len(n.Rotation) == 4) {
+        m = quaternionMatrix(n.Rotation[0], n.Rotation[1], n.Rotation[2], n.Rotation[3]).multiply(m)
+    }
+    if (len(n.Translation) == 3) {
+        m[12], m[13], m[14] = n.Translation[0], n.Translation[1], n.Translation[2]
+    }
+
+    return m
+}
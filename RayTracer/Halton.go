@@ -0,0 +1,84 @@
+package raytracer
+
+import
+(
+    "sync"
+)
+
+// HaltonSampler draws samples from the Halton sequence with a per pixel Cranley-Patterson rotation
+type HaltonSampler struct {
+    samplerState
+}
+
+// maxHaltonDimensions is the number of prime bases generated for the Halton sequence, higher dimensions fall back to hashed random numbers
+const maxHaltonDimensions = 256
+
+var haltonPrimes []uint32
+var haltonPrimesOnce sync.Once
+
+// generateHaltonPrimes fills haltonPrimes with the first maxHaltonDimensions primes
+func generateHaltonPrimes() {
+    haltonPrimes = make([]uint32, 0, maxHaltonDimensions)
+    for candidate := uint32(2); len(haltonPrimes) < maxHaltonDimensions; candidate++ {
+        isPrime := true
+        for _, p := range haltonPrimes {
+            if (p * p > candidate) {
+                break
+            }
+
+            if (candidate % p == 0) {
+                isPrime = false
+                break
+            }
+        }
+
+        if (isPrime) {
+            haltonPrimes = append(haltonPrimes, candidate)
+        }
+    }
+}
+
+// radicalInverse mirrors the digits of index in the given base around the decimal point
+func radicalInverse(base, index uint32) float32 {
+    invBase := 1.0 / float64(base)
+    invBaseN := 1.0
+    reversed := uint64(0)
+
+    for index > 0 {
+        next := index / base
+        digit := index - next * base
+        reversed = reversed * uint64(base) + uint64(digit)
+        invBaseN *= invBase
+        index = next
+    }
+
+    return clampSample(float32(float64(reversed) * invBaseN))
+}
+
+// Get1D returns the next Halton dimension for the current sample
+func (s *HaltonSampler) Get1D() float32 {
+    haltonPrimesOnce.Do(generateHaltonPrimes)
+
+    d := s.nextDimension()
+    if (d >= maxHaltonDimensions) {
+        return hashToFloat(mixBits(s.pixelHash, d, s.sampleIndex))
+    }
+
+    v := radicalInverse(haltonPrimes[d], s.sampleIndex) + hashToFloat(mixBits(s.pixelHash, d))
+    if (v >= 1.0) {
+        v -= 1.0
+    }
+
+    return clampSample(v)
+}
+
+// Get2D returns the next two Halton dimensions for the current sample
+func (s *HaltonSampler) Get2D() (float32, float32) {
+    return s.Get1D(), s.Get1D()
+}
+
+// Clone returns a copy of the Halton sampler
+func (s *HaltonSampler) Clone() Sampler {
+    c := *s
+    return &c
+}
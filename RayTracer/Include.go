@@ -0,0 +1,239 @@
+package raytracer
+
+import
+(
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// documentInclude adds another scene document to a scene document, File is relative to the including document.
+// Namespace is put in front of the names of everything included followed by a slash so pieces can be included more than once, Transform places the included contents in the scene
+type documentInclude struct {
+    File string
+    Namespace string `json:",omitempty"`
+    Transform *documentTransform `json:",omitempty"`
+}
+
+// documentTransform moves, turns and scales included contents.  They are scaled by Scale, then rotated by Rotate.X degrees around the X axis, Rotate.Y around Y and Rotate.Z around Z, then moved by Translate.
+// Matrix is a 4x4 matrix stored column by column used instead of the others when it is given, spheres stay round under a Matrix that scales each axis differently
+type documentTransform struct {
+    Translate Vector3
+    Rotate Vector3
+
+    // Scale defaults to 1
+    Scale float32 `json:",omitempty"`
+    Matrix []float64 `json:",omitempty"`
+}
+
+// matrix returns the transform as a matrix
+func (t documentTransform) matrix() transformMatrix {
+    if (len(t.Matrix) > 0) {
+        if (len(t.Matrix) != 16) {
+            log.Fatalf("Transform matrix has %v numbers, it needs 16", len(t.Matrix))
+        }
+
+        var m transformMatrix
+        copy(m[:], t.Matrix)
+        return m
+    }
+
+    scale := t.Scale
+    if (scale == 0.0) {
+        scale = 1.0
+    } else if (scale < 0.0) {
+        log.Fatalf("Transform scale %v is negative", scale)
+    }
+
+    m := identityTransform
+    m[0], m[5], m[10] = float64(scale), float64(scale), float64(scale)
+    m = rotationMatrix(t.Rotate).multiply(m)
+    m[12], m[13], m[14] = float64(t.Translate.X), float64(t.Translate.Y), float64(t.Translate.Z)
+    return m
+}
+
+// resolveIncludes adds the contents of the documents d includes to d, and of the documents they include, so d no longer includes anything.
+// Included files are relative to directory and including lists the files already being included so a cycle of includes is reported instead of recursing forever
+func (d *sceneDocument) resolveIncludes(directory string, including []string) {
+    includes := d.Includes
+    d.Includes = nil
+
+    for _, include := range includes {
+        filename := include.File
+        if (false == filepath.IsAbs(filename)) {
+            filename = filepath.Join(directory, filename)
+        }
+        filename, err := filepath.Abs(filename)
+        checkError(err)
+
+        chain := append(append([]string{}, including...), filename)
+        for _, file := range including {
+            if (file == filename) {
+                log.Fatalf("Scene documents include each other in a cycle: %v", strings.Join(chain, " -> "))
+            }
+        }
+
+        if _, err := os.Stat(filename); err != nil {
+            if (len(including) > 0) {
+                log.Fatalf("%v includes %v which cannot be read: %v", including[len(including) - 1], include.File, err)
+            }
+            log.Fatalf("Included file %v cannot be read: %v", include.File, err)
+        }
+
+        contents := readFile(filename)
+        if (false == isSceneDocument(contents)) {
+            log.Fatalf("Included file %v is not a scene document", filename)
+        }
+
+        included := decodeSceneDocument(contents, filename)
+        included.resolveIncludes(filepath.Dir(filename), chain)
+        d.merge(included, include, filename)
+    }
+}
+
+// merge adds the contents of an included document to d with the include's namespace and transform, filename is the included file for errors
+func (d *sceneDocument) merge(included sceneDocument, include documentInclude, filename string) {
+    name := func(n string) string {
+        if (include.Namespace == "") {
+            return n
+        }
+        return include.Namespace + "/" + n
+    }
+
+    // Materials the included document defines are renamed with it, others are left to be found in the including document's library
+    materialName := func(n string) string {
+        if _, defined := included.Materials[n]; defined {
+            return name(n)
+        }
+        return n
+    }
+
+    transform := identityTransform
+    if (include.Transform != nil) {
+        transform = include.Transform.matrix()
+    }
+    scale := transform.scaleFactor()
+
+    // taken reports names that are already used so included contents never silently replace anything
+    taken := func(kind, n string, exists bool) {
+        if (exists) {
+            log.Fatalf("%v %q included from %v is already in the scene, give the include a Namespace", kind, n, filename)
+        }
+    }
+
+    for n, definition := range included.Materials {
+        renamed := make(MaterialDefinition, len(definition))
+        for field, value := range definition {
+            renamed[field] = value
+        }
+        if parent, ok := definition[inheritsField].(string); ok {
+            renamed[inheritsField] = materialName(parent)
+        }
+
+        _, exists := d.Materials[name(n)]
+        taken("Material", name(n), exists)
+        if (nil == d.Materials) {
+            d.Materials = make(map[string]MaterialDefinition)
+        }
+        d.Materials[name(n)] = renamed
+    }
+
+    for n, camera := range included.Cameras {
+        _, exists := d.Cameras[name(n)]
+        taken("Camera", name(n), exists)
+        if (nil == d.Cameras) {
+            d.Cameras = make(map[string]cameraConfig)
+        }
+        d.Cameras[name(n)] = transform.transformCamera(camera)
+    }
+
+    for n, object := range included.Objects {
+        _, exists := d.Objects[name(n)]
+        taken("Object", name(n), exists)
+        if (nil == d.Objects) {
+            d.Objects = make(map[string]documentObject)
+        }
+
+        object.Origin = transform.transformVector(object.Origin)
+        object.Radius *= scale
+        if (object.Motion != nil) {
+            motion := transform.transformMotion(*object.Motion)
+            object.Motion = &motion
+        }
+        if (object.Material != "") {
+            object.Material = materialName(object.Material)
+        }
+        d.Objects[name(n)] = object
+    }
+
+    for n, light := range included.Lights {
+        _, exists := d.Lights[name(n)]
+        taken("Light", name(n), exists)
+        if (nil == d.Lights) {
+            d.Lights = make(map[string]documentLight)
+        }
+
+        light.Origin = transform.transformVector(light.Origin)
+        light.Radius *= scale
+        if (light.Motion != nil) {
+            motion := transform.transformMotion(*light.Motion)
+            light.Motion = &motion
+        }
+        d.Lights[name(n)] = light
+    }
+
+    for n, mesh := range included.Meshes {
+        _, exists := d.Meshes[name(n)]
+        taken("Mesh", name(n), exists)
+        if (nil == d.Meshes) {
+            d.Meshes = make(map[string]documentMesh)
+        }
+
+        mesh.Positions, mesh.Normals, mesh.Indices = transform.transformMeshData(mesh.Positions, mesh.Normals, mesh.Indices)
+        if (mesh.Material != "") {
+            mesh.Material = materialName(mesh.Material)
+        }
+        d.Meshes[name(n)] = mesh
+    }
+
+    for n, points := range included.PointClouds {
+        _, exists := d.PointClouds[name(n)]
+        taken("Point cloud", name(n), exists)
+        if (nil == d.PointClouds) {
+            d.PointClouds = make(map[string]documentPointCloud)
+        }
+
+        points.Positions, points.Radii = transform.transformPointData(points.Positions, points.Radii)
+        points.Radius *= scale
+        if (points.Material != "") {
+            points.Material = materialName(points.Material)
+        }
+        d.PointClouds[name(n)] = points
+    }
+
+    // Models are loaded later so they keep the namespace and transform to be applied then
+    for _, model := range included.Models {
+        if (false == filepath.IsAbs(model.File)) {
+            model.File = filepath.Join(filepath.Dir(filename), model.File)
+        }
+        if (model.Namespace != "") {
+            model.Namespace = name(model.Namespace)
+        } else {
+            model.Namespace = include.Namespace
+        }
+        if (model.Material != "") {
+            model.Material = materialName(model.Material)
+        }
+
+        modelTransform := transform
+        if (model.Transform != nil) {
+            modelTransform = transform.multiply(model.Transform.matrix())
+        }
+        if (include.Transform != nil || model.Transform != nil) {
+            model.Transform = &documentTransform{ Matrix: modelTransform[:] }
+        }
+
+        d.Models = append(d.Models, model)
+    }
+}
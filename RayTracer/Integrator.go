@@ -0,0 +1,319 @@
+package raytracer
+
+import
+(
+    "log"
+    "math"
+)
+
+// Integrator is an interface for lighting algorithms that compute the color seen along a camera ray
+type Integrator interface {
+    // Li returns the light arriving along r, every random decision is made with s
+    Li(r Ray, w World, s Sampler) Vector3
+}
+
+const (
+    // PathIntegratorType is the full path tracer
+    PathIntegratorType = "path"
+
+    // WhittedIntegratorType lights diffuse surfaces directly and follows only perfect reflections and refractions
+    WhittedIntegratorType = "whitted"
+
+    // AmbientOcclusionIntegratorType shades surfaces by how much of their hemisphere is unoccluded
+    AmbientOcclusionIntegratorType = "ao"
+
+    // NormalsIntegratorType shows surface normals as colors
+    NormalsIntegratorType = "normals"
+
+    // UVIntegratorType shows surface texture coordinates as colors
+    UVIntegratorType = "uv"
+
+    // DepthIntegratorType shows the distance to the first hit as brightness
+    DepthIntegratorType = "depth"
+
+    // CostIntegratorType shows the number of bounding box and object tests made by camera rays as a heatmap
+    CostIntegratorType = "cost"
+)
+
+// sceneEpsilon is the minimum distance along a ray that counts as a hit, it keeps rays from hitting the surface they start on
+const sceneEpsilon = 0.0001
+
+// PathIntegrator traces rays through every bounce using the materials' scatter functions
+type PathIntegrator struct {}
+
+// WhittedIntegrator is a fast preview integrator that only follows perfect mirror and glass paths
+type WhittedIntegrator struct {}
+
+// AmbientOcclusionIntegrator shades the first hit by the fraction of hemisphere rays that escape within Distance
+type AmbientOcclusionIntegrator struct {
+    Distance float32
+}
+
+// DebugIntegrator shows a property of the first hit, Mode is one of normals, uv or depth
+type DebugIntegrator struct {
+    Mode string
+    DepthRange float32
+}
+
+// CostIntegrator shows the traversal cost of camera rays as a heatmap where CostRange tests is the hottest color
+type CostIntegrator struct {
+    CostRange float32
+}
+
+// CreateIntegrator creates the integrator named by integratorType using the current settings
+func CreateIntegrator(integratorType string) Integrator {
+    switch integratorType {
+        case "", PathIntegratorType:
+            return PathIntegrator{}
+        case WhittedIntegratorType:
+            return WhittedIntegrator{}
+        case AmbientOcclusionIntegratorType:
+            return AmbientOcclusionIntegrator {
+                Distance: defaultFloat(Settings.AmbientOcclusionDistance, 1.0) }
+        case NormalsIntegratorType, UVIntegratorType, DepthIntegratorType:
+            return DebugIntegrator {
+                Mode: integratorType,
+                DepthRange: defaultFloat(Settings.DebugDepthRange, 100.0) }
+        case CostIntegratorType:
+            return CostIntegrator {
+                CostRange: defaultFloat(Settings.DebugCostRange, 64.0) }
+    }
+
+    log.Fatalf("Unknown integrator type %q", integratorType)
+    return nil
+}
+
+// defaultFloat returns value, or fallback when value is not set
+func defaultFloat(value, fallback float32) float32 {
+    if (value <= 0.0) {
+        return fallback
+    }
+
+    return value
+}
+
+// raysPerBounce returns the number of rays to scatter from each intersection
+func raysPerBounce() uint32 {
+    if (Settings.MaxRaysPerBounce == 0) {
+        return 1
+    }
+
+    return Settings.MaxRaysPerBounce
+}
+
+// Li traces the ray and all of its scattered rays
+func (p PathIntegrator) Li(r Ray, w World, s Sampler) Vector3 {
+    return p.shootRay(r, w, s, 0)
+}
+
+// shootRay shoots a ray and tests for intersection
+func (p PathIntegrator) shootRay(r Ray, w World, s Sampler, bounces uint32) Vector3 {
+    collided, record := w.TestCollision(r, sceneEpsilon, math.MaxFloat32)
+    if (false == collided) {
+        return skyColor(r.Direction)
+    }
+    w.countPathVertex()
+
+    // If the ray has bounced more times than the provided amout return white
+    if (bounces > Settings.MaxBounces) {
+        return NewVector3(1.0, 1.0, 1.0)
+    }
+
+    if (record.Material.IsEmissive()) {
+        return record.Material.GetEmission()
+    }
+
+    // Bounce multiple rays and average the color
+    var c Vector3
+    rays := raysPerBounce()
+    for ray := uint32(0); ray < rays; ray++ {
+        bouncedRay := record.Material.Scatter(r, record, s)
+        bouncedRay.Time = r.Time
+        c = c.Add(p.shootRay(bouncedRay, w, s, bounces + 1))
+    }
+    c = c.Scale(1.0 / float32(rays))
+
+    // Multiply this objects color with the incoming color
+    return c.Multiply(record.Material.GetAttenuation())
+}
+
+// Li traces the ray through mirrors and glass and lights everything else directly
+func (wi WhittedIntegrator) Li(r Ray, w World, s Sampler) Vector3 {
+    return wi.shootRay(r, w, 0)
+}
+
+// shootRay shoots a ray and tests for intersection
+func (wi WhittedIntegrator) shootRay(r Ray, w World, bounces uint32) Vector3 {
+    collided, record := w.TestCollision(r, sceneEpsilon, math.MaxFloat32)
+    if (false == collided) {
+        return skyColor(r.Direction)
+    }
+    w.countPathVertex()
+
+    if (record.Material.IsEmissive()) {
+        return record.Material.GetEmission()
+    }
+
+    if (bounces > Settings.MaxBounces) {
+        return Vector3{}
+    }
+
+    switch m := record.Material.(type) {
+        case Metal:
+            reflected := Ray {
+                Origin: record.Point,
+                Direction: calculateReflectionVector(r.Direction, record.Normal),
+                Time: r.Time }
+            return wi.shootRay(reflected, w, bounces + 1).Multiply(m.Attenuation)
+        case Dielectric:
+            reflected, refracted, reflectance := calculateFresnelRays(r, record, m.RefractiveIndex)
+            reflected.Time = r.Time
+            refracted.Time = r.Time
+            c := wi.shootRay(reflected, w, bounces + 1).Scale(reflectance)
+            if (reflectance < 1.0) {
+                c = c.Add(wi.shootRay(refracted, w, bounces + 1).Scale(1.0 - reflectance))
+            }
+            return c.Multiply(m.Attenuation)
+    }
+
+    return directLighting(r, record, w).Multiply(record.Material.GetAttenuation())
+}
+
+// directLighting returns the light arriving at a diffuse hit from the sky and from every emissive sphere that is not shadowed
+func directLighting(r Ray, i IntersectionRecord, w World) Vector3 {
+    normal := i.Normal
+    if (r.Direction.Dot(normal) > 0.0) {
+        normal = normal.Scale(-1.0)
+    }
+
+    // Treat the sky above the normal as a uniform ambient light
+    c := skyColor(normal)
+
+    for _, light := range w.Scene.emissiveSpheres() {
+        toLight := light.centerAt(r.Time).Subtract(i.Point)
+        distance := float32(toLight.Length())
+        if (distance <= light.Radius) {
+            continue
+        }
+
+        direction := toLight.Scale(1.0 / distance)
+        cosine := normal.Dot(direction)
+        if (cosine <= 0.0) {
+            continue
+        }
+
+        shadowRay := Ray {
+            Origin: i.Point,
+            Direction: direction,
+            Time: r.Time }
+        if occluded, _ := w.TestCollision(shadowRay, sceneEpsilon, distance - light.Radius * 1.001); occluded {
+            continue
+        }
+
+        // A sphere of radius R at distance d subtends roughly pi * R^2 / d^2 steradians
+        falloff := (light.Radius * light.Radius) / (distance * distance)
+        c = c.Add(light.Properties.GetEmission().Scale(cosine * falloff))
+    }
+
+    return c
+}
+
+// Li returns white where the first hit is unoccluded and darker the more nearby geometry blocks it
+func (a AmbientOcclusionIntegrator) Li(r Ray, w World, s Sampler) Vector3 {
+    collided, record := w.TestCollision(r, sceneEpsilon, math.MaxFloat32)
+    if (false == collided) {
+        return NewVector3(1.0, 1.0, 1.0)
+    }
+    w.countPathVertex()
+
+    normal := record.Normal
+    if (r.Direction.Dot(normal) > 0.0) {
+        normal = normal.Scale(-1.0)
+    }
+
+    rays := raysPerBounce()
+    unoccluded := uint32(0)
+    for ray := uint32(0); ray < rays; ray++ {
+        occlusionRay := Ray {
+            Origin: record.Point,
+            Direction: sampleCosineHemisphere(normal, s),
+            Time: r.Time }
+
+        if occluded, _ := w.TestCollision(occlusionRay, sceneEpsilon, a.Distance); !occluded {
+            unoccluded++
+        }
+    }
+
+    v := float32(unoccluded) / float32(rays)
+    return NewVector3(v, v, v)
+}
+
+// Li returns the normal, texture coordinates, or depth of the first hit as a color
+func (d DebugIntegrator) Li(r Ray, w World, s Sampler) Vector3 {
+    collided, record := w.TestCollision(r, sceneEpsilon, math.MaxFloat32)
+    if (false == collided) {
+        return Vector3{}
+    }
+    w.countPathVertex()
+
+    switch d.Mode {
+        case NormalsIntegratorType:
+            return getNormalAsColor(record.Normal)
+        case UVIntegratorType:
+            return NewVector3(record.U, record.V, 0.0)
+    }
+
+    v := restrictValues(1.0 - record.T / d.DepthRange, 0.0, 1.0)
+    return NewVector3(v, v, v)
+}
+
+// Li returns a heatmap color for the number of tests needed to find the first hit
+func (c CostIntegrator) Li(r Ray, w World, s Sampler) Vector3 {
+    var counters collisionCounters
+    w.countRay()
+    w.Scene.testCollisionWithCounters(r, sceneEpsilon, math.MaxFloat32, &counters)
+    return heatmapColor(float32(counters.Tests) / c.CostRange)
+}
+
+// heatmapColor maps t in [0, 1] to a blue, cyan, green, yellow, red ramp
+func heatmapColor(t float32) Vector3 {
+    ramp := []Vector3 {
+        NewVector3(0.0, 0.0, 1.0),
+        NewVector3(0.0, 1.0, 1.0),
+        NewVector3(0.0, 1.0, 0.0),
+        NewVector3(1.0, 1.0, 0.0),
+        NewVector3(1.0, 0.0, 0.0) }
+
+    t = restrictValues(t, 0.0, 1.0) * float32(len(ramp) - 1)
+    index := int(t)
+    if (index >= len(ramp) - 1) {
+        return ramp[len(ramp) - 1]
+    }
+
+    f := t - float32(index)
+    return ramp[index].Scale(1.0 - f).Add(ramp[index + 1].Scale(f))
+}
+
+// sampleCosineHemisphere returns a direction around normal n distributed by the cosine to n
+func sampleCosineHemisphere(n Vector3, s Sampler) Vector3 {
+    u, v := s.Get2D()
+    radius := float32(math.Sqrt(float64(u)))
+    phi := 2.0 * math.Pi * float64(v)
+    x := radius * float32(math.Cos(phi))
+    y := radius * float32(math.Sin(phi))
+    z := float32(math.Sqrt(math.Max(0.0, float64(1.0 - u))))
+
+    tangent, bitangent := orthonormalBasis(n)
+    return tangent.Scale(x).Add(bitangent.Scale(y)).Add(n.Scale(z)).UnitVector()
+}
+
+// orthonormalBasis returns two unit vectors perpendicular to the unit vector n and to each other
+func orthonormalBasis(n Vector3) (Vector3, Vector3) {
+    helper := NewVector3(1.0, 0.0, 0.0)
+    if (math.Abs(float64(n.X)) > 0.9) {
+        helper = NewVector3(0.0, 1.0, 0.0)
+    }
+
+    tangent := helper.Cross(n).UnitVector()
+    return tangent, n.Cross(tangent)
+}
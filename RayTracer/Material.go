@@ -5,12 +5,11 @@ import
     "encoding/json"
     "image/color"
     "math"
-    "math/rand"
 )
 
 // Material is a interface that returns where a scattered ray will be when it reflects off an object
 type Material interface {
-    Scatter(r Ray, i IntersectionRecord) Ray
+    Scatter(r Ray, i IntersectionRecord, s Sampler) Ray
     GetAttenuation() Vector3
     GetEmission() Vector3
     IsEmissive() bool
@@ -46,38 +45,30 @@ func calculateReflectionVector(d, n Vector3) Vector3 {
 }
 
 // getNormalAsColor give a normal n, return the color value for that normal
-func getNormalAsColor(n Vector3) color.RGBA {
-    // Render normals
-    c := n.Scale(0.5)
-    c = c.Add(Vector3{X:1.0, Y:1.0, Z:1.0})
+func getNormalAsColor(n Vector3) Vector3 {
+    // Map each component from [-1, 1] to [0, 1]
+    c := n.Add(Vector3{X:1.0, Y:1.0, Z:1.0})
+    c = c.Scale(0.5)
     
-    c.X = float32(math.Min(1.0, float64(c.X)))
-    c.Y = float32(math.Min(1.0, float64(c.Y)))
-    c.Z = float32(math.Min(1.0, float64(c.Z)))
-    return c.AsColor()
+    c.X = restrictValues(c.X, 0.0, 1.0)
+    c.Y = restrictValues(c.Y, 0.0, 1.0)
+    c.Z = restrictValues(c.Z, 0.0, 1.0)
+    return c
 }
 
-func createUnitSphereVector() Vector3 {
-    return Vector3 {
-        rand.Float32(),
-        rand.Float32(),
-        rand.Float32() }.Subtract(Vector3 {
-            1.0,
-            1.0,
-            1.0 }).Multiply(Vector3 {
-                2.0,
-                2.0,
-                2.0 })
-}
+// randomVectorInUnitSphere maps three sample values to a point uniformly distributed inside the unit sphere
+func randomVectorInUnitSphere(s Sampler) Vector3 {
+    u, v := s.Get2D()
+    radius := float32(math.Cbrt(float64(s.Get1D())))
 
-func  randomVectorInUnitSphere() Vector3 {
-    p := createUnitSphereVector()
-    
-    for p.Dot(p) >= 1.0 {
-        p = createUnitSphereVector()
-    }
-    
-    return p
+    z := 1.0 - 2.0 * u
+    ring := float32(math.Sqrt(math.Max(0.0, float64(1.0 - z * z))))
+    phi := 2.0 * math.Pi * float64(v)
+
+    return Vector3 {
+        X: ring * float32(math.Cos(phi)),
+        Y: ring * float32(math.Sin(phi)),
+        Z: z }.Scale(radius)
 }
 
 func restrictValues(num, min, max float32) float32 {
@@ -86,14 +77,14 @@ func restrictValues(num, min, max float32) float32 {
     return num
 }
 
-func calculateReflectionRay(r Ray, i IntersectionRecord, fuzziness float32) Ray {
+func calculateReflectionRay(r Ray, i IntersectionRecord, fuzziness float32, s Sampler) Ray {
     return Ray {
         Origin: i.Point,
-        Direction: calculateReflectionVector(r.Direction, i.Normal).Add(randomVectorInUnitSphere().Scale(fuzziness)).UnitVector() }
+        Direction: calculateReflectionVector(r.Direction, i.Normal).Add(randomVectorInUnitSphere(s).Scale(fuzziness)).UnitVector() }
 }
 
-func calculateDiffuseRay(i IntersectionRecord) Ray {
-        target := i.Point.Add(i.Normal).Add(randomVectorInUnitSphere())
+func calculateDiffuseRay(i IntersectionRecord, s Sampler) Ray {
+        target := i.Point.Add(i.Normal).Add(randomVectorInUnitSphere(s))
         return Ray {
             Origin: i.Point,
             Direction: target.Subtract(i.Point).UnitVector() }
@@ -116,10 +107,10 @@ func calculateRefractionVector(incomingVector, normal Vector3, niOverNt float32)
     return false, Vector3{}
 }
 
-func calculateRefractedRay(r Ray, i IntersectionRecord, refractiveIndex float32) Ray {
+// calculateFresnelRays returns the reflected and refracted rays leaving a dielectric surface along with the probability of reflection
+func calculateFresnelRays(r Ray, i IntersectionRecord, refractiveIndex float32) (Ray, Ray, float32) {
     var outwardNormal Vector3
     var niOverNt float32
-    var refractedRay Ray
     var cosine float32
     var reflectionProbability float32
     reflectionVector := calculateReflectionVector(r.Direction, i.Normal)
@@ -136,26 +127,34 @@ func calculateRefractedRay(r Ray, i IntersectionRecord, refractiveIndex float32)
     
     isRefracted, refractedVector := calculateRefractionVector(r.Direction, outwardNormal, niOverNt)
     
-    refractedRay.Origin = i.Point
+    reflectedRay := Ray {
+        Origin: i.Point,
+        Direction: reflectionVector }
+    refractedRay := reflectedRay
     
     if (isRefracted) {
         reflectionProbability = schlickReflectanceProbability(cosine, refractiveIndex)
+        refractedRay.Direction = refractedVector.UnitVector()
     } else {
         reflectionProbability = 1.0
     }
     
-    if (rand.Float32() < reflectionProbability) {
-        refractedRay.Direction = reflectionVector
-    } else {
-        refractedRay.Direction = refractedVector.UnitVector()
+    return reflectedRay, refractedRay, reflectionProbability
+}
+
+func calculateRefractedRay(r Ray, i IntersectionRecord, refractiveIndex float32, s Sampler) Ray {
+    reflectedRay, refractedRay, reflectionProbability := calculateFresnelRays(r, i, refractiveIndex)
+    
+    if (s.Get1D() < reflectionProbability) {
+        return reflectedRay
     }
     
     return refractedRay
 }
 
 // Scatter for lambertian materials
-func (l Lambertian) Scatter(r Ray, i IntersectionRecord) Ray {
-    return calculateDiffuseRay(i)
+func (l Lambertian) Scatter(r Ray, i IntersectionRecord, s Sampler) Ray {
+    return calculateDiffuseRay(i, s)
 }
 
 // GetAttenuation returns the diffuse attenuation
@@ -173,8 +172,8 @@ func (l Lambertian) IsEmissive() bool {
 }
 
 // Scatter for metal materials
-func (m Metal) Scatter(r Ray, i IntersectionRecord) Ray {
-    return calculateReflectionRay(r, i, m.Fuzziness)
+func (m Metal) Scatter(r Ray, i IntersectionRecord, s Sampler) Ray {
+    return calculateReflectionRay(r, i, m.Fuzziness, s)
 }
 
 // GetAttenuation gets the metal attenuation
@@ -192,8 +191,8 @@ func (m Metal) IsEmissive() bool {
 }
 
 // Scatter refracts rays for dielectric materials
-func (d Dielectric) Scatter(r Ray, i IntersectionRecord) Ray {
-    return calculateRefractedRay(r, i, d.RefractiveIndex)
+func (d Dielectric) Scatter(r Ray, i IntersectionRecord, s Sampler) Ray {
+    return calculateRefractedRay(r, i, d.RefractiveIndex, s)
 }
 
 // GetAttenuation gets the dielectric attenuation
@@ -211,7 +210,7 @@ func (d Dielectric) IsEmissive() bool {
 }
 
 // Scatter does nothing for an emissive material
-func (e Emissive) Scatter(r Ray, i IntersectionRecord) Ray {
+func (e Emissive) Scatter(r Ray, i IntersectionRecord, s Sampler) Ray {
     return Ray{}
 }
 
@@ -247,6 +246,12 @@ func deserializeMaterial(object map[string]interface{}) (Material, bool) {
         if err := json.Unmarshal(b, &dielectric); err == nil {
             return dielectric, true
         }
+    // If Emission is in the object then it must be emissive
+    } else if nil != object["Emission"] {
+        var emissive Emissive
+        if err := json.Unmarshal(b, &emissive); err == nil {
+            return emissive, true
+        }
     }
     
     var lambert Lambertian
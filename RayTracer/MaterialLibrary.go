@@ -0,0 +1,134 @@
+package raytracer
+
+import
+(
+    "log"
+    "reflect"
+    "strings"
+)
+
+// inheritsField names the material a definition starts from
+const inheritsField = "Inherits"
+
+// MaterialDefinition is a named material in the scene's material library, it holds the same fields as an object's Properties.
+// If it has an Inherits field it starts with the fields of the material it names and its own fields override them, nested fields such as Attenuation.X can be overridden one at a time
+type MaterialDefinition map[string]interface{}
+
+// materialReference records which library material an object was given and the fields it overrides, so the scene can be exported with the reference
+type materialReference struct {
+    Material string
+    Overrides MaterialDefinition
+}
+
+// AddMaterial adds a material definition to the scene's material library, replacing any with the same name
+func (w *World) AddMaterial(name string, definition MaterialDefinition) {
+    if (nil == w.Materials) {
+        w.Materials = make(map[string]MaterialDefinition)
+    }
+
+    w.Materials[name] = definition
+}
+
+// materialObject is a scene object with a single material that can be given a library material
+type materialObject interface {
+    CollidableObject
+
+    // material returns the object's material
+    material() Material
+
+    // withMaterial returns the object with its material replaced
+    withMaterial(material Material) CollidableObject
+}
+
+// AddObjectWithMaterial adds a sphere or mesh whose material is the named library material with overrides replacing some of its fields, overrides may be nil
+func (w *World) AddObjectWithMaterial(name string, obj materialObject, material string, overrides MaterialDefinition) {
+    w.AddObject(name, obj.withMaterial(w.ResolveMaterial(material, overrides)))
+
+    if (nil == w.materialReferences) {
+        w.materialReferences = make(map[string]materialReference)
+    }
+    w.materialReferences[name] = materialReference {
+        Material: material,
+        Overrides: overrides }
+}
+
+// ResolveMaterial returns the named library material with overrides replacing some of its fields
+func (w World) ResolveMaterial(material string, overrides MaterialDefinition) Material {
+    fields := mergeMaterialFields(w.resolveMaterialFields(material, nil), overrides)
+
+    resolved, ok := deserializeMaterial(fields)
+    if (false == ok) {
+        log.Fatalf("Material %q is not a valid material", material)
+    }
+
+    return resolved
+}
+
+// resolveMaterialFields returns the fields of the named material with everything it inherits filled in.
+// inheriting lists the materials already being resolved so a cycle of inheritance is reported instead of recursing forever
+func (w World) resolveMaterialFields(material string, inheriting []string) map[string]interface{} {
+    for _, name := range inheriting {
+        if (name == material) {
+            log.Fatalf("Materials inherit from each other in a cycle: %v -> %v", strings.Join(inheriting, " -> "), material)
+        }
+    }
+
+    definition, exists := w.Materials[material]
+    if (false == exists) {
+        if (len(inheriting) > 0) {
+            log.Fatalf("Material %q inherits from %q which is not in the material library", inheriting[len(inheriting) - 1], material)
+        }
+        log.Fatalf("Material %q is not in the material library", material)
+    }
+
+    fields := map[string]interface{}{}
+    if parent, inherits := definition[inheritsField]; inherits {
+        parentName, ok := parent.(string)
+        if (false == ok) {
+            log.Fatalf("Material %q has an Inherits field that is not a material name", material)
+        }
+        fields = w.resolveMaterialFields(parentName, append(inheriting, material))
+    }
+
+    return mergeMaterialFields(fields, definition)
+}
+
+// mergeMaterialFields returns a copy of base with the fields of overrides replacing its own.
+// Nested objects are merged field by field and the Inherits field is left out
+func mergeMaterialFields(base map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+    merged := make(map[string]interface{}, len(base) + len(overrides))
+    for field, value := range base {
+        merged[field] = value
+    }
+
+    for field, value := range overrides {
+        if (field == inheritsField) {
+            continue
+        }
+
+        baseObject, baseIsObject := merged[field].(map[string]interface{})
+        overrideObject, overrideIsObject := value.(map[string]interface{})
+        if (baseIsObject && overrideIsObject) {
+            merged[field] = mergeMaterialFields(baseObject, overrideObject)
+        } else {
+            merged[field] = value
+        }
+    }
+
+    return merged
+}
+
+// objectMaterialReference returns the library material the named object uses.
+// It returns false if the object was not given a library material or its material has changed since, an animation may have changed its properties
+func (w World) objectMaterialReference(name string, obj materialObject) (materialReference, bool) {
+    reference, exists := w.materialReferences[name]
+    if (false == exists) {
+        return reference, false
+    }
+
+    if _, defined := w.Materials[reference.Material]; !defined {
+        return reference, false
+    }
+
+    return reference, reflect.DeepEqual(w.ResolveMaterial(reference.Material, reference.Overrides), obj.material())
+}
@@ -0,0 +1,244 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+)
+
+// triangleEpsilon is how far a triangle's bounding box is grown on every side so flat triangles still have a box rays can hit
+const triangleEpsilon = 1e-5
+
+// Mesh is a triangle mesh with one material.  Every three Indices are the corners of a triangle in Positions.
+// Normals and UVs are optional and have one entry per position, without normals the mesh is flat shaded and without UVs the texture coordinates are the barycentric coordinates
+type Mesh struct {
+    Positions []Vector3
+    Normals []Vector3 `json:",omitempty"`
+    UVs [][2]float32 `json:",omitempty"`
+    Indices []uint32
+    Properties Material
+
+    // Texture is multiplied with the material's attenuation at the hit's texture coordinates, nil for none
+    Texture *Texture `json:",omitempty"`
+
+    // hierarchy is the bounding volume hierarchy over the triangles, triangleIndices is the original index of each triangle in the hierarchy's order
+    hierarchy *bvhNode
+    triangles []CollidableObject
+    triangleIndices []int
+}
+
+// meshTriangle is a single triangle of a mesh
+type meshTriangle struct {
+    mesh *Mesh
+    first int
+}
+
+// checkMeshData returns an error if the indices, normals or texture coordinates of a mesh do not fit its positions
+func checkMeshData(positions, normals []Vector3, uvs [][2]float32, indices []uint32) error {
+    if (len(indices) % 3 != 0) {
+        return fmt.Errorf("Mesh has %v indices, it needs three for each triangle", len(indices))
+    }
+    for _, index := range indices {
+        if (int(index) >= len(positions)) {
+            return fmt.Errorf("Mesh index %v is past its %v positions", index, len(positions))
+        }
+    }
+    if (len(normals) > 0 && len(normals) != len(positions)) {
+        return fmt.Errorf("Mesh has %v normals for %v positions", len(normals), len(positions))
+    }
+    if (len(uvs) > 0 && len(uvs) != len(positions)) {
+        return fmt.Errorf("Mesh has %v texture coordinates for %v positions", len(uvs), len(positions))
+    }
+
+    return nil
+}
+
+// NewMesh creates a mesh and builds the hierarchy over its triangles
+func NewMesh(positions, normals []Vector3, uvs [][2]float32, indices []uint32, material Material, texture *Texture) *Mesh {
+    if err := checkMeshData(positions, normals, uvs, indices); err != nil {
+        log.Fatal(err)
+    }
+
+    m := &Mesh {
+        Positions: positions,
+        Normals: normals,
+        UVs: uvs,
+        Indices: indices,
+        Properties: material,
+        Texture: texture }
+
+    if (len(indices) == 0) {
+        return m
+    }
+
+    m.triangles = make([]CollidableObject, len(indices) / 3)
+    m.triangleIndices = make([]int, len(m.triangles))
+    for i := range m.triangles {
+        m.triangles[i] = meshTriangle{ mesh: m, first: i * 3 }
+        m.triangleIndices[i] = i
+    }
+    m.hierarchy = buildHierarchy(m.triangles, m.triangleIndices, 0)
+
+    return m
+}
+
+// TestIntersection finds the closest triangle of the mesh hit by the ray
+func (m *Mesh) TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionRecord) {
+    if (m.hierarchy == nil) {
+        return false, IntersectionRecord{}
+    }
+
+    hit, record := traverseHierarchy(m.hierarchy, m.triangles, m.triangleIndices, r, tMin, tMax, nil)
+    if (false == hit) {
+        return false, record
+    }
+
+    // Only refracting materials need to know which side of the surface was hit, everything else scatters off the side the ray came from
+    if _, refracts := m.Properties.(Dielectric); !refracts && record.Normal.Dot(r.Direction) > 0.0 {
+        record.Normal = record.Normal.Scale(-1.0)
+    }
+
+    record.Object = m
+    record.Material = m.Properties
+    if (m.Texture != nil) {
+        record.Material = texturedMaterial(m.Properties, m.Texture.Sample(record.U, record.V))
+    }
+
+    return true, record
+}
+
+// BoundingBox returns the box enclosing every triangle
+func (m *Mesh) BoundingBox() BoundingBox {
+    if (m.hierarchy == nil) {
+        return EmptyBoundingBox()
+    }
+
+    return m.hierarchy.bounds
+}
+
+// GoString prints the mesh data without the hierarchy, whose pointers would make RenderInputHash differ between runs
+func (m *Mesh) GoString() string {
+    var texture interface{}
+    if (m.Texture != nil) {
+        texture = *m.Texture
+    }
+
+    return fmt.Sprintf("Mesh{%#v, %#v, %#v, %#v, %#v, %#v}", m.Positions, m.Normals, m.UVs, m.Indices, m.Properties, texture)
+}
+
+// material returns the mesh's material
+func (m *Mesh) material() Material {
+    return m.Properties
+}
+
+// withMaterial returns a copy of the mesh with another material, the copy shares the triangles and hierarchy
+func (m *Mesh) withMaterial(material Material) CollidableObject {
+    copy := *m
+    copy.Properties = material
+    return &copy
+}
+
+// corners returns the positions of the triangle's corners
+func (t meshTriangle) corners() (Vector3, Vector3, Vector3) {
+    indices := t.mesh.Indices
+    return t.mesh.Positions[indices[t.first]], t.mesh.Positions[indices[t.first + 1]], t.mesh.Positions[indices[t.first + 2]]
+}
+
+// TestIntersection tests the ray against the triangle from either side using the Moller-Trumbore method
+func (t meshTriangle) TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionRecord) {
+    var record IntersectionRecord
+    p0, p1, p2 := t.corners()
+
+    edge1 := p1.Subtract(p0)
+    edge2 := p2.Subtract(p0)
+    pvec := r.Direction.Cross(edge2)
+    determinant := edge1.Dot(pvec)
+    if (float32(math.Abs(float64(determinant))) < 1e-12) {
+        return false, record
+    }
+
+    inverse := 1.0 / determinant
+    tvec := r.Origin.Subtract(p0)
+    b1 := tvec.Dot(pvec) * inverse
+    if (b1 < 0.0 || b1 > 1.0) {
+        return false, record
+    }
+
+    qvec := tvec.Cross(edge1)
+    b2 := r.Direction.Dot(qvec) * inverse
+    if (b2 < 0.0 || b1 + b2 > 1.0) {
+        return false, record
+    }
+
+    record.T = edge2.Dot(qvec) * inverse
+    if (record.T < tMin || record.T > tMax) {
+        return false, record
+    }
+
+    b0 := 1.0 - b1 - b2
+    record.Point = r.PointOnRay(record.T)
+
+    indices := t.mesh.Indices
+    i0, i1, i2 := indices[t.first], indices[t.first + 1], indices[t.first + 2]
+    if (len(t.mesh.Normals) > 0) {
+        normals := t.mesh.Normals
+        record.Normal = normals[i0].Scale(b0).Add(normals[i1].Scale(b1)).Add(normals[i2].Scale(b2)).UnitVector()
+    } else {
+        record.Normal = edge1.Cross(edge2).UnitVector()
+    }
+
+    if (len(t.mesh.UVs) > 0) {
+        uvs := t.mesh.UVs
+        record.U = uvs[i0][0] * b0 + uvs[i1][0] * b1 + uvs[i2][0] * b2
+        record.V = uvs[i0][1] * b0 + uvs[i1][1] * b1 + uvs[i2][1] * b2
+    } else {
+        record.U, record.V = b1, b2
+    }
+
+    return true, record
+}
+
+// BoundingBox returns the box around the triangle's corners
+func (t meshTriangle) BoundingBox() BoundingBox {
+    p0, p1, p2 := t.corners()
+    box := EmptyBoundingBox().AddPoint(p0).AddPoint(p1).AddPoint(p2)
+    padding := NewVector3(triangleEpsilon, triangleEpsilon, triangleEpsilon)
+    return BoundingBox {
+        Min: box.Min.Subtract(padding),
+        Max: box.Max.Add(padding) }
+}
+
+// meshJSON is the json form of a mesh with its material left as a json object
+type meshJSON struct {
+    Positions []Vector3
+    Normals []Vector3
+    UVs [][2]float32
+    Indices []uint32
+    Properties map[string]interface{}
+    Texture *Texture
+}
+
+// deserializeMesh creates a mesh from its json object form
+func deserializeMesh(object map[string]interface{}) (*Mesh, bool) {
+    b, err := json.Marshal(object)
+    checkError(err)
+
+    var mesh meshJSON
+    if err := json.Unmarshal(b, &mesh); err != nil {
+        return nil, false
+    }
+
+    material, ok := deserializeMaterial(mesh.Properties)
+    if (mesh.Properties == nil || false == ok) {
+        return nil, false
+    }
+
+    // Scene files come from users and render servers, so bad mesh data skips the mesh rather than stopping
+    if err := checkMeshData(mesh.Positions, mesh.Normals, mesh.UVs, mesh.Indices); err != nil {
+        return nil, false
+    }
+
+    return NewMesh(mesh.Positions, mesh.Normals, mesh.UVs, mesh.Indices, material, mesh.Texture), true
+}
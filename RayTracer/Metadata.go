@@ -0,0 +1,429 @@
+package raytracer
+
+import
+(
+    "bufio"
+    "bytes"
+    "compress/zlib"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "log"
+    "os"
+    "runtime"
+    "runtime/debug"
+    "strconv"
+    "text/tabwriter"
+    "time"
+)
+
+// pngSignature starts every png file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// maxPNGChunkLength is the largest chunk length the png format allows
+const maxPNGChunkLength = (1 << 31) - 1
+
+// Keywords of the png text chunks the render metadata is stored in, Software and Creation Time are the standard png keywords
+const (
+    softwareKeyword = "Software"
+    createdKeyword = "Creation Time"
+    configKeyword = "GoTracer Config"
+    cameraKeyword = "GoTracer Camera"
+    sceneKeyword = "GoTracer Scene"
+    sceneHashKeyword = "GoTracer Scene Hash"
+    inputHashKeyword = "GoTracer Input Hash"
+    seedKeyword = "GoTracer Seed"
+    samplesKeyword = "GoTracer Samples Per Pixel"
+    renderTimeKeyword = "GoTracer Render Seconds"
+    partialKeyword = "GoTracer Partial"
+)
+
+// RenderMetadata records what produced an image so it can be identified and rendered again
+type RenderMetadata struct {
+    // Software is the ray tracer's build, with its revision when the build recorded one
+    Software string
+    Created time.Time
+
+    // Config, Camera and Scene are the render inputs as json, in the same format as the input files.
+    // Scene is a scene document that refers to model files by their absolute path, images from before scene documents hold a scene file
+    Config, Camera, Scene json.RawMessage
+
+    // SceneHash is the sha256 of the scene json and InputHash is RenderInputHash of the config, scene and camera, both in hex
+    SceneHash, InputHash string
+
+    Seed uint64
+    SamplesPerPixel uint32
+    RenderTime time.Duration
+
+    // Partial is set when the render was stopped part way through a pass, some pixels then have more than SamplesPerPixel samples
+    Partial bool
+}
+
+// metadataInputs are the render inputs and their hashes as they are stored in an image
+type metadataInputs struct {
+    Config, Camera, Scene json.RawMessage
+    SceneHash, InputHash string
+}
+
+// currentMetadataInputs returns the global config, camera and scene as they are stored in an image.
+// The scene is stored as a scene document so the geometry of model files is referred to rather than copied into every image
+func currentMetadataInputs() *metadataInputs {
+    config, err := json.Marshal(Settings)
+    checkError(err)
+    camera, err := json.Marshal(cameraSettings)
+    checkError(err)
+    scene, err := json.Marshal(currentSceneDocument(""))
+    checkError(err)
+
+    sceneHash := sha256.Sum256(scene)
+    inputHash := RenderInputHash()
+
+    return &metadataInputs {
+        Config: config,
+        Camera: camera,
+        Scene: scene,
+        SceneHash: hex.EncodeToString(sceneHash[:]),
+        InputHash: hex.EncodeToString(inputHash[:]) }
+}
+
+// Metadata returns the metadata of the renderer's current image.
+// The inputs do not change while rendering, so they are serialized and hashed once rather than for every preview
+func (r *Renderer) Metadata() RenderMetadata {
+    if (nil == r.inputs) {
+        r.inputs = currentMetadataInputs()
+    }
+
+    return RenderMetadata {
+        Software: softwareVersion(),
+        Created: time.Now(),
+        Config: r.inputs.Config,
+        Camera: r.inputs.Camera,
+        Scene: r.inputs.Scene,
+        SceneHash: r.inputs.SceneHash,
+        InputHash: r.inputs.InputHash,
+        Seed: Settings.Seed,
+        SamplesPerPixel: r.SamplesPerPixel,
+        RenderTime: r.progress.elapsedTime(),
+        Partial: r.partialPass }
+}
+
+// softwareVersion describes the running build
+func softwareVersion() string {
+    version := "gotracer"
+
+    if info, ok := debug.ReadBuildInfo(); ok {
+        revision, modified := "", false
+        for _, setting := range info.Settings {
+            switch setting.Key {
+                case "vcs.revision":
+                    revision = setting.Value
+                case "vcs.modified":
+                    modified = setting.Value == "true"
+            }
+        }
+
+        if (revision != "") {
+            version += " " + revision
+            if (modified) {
+                version += "-dirty"
+            }
+        } else if (info.Main.Version != "" && info.Main.Version != "(devel)") {
+            version += " " + info.Main.Version
+        }
+    }
+
+    return version + " (" + runtime.Version() + ")"
+}
+
+// WritePNG encodes img as a png with the metadata in text chunks following the header.
+// Short values are written as tEXt chunks and the json inputs as compressed iTXt chunks
+func WritePNG(w io.Writer, img image.Image, metadata RenderMetadata) error {
+    var encoded bytes.Buffer
+    err := png.Encode(&encoded, img)
+    if (err != nil) {
+        return err
+    }
+
+    // The signature is followed by the IHDR chunk, which always has 13 bytes of data
+    headerEnd := len(pngSignature) + 12 + 13
+    contents := encoded.Bytes()
+
+    _, err = w.Write(contents[:headerEnd])
+    if (err != nil) {
+        return err
+    }
+
+    texts := []struct{ keyword, text string } {
+        { softwareKeyword, metadata.Software },
+        { createdKeyword, metadata.Created.Format(time.RFC1123Z) },
+        { sceneHashKeyword, metadata.SceneHash },
+        { inputHashKeyword, metadata.InputHash },
+        { seedKeyword, strconv.FormatUint(metadata.Seed, 10) },
+        { samplesKeyword, strconv.FormatUint(uint64(metadata.SamplesPerPixel), 10) },
+        { renderTimeKeyword, strconv.FormatFloat(metadata.RenderTime.Seconds(), 'f', 3, 64) } }
+    if (metadata.Partial) {
+        texts = append(texts, struct{ keyword, text string }{ partialKeyword, "true" })
+    }
+
+    for _, text := range texts {
+        err = writePNGChunk(w, "tEXt", []byte(text.keyword + "\x00" + text.text))
+        if (err != nil) {
+            return err
+        }
+    }
+
+    for _, text := range []struct{ keyword string; json []byte } {
+        { configKeyword, metadata.Config },
+        { cameraKeyword, metadata.Camera },
+        { sceneKeyword, metadata.Scene } } {
+        var compressed bytes.Buffer
+        compressor := zlib.NewWriter(&compressed)
+        compressor.Write(text.json)
+        compressor.Close()
+
+        // Keyword, compressed with zlib, and no language tag or translated keyword
+        data := append([]byte(text.keyword), 0, 1, 0, 0, 0)
+        err = writePNGChunk(w, "iTXt", append(data, compressed.Bytes()...))
+        if (err != nil) {
+            return err
+        }
+    }
+
+    _, err = w.Write(contents[headerEnd:])
+    return err
+}
+
+// writePNGChunk writes a png chunk with its length and checksum
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+    var header [8]byte
+    binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+    copy(header[4:], chunkType)
+
+    checksum := crc32.NewIEEE()
+    checksum.Write(header[4:])
+    checksum.Write(data)
+
+    var footer [4]byte
+    binary.BigEndian.PutUint32(footer[:], checksum.Sum32())
+
+    for _, b := range [][]byte{ header[:], data, footer[:] } {
+        if _, err := w.Write(b); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// ReadPNGMetadata reads the render metadata from the text chunks of a png.  It fails if the png was not written with WritePNG
+func ReadPNGMetadata(r io.Reader) (RenderMetadata, error) {
+    var metadata RenderMetadata
+    reader := bufio.NewReader(r)
+
+    signature := make([]byte, len(pngSignature))
+    if _, err := io.ReadFull(reader, signature); err != nil || string(signature) != pngSignature {
+        return metadata, errors.New("Not a png file")
+    }
+
+    texts := make(map[string]string)
+    for {
+        var header [8]byte
+        if _, err := io.ReadFull(reader, header[:]); err != nil {
+            return metadata, err
+        }
+
+        length := binary.BigEndian.Uint32(header[:4])
+        chunkType := string(header[4:])
+        if (length > maxPNGChunkLength) {
+            return metadata, fmt.Errorf("The png's %q chunk has a length of %v, past the png limit", chunkType, length)
+        }
+        if (chunkType == "IEND") {
+            break
+        }
+
+        // Skip the chunk and its checksum unless it holds text
+        if (chunkType != "tEXt" && chunkType != "zTXt" && chunkType != "iTXt") {
+            if _, err := reader.Discard(int(length) + 4); err != nil {
+                return metadata, err
+            }
+            continue
+        }
+
+        // The chunk is read as it arrives rather than allocated from its length, which a damaged file may get wrong
+        data, err := ioutil.ReadAll(io.LimitReader(reader, int64(length) + 4))
+        if (err != nil) {
+            return metadata, err
+        }
+        if (int64(len(data)) < int64(length) + 4) {
+            return metadata, io.ErrUnexpectedEOF
+        }
+
+        keyword, text, err := parsePNGText(chunkType, data[:length])
+        if (err != nil) {
+            return metadata, err
+        }
+        texts[keyword] = text
+    }
+
+    if _, ok := texts[configKeyword]; !ok {
+        return metadata, errors.New("The png has no render metadata")
+    }
+
+    metadata.Software = texts[softwareKeyword]
+    metadata.Config = json.RawMessage(texts[configKeyword])
+    metadata.Camera = json.RawMessage(texts[cameraKeyword])
+    metadata.Scene = json.RawMessage(texts[sceneKeyword])
+    metadata.SceneHash = texts[sceneHashKeyword]
+    metadata.InputHash = texts[inputHashKeyword]
+    metadata.Partial = texts[partialKeyword] == "true"
+
+    // A value that does not parse is left at zero, the json inputs are what matter for rendering again
+    metadata.Created, _ = time.Parse(time.RFC1123Z, texts[createdKeyword])
+    metadata.Seed, _ = strconv.ParseUint(texts[seedKeyword], 10, 64)
+    samples, _ := strconv.ParseUint(texts[samplesKeyword], 10, 32)
+    metadata.SamplesPerPixel = uint32(samples)
+    seconds, _ := strconv.ParseFloat(texts[renderTimeKeyword], 64)
+    metadata.RenderTime = time.Duration(seconds * float64(time.Second))
+
+    return metadata, nil
+}
+
+// parsePNGText returns the keyword and text of a tEXt, zTXt or iTXt chunk
+func parsePNGText(chunkType string, data []byte) (string, string, error) {
+    fields := bytes.SplitN(data, []byte{ 0 }, 2)
+    if (len(fields) != 2) {
+        return "", "", fmt.Errorf("Malformed %v chunk", chunkType)
+    }
+    keyword, rest := string(fields[0]), fields[1]
+
+    compressed := false
+    switch chunkType {
+        case "zTXt":
+            if (len(rest) < 1) {
+                return "", "", fmt.Errorf("Malformed %v chunk", chunkType)
+            }
+            compressed, rest = true, rest[1:]
+        case "iTXt":
+            // Compression flag and method, then the language tag and translated keyword which are not needed
+            if (len(rest) < 2) {
+                return "", "", fmt.Errorf("Malformed %v chunk", chunkType)
+            }
+            compressed = rest[0] == 1
+            fields = bytes.SplitN(rest[2:], []byte{ 0 }, 3)
+            if (len(fields) != 3) {
+                return "", "", fmt.Errorf("Malformed %v chunk", chunkType)
+            }
+            rest = fields[2]
+    }
+
+    if (false == compressed) {
+        return keyword, string(rest), nil
+    }
+
+    decompressor, err := zlib.NewReader(bytes.NewReader(rest))
+    if (err != nil) {
+        return "", "", err
+    }
+    text, err := ioutil.ReadAll(decompressor)
+    return keyword, string(text), err
+}
+
+// ReadImageMetadata reads the render metadata of a png file
+func ReadImageMetadata(filename string) (RenderMetadata, error) {
+    imageFile, err := os.Open(filename)
+    if (err != nil) {
+        return RenderMetadata{}, err
+    }
+    defer imageFile.Close()
+
+    return ReadPNGMetadata(imageFile)
+}
+
+// WriteSummary writes everything but the json inputs as a short table for people to read
+func (m RenderMetadata) WriteSummary(output io.Writer) {
+    table := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(table, "Software\t%v\n", m.Software)
+    fmt.Fprintf(table, "Created\t%v\n", m.Created.Format(time.RFC1123Z))
+    fmt.Fprintf(table, "Seed\t%v\n", m.Seed)
+    if (m.Partial) {
+        fmt.Fprintf(table, "Samples per pixel\t%v, stopped part way through the next pass\n", m.SamplesPerPixel)
+    } else {
+        fmt.Fprintf(table, "Samples per pixel\t%v\n", m.SamplesPerPixel)
+    }
+    fmt.Fprintf(table, "Render time\t%.2f s\n", m.RenderTime.Seconds())
+    fmt.Fprintf(table, "Scene hash\t%v\n", m.SceneHash)
+    fmt.Fprintf(table, "Input hash\t%v\n", m.InputHash)
+    table.Flush()
+}
+
+// ExportInputs writes the config, scene and camera json to the given files so the image can be rendered again, an empty filename skips that input
+func (m RenderMetadata) ExportInputs(configFilename, sceneFilename, cameraFilename string) {
+    for _, input := range []struct{ filename string; json json.RawMessage } {
+        { configFilename, m.Config },
+        { sceneFilename, m.Scene },
+        { cameraFilename, m.Camera } } {
+        if (input.filename == "") {
+            continue
+        }
+
+        var indented bytes.Buffer
+        err := json.Indent(&indented, input.json, "", "    ")
+        checkError(err)
+
+        inputFile, err := os.Create(input.filename)
+        checkError(err)
+        defer inputFile.Close()
+
+        inputFile.Write(indented.Bytes())
+    }
+}
+
+// ExportSceneDocument writes the config, scene and camera to filename as a single scene document
+func (m RenderMetadata) ExportSceneDocument(filename string) {
+    documentFile, err := os.Create(filename)
+    checkError(err)
+    defer documentFile.Close()
+
+    if (isSceneDocument(m.Scene)) {
+        var indented bytes.Buffer
+        err := json.Indent(&indented, m.Scene, "", "    ")
+        checkError(err)
+        documentFile.Write(indented.Bytes())
+        return
+    }
+
+    documentFile.Write(MigrateSceneJSON(m.Config, m.Scene, m.Camera))
+}
+
+// CheckInputs loads the json inputs as the current config, scene and camera and checks they hash to the image's input hash.
+// Model files the scene refers to that no longer exist are reported and the inputs do not match
+func (m RenderMetadata) CheckInputs() bool {
+    if (false == isSceneDocument(m.Scene)) {
+        ImportRenderInputs(m.Config, m.Scene, m.Camera)
+    } else {
+        var document sceneDocument
+        err := json.Unmarshal(m.Scene, &document)
+        checkError(err)
+        for _, model := range document.Models {
+            if _, err := os.Stat(model.File); err != nil {
+                log.Printf("Model file %v cannot be read, the stored inputs cannot be checked: %v", model.File, err)
+                return false
+            }
+        }
+
+        Settings = Config{}
+        cameraSettings = cameraConfig{}
+        ImportSceneDocumentJSON(m.Scene)
+    }
+
+    inputHash := RenderInputHash()
+    return hex.EncodeToString(inputHash[:]) == m.InputHash
+}
@@ -0,0 +1,58 @@
+package raytracer
+
+import
+(
+    "fmt"
+    "path/filepath"
+    "strings"
+)
+
+// loadedModel is what a model file adds to a scene, objects and cameras are named after the file and the part of it they came from
+type loadedModel struct {
+    Objects map[string]CollidableObject
+    Cameras map[string]cameraConfig
+}
+
+// defaultModelMaterial is the material of model geometry that is not given one, a light gray diffuse
+func defaultModelMaterial() Material {
+    gray := NewVector3(0.8, 0.8, 0.8)
+    return Lambertian{ Color: gray.AsColor(), Attenuation: gray }
+}
+
+// loadModel reads the model file named in a scene document by its extension, filename is the file's path.
+// PLY files have no materials of their own so they are given the model's material, or the default one
+func loadModel(model documentModel, filename string) (loadedModel, error) {
+    switch strings.ToLower(filepath.Ext(filename)) {
+        case ".gltf", ".glb":
+            return loadGLTF(filename)
+        case ".ply":
+            material := defaultModelMaterial()
+            if (model.Material != "") {
+                material = Scene.ResolveMaterial(model.Material, model.Properties)
+            } else if (model.Properties != nil) {
+                var ok bool
+                material, ok = deserializeMaterial(model.Properties)
+                if (false == ok) {
+                    return loadedModel{}, fmt.Errorf("%v: model has invalid Properties", filename)
+                }
+            }
+            return loadPLY(filename, material, model.PointRadius)
+    }
+
+    return loadedModel{}, fmt.Errorf("%v: unknown model format, models are .gltf, .glb or .ply files", filename)
+}
+
+// addModel records that the named objects came from a model file, an exported scene refers to the file instead of copying them
+func (w *World) addModel(model documentModel, names []string) {
+    absolute, err := filepath.Abs(model.File)
+    checkError(err)
+
+    model.File = absolute
+    w.models = append(w.models, model)
+    if (nil == w.modelObjects) {
+        w.modelObjects = make(map[string]bool)
+    }
+    for _, name := range names {
+        w.modelObjects[name] = true
+    }
+}
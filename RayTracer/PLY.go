@@ -0,0 +1,346 @@
+package raytracer
+
+import
+(
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// plyProperty is a property of a PLY element, list properties have a count before their values
+type plyProperty struct {
+    name string
+    valueType string
+    countType string
+    list bool
+}
+
+// plyElement is an element declared in a PLY header such as vertex or face
+type plyElement struct {
+    name string
+    count int
+    properties []plyProperty
+}
+
+// plyReader reads the values after a PLY header as ascii words or binary little endian numbers
+type plyReader struct {
+    reader *bufio.Reader
+    words *bufio.Scanner
+    binary bool
+}
+
+// plySizes are the sizes in bytes of the binary PLY types by both of their names
+var plySizes = map[string]int {
+    "char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+    "short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+    "int": 4, "int32": 4, "uint": 4, "uint32": 4,
+    "float": 4, "float32": 4, "double": 8, "float64": 8 }
+
+// ImportPLY adds the triangles or points of a PLY file to the global scene with material.
+// A file with faces becomes a mesh named after the file, such as scan/mesh, and one with only vertices a point cloud such as scan/points whose points without a radius have pointRadius
+func ImportPLY(filename string, material Material, pointRadius float32) {
+    model, err := loadPLY(filename, material, pointRadius)
+    checkError(err)
+
+    names := make([]string, 0, len(model.Objects))
+    for name, object := range model.Objects {
+        Scene.AddObject(name, object)
+        names = append(names, name)
+    }
+    Scene.addModel(documentModel{ File: filename, PointRadius: pointRadius }, names)
+    Scene.BuildHierarchy()
+}
+
+// loadPLY reads an ascii or binary little endian PLY file
+func loadPLY(filename string, material Material, pointRadius float32) (loadedModel, error) {
+    model := loadedModel {
+        Objects: make(map[string]CollidableObject),
+        Cameras: make(map[string]cameraConfig) }
+    prefix := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+    contents, err := ioutil.ReadFile(filename)
+    if (err != nil) {
+        return model, err
+    }
+
+    reader := bufio.NewReader(bytes.NewReader(contents))
+    elements, isBinary, err := readPLYHeader(reader)
+    if (err != nil) {
+        return model, fmt.Errorf("%v: %v", filename, err)
+    }
+
+    ply := plyReader {
+        reader: reader,
+        binary: isBinary }
+    if (false == isBinary) {
+        ply.words = bufio.NewScanner(reader)
+        ply.words.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+        ply.words.Split(bufio.ScanWords)
+    }
+
+    var positions, normals []Vector3
+    var uvs [][2]float32
+    var radii []float32
+    var colors []byte
+    var indices []uint32
+    var row [][]float64
+
+    for _, element := range elements {
+        // Each property's column, -1 when the element does not have it
+        column := func(names ...string) int {
+            for _, name := range names {
+                for i, property := range element.properties {
+                    if (property.name == name && false == property.list) {
+                        return i
+                    }
+                }
+            }
+            return -1
+        }
+
+        switch element.name {
+            case "vertex":
+                x, y, z := column("x"), column("y"), column("z")
+                if (x < 0 || y < 0 || z < 0) {
+                    return model, fmt.Errorf("%v: vertices have no x, y and z", filename)
+                }
+                nx, ny, nz := column("nx"), column("ny"), column("nz")
+                u, v := column("u", "s", "texture_u"), column("v", "t", "texture_v")
+                red, green, blue := column("red", "diffuse_red"), column("green", "diffuse_green"), column("blue", "diffuse_blue")
+                radius := column("radius")
+
+                for i := 0; i < element.count; i++ {
+                    row, err = ply.readRow(element, row)
+                    if (err != nil) {
+                        return model, fmt.Errorf("%v: vertex %v: %v", filename, i, err)
+                    }
+
+                    positions = append(positions, NewVector3(float32(row[x][0]), float32(row[y][0]), float32(row[z][0])))
+                    if (nx >= 0 && ny >= 0 && nz >= 0) {
+                        normals = append(normals, NewVector3(float32(row[nx][0]), float32(row[ny][0]), float32(row[nz][0])).UnitVector())
+                    }
+                    if (u >= 0 && v >= 0) {
+                        // PLY texture coordinates start at the bottom of the image
+                        uvs = append(uvs, [2]float32{ float32(row[u][0]), 1.0 - float32(row[v][0]) })
+                    }
+                    if (red >= 0 && green >= 0 && blue >= 0) {
+                        for _, c := range []int{ red, green, blue } {
+                            colors = append(colors, plyColorByte(row[c][0], element.properties[c].valueType))
+                        }
+                    }
+                    if (radius >= 0) {
+                        radii = append(radii, float32(row[radius][0]))
+                    }
+                }
+
+            case "face":
+                list := -1
+                for i, property := range element.properties {
+                    if (property.list && (property.name == "vertex_indices" || property.name == "vertex_index")) {
+                        list = i
+                    }
+                }
+                if (list < 0) {
+                    return model, fmt.Errorf("%v: faces have no vertex_indices", filename)
+                }
+
+                for i := 0; i < element.count; i++ {
+                    row, err = ply.readRow(element, row)
+                    if (err != nil) {
+                        return model, fmt.Errorf("%v: face %v: %v", filename, i, err)
+                    }
+
+                    // Faces with more than three corners are split into a fan of triangles
+                    corners := row[list]
+                    for c := 2; c < len(corners); c++ {
+                        for _, corner := range []float64{ corners[0], corners[c - 1], corners[c] } {
+                            if (corner < 0 || int(corner) >= len(positions)) {
+                                return model, fmt.Errorf("%v: face %v refers to vertex %v which does not exist", filename, i, corner)
+                            }
+                            indices = append(indices, uint32(corner))
+                        }
+                    }
+                }
+
+            default:
+                for i := 0; i < element.count; i++ {
+                    if row, err = ply.readRow(element, row); err != nil {
+                        return model, fmt.Errorf("%v: %v %v: %v", filename, element.name, i, err)
+                    }
+                }
+        }
+    }
+
+    if (len(indices) > 0) {
+        if err := checkMeshData(positions, normals, uvs, indices); err != nil {
+            return model, fmt.Errorf("%v: %v", filename, err)
+        }
+        model.Objects[prefix + "/mesh"] = NewMesh(positions, normals, uvs, indices, material, nil)
+    } else {
+        if (len(radii) == 0 && pointRadius <= 0.0) {
+            return model, fmt.Errorf("%v: points have no radius and no point radius was given", filename)
+        }
+        if err := checkPointCloudData(positions, radii, pointRadius, colors); err != nil {
+            return model, fmt.Errorf("%v: %v", filename, err)
+        }
+        model.Objects[prefix + "/points"] = NewPointCloud(positions, radii, pointRadius, colors, material)
+    }
+
+    return model, nil
+}
+
+// readPLYHeader reads the header of a PLY file and returns its elements and whether the data is binary
+func readPLYHeader(reader *bufio.Reader) ([]plyElement, bool, error) {
+    var elements []plyElement
+    format := ""
+
+    for lineNumber := 1; ; lineNumber++ {
+        line, err := reader.ReadString('\n')
+        if (err != nil) {
+            return nil, false, fmt.Errorf("header has no end_header")
+        }
+
+        fields := strings.Fields(line)
+        if (lineNumber == 1) {
+            if (len(fields) != 1 || fields[0] != "ply") {
+                return nil, false, fmt.Errorf("not a PLY file")
+            }
+            continue
+        }
+        if (len(fields) == 0) {
+            continue
+        }
+
+        switch fields[0] {
+            case "format":
+                if (len(fields) < 2) {
+                    return nil, false, fmt.Errorf("line %v: format has no type", lineNumber)
+                }
+                format = fields[1]
+                if (format != "ascii" && format != "binary_little_endian") {
+                    return nil, false, fmt.Errorf("format %v is not supported, only ascii and binary_little_endian are", format)
+                }
+
+            case "element":
+                if (len(fields) != 3) {
+                    return nil, false, fmt.Errorf("line %v: element needs a name and a count", lineNumber)
+                }
+                count, err := strconv.Atoi(fields[2])
+                if (err != nil || count < 0) {
+                    return nil, false, fmt.Errorf("line %v: element count %q is not a number", lineNumber, fields[2])
+                }
+                elements = append(elements, plyElement{ name: fields[1], count: count })
+
+            case "property":
+                if (len(elements) == 0) {
+                    return nil, false, fmt.Errorf("line %v: property before any element", lineNumber)
+                }
+
+                var property plyProperty
+                if (len(fields) == 5 && fields[1] == "list") {
+                    property = plyProperty{ name: fields[4], valueType: fields[3], countType: fields[2], list: true }
+                } else if (len(fields) == 3) {
+                    property = plyProperty{ name: fields[2], valueType: fields[1] }
+                } else {
+                    return nil, false, fmt.Errorf("line %v: property needs a type and a name", lineNumber)
+                }
+
+                for _, valueType := range []string{ property.valueType, property.countType } {
+                    if _, known := plySizes[valueType]; valueType != "" && false == known {
+                        return nil, false, fmt.Errorf("line %v: unknown type %q", lineNumber, valueType)
+                    }
+                }
+
+                element := &elements[len(elements) - 1]
+                element.properties = append(element.properties, property)
+
+            case "end_header":
+                if (format == "") {
+                    return nil, false, fmt.Errorf("header has no format")
+                }
+                return elements, format == "binary_little_endian", nil
+        }
+    }
+}
+
+// readRow reads the values of every property of one element into row, non list properties have a single value.
+// row is reused between calls so millions of points do not allocate a row each, it may be nil for the first
+func (p *plyReader) readRow(element plyElement, row [][]float64) ([][]float64, error) {
+    if (len(row) != len(element.properties)) {
+        row = make([][]float64, len(element.properties))
+    }
+    for i, property := range element.properties {
+        count := 1
+        if (property.list) {
+            value, err := p.value(property.countType)
+            if (err != nil) {
+                return nil, err
+            }
+            count = int(value)
+            if (count < 0) {
+                return nil, fmt.Errorf("%v has a negative count", property.name)
+            }
+        }
+
+        row[i] = row[i][:0]
+        for c := 0; c < count; c++ {
+            value, err := p.value(property.valueType)
+            if (err != nil) {
+                return nil, err
+            }
+            row[i] = append(row[i], value)
+        }
+    }
+
+    return row, nil
+}
+
+// value reads one number of the given PLY type
+func (p *plyReader) value(valueType string) (float64, error) {
+    if (false == p.binary) {
+        if (false == p.words.Scan()) {
+            return 0.0, io.ErrUnexpectedEOF
+        }
+        return strconv.ParseFloat(p.words.Text(), 64)
+    }
+
+    var data [8]byte
+    if _, err := io.ReadFull(p.reader, data[:plySizes[valueType]]); err != nil {
+        return 0.0, io.ErrUnexpectedEOF
+    }
+
+    switch valueType {
+        case "char", "int8":
+            return float64(int8(data[0])), nil
+        case "uchar", "uint8":
+            return float64(data[0]), nil
+        case "short", "int16":
+            return float64(int16(binary.LittleEndian.Uint16(data[:]))), nil
+        case "ushort", "uint16":
+            return float64(binary.LittleEndian.Uint16(data[:])), nil
+        case "int", "int32":
+            return float64(int32(binary.LittleEndian.Uint32(data[:]))), nil
+        case "uint", "uint32":
+            return float64(binary.LittleEndian.Uint32(data[:])), nil
+        case "float", "float32":
+            return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[:]))), nil
+    }
+
+    return math.Float64frombits(binary.LittleEndian.Uint64(data[:])), nil
+}
+
+// plyColorByte converts a color value to a byte, floating point colors go from 0 to 1 and integer ones from 0 to 255
+func plyColorByte(value float64, valueType string) byte {
+    if (valueType == "float" || valueType == "float32" || valueType == "double" || valueType == "float64") {
+        value *= 255.0
+    }
+
+    return byte(math.Max(0.0, math.Min(255.0, math.Round(value))))
+}
@@ -0,0 +1,284 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+)
+
+// PointCloud is a large number of small spheres stored compactly, as scanned data has millions of points.
+// Each point has a position, an optional radius and an optional red, green and blue color that is multiplied with the material's attenuation
+type PointCloud struct {
+    Positions []Vector3
+
+    // Radii has one radius per point, without it every point has Radius
+    Radii []float32 `json:",omitempty"`
+    Radius float32
+
+    // Colors holds three bytes of red, green and blue per point, without it every point has the plain material
+    Colors []byte `json:",omitempty"`
+    Properties Material
+
+    // hierarchy is the bounding volume hierarchy over the points, its leaves are ranges of order which holds point indices
+    hierarchy *bvhNode
+    order []uint32
+}
+
+// checkPointCloudData returns an error if the radii or colors of a point cloud do not fit its points
+func checkPointCloudData(positions []Vector3, radii []float32, radius float32, colors []byte) error {
+    if (len(radii) > 0 && len(radii) != len(positions)) {
+        return fmt.Errorf("Point cloud has %v radii for %v points", len(radii), len(positions))
+    }
+    if (len(colors) > 0 && len(colors) != len(positions) * 3) {
+        return fmt.Errorf("Point cloud has %v color bytes for %v points, it needs three per point", len(colors), len(positions))
+    }
+    if (len(radii) == 0 && radius <= 0.0) {
+        return fmt.Errorf("Point cloud has no radii and a point radius of %v", radius)
+    }
+
+    return nil
+}
+
+// NewPointCloud creates a point cloud and builds the hierarchy over its points
+func NewPointCloud(positions []Vector3, radii []float32, radius float32, colors []byte, material Material) *PointCloud {
+    if err := checkPointCloudData(positions, radii, radius, colors); err != nil {
+        log.Fatal(err)
+    }
+
+    p := &PointCloud {
+        Positions: positions,
+        Radii: radii,
+        Radius: radius,
+        Colors: colors,
+        Properties: material }
+
+    if (len(positions) == 0) {
+        return p
+    }
+
+    p.order = make([]uint32, len(positions))
+    for i := range p.order {
+        p.order[i] = uint32(i)
+    }
+    p.hierarchy = p.buildHierarchy(p.order, 0)
+
+    return p
+}
+
+// radius returns the radius of a point
+func (p *PointCloud) radius(point uint32) float32 {
+    if (len(p.Radii) > 0) {
+        return p.Radii[point]
+    }
+
+    return p.Radius
+}
+
+// pointBounds returns the box around a point
+func (p *PointCloud) pointBounds(point uint32) BoundingBox {
+    radius := p.radius(point)
+    r := NewVector3(radius, radius, radius)
+    return BoundingBox {
+        Min: p.Positions[point].Subtract(r),
+        Max: p.Positions[point].Add(r) }
+}
+
+// buildHierarchy builds the hierarchy over the points in order the same way buildHierarchy does for objects, reordering order so every leaf covers a contiguous range
+func (p *PointCloud) buildHierarchy(order []uint32, first int) *bvhNode {
+    node := &bvhNode {
+        bounds: EmptyBoundingBox(),
+        first: first,
+        count: len(order) }
+
+    if (len(order) <= maxObjectsPerLeaf) {
+        for _, point := range order {
+            node.bounds = node.bounds.Union(p.pointBounds(point))
+        }
+        return node
+    }
+
+    // Only leaves look at the radii, the bounds of other nodes are the union of their children's
+    centroids := EmptyBoundingBox()
+    for _, point := range order {
+        centroids = centroids.AddPoint(p.Positions[point])
+    }
+
+    // Split at the median point along the axis the points are most spread out on, only the median has to be found rather than sorting every level
+    axis := centroids.LargestAxis()
+    middle := len(order) / 2
+    p.selectMedian(order, middle, axis)
+
+    node.left = p.buildHierarchy(order[:middle], first)
+    node.right = p.buildHierarchy(order[middle:], first + middle)
+    node.bounds = node.left.bounds.Union(node.right.bounds)
+    node.count = 0
+    return node
+}
+
+// selectMedian reorders order so the point at middle is the one that would be there if they were sorted along axis, with no point before it further along and none after it less far
+func (p *PointCloud) selectMedian(order []uint32, middle int, axis int) {
+    position := func(i int) float32 {
+        return component(p.Positions[order[i]], axis)
+    }
+
+    low, high := 0, len(order) - 1
+    for low < high {
+        pivot := position(low + (high - low) / 2)
+        i, j := low, high
+        for i <= j {
+            for position(i) < pivot {
+                i++
+            }
+            for position(j) > pivot {
+                j--
+            }
+            if (i <= j) {
+                order[i], order[j] = order[j], order[i]
+                i++
+                j--
+            }
+        }
+
+        if (middle <= j) {
+            high = j
+        } else if (middle >= i) {
+            low = i
+        } else {
+            return
+        }
+    }
+}
+
+// TestIntersection finds the closest point hit by the ray
+func (p *PointCloud) TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionRecord) {
+    var record IntersectionRecord
+    if (p.hierarchy == nil) {
+        return false, record
+    }
+
+    closest := -1
+    closestT := tMax
+
+    stack := make([]*bvhNode, 0, 64)
+    stack = append(stack, p.hierarchy)
+
+    for len(stack) > 0 {
+        node := stack[len(stack) - 1]
+        stack = stack[:len(stack) - 1]
+
+        if (false == node.bounds.TestIntersection(r, tMin, closestT)) {
+            continue
+        }
+
+        if (node.left == nil) {
+            for _, point := range p.order[node.first:node.first + node.count] {
+                if t, hit := intersectSphere(p.Positions[point], p.radius(point), r, tMin, closestT); hit {
+                    closest = int(point)
+                    closestT = t
+                }
+            }
+
+            continue
+        }
+
+        stack = append(stack, node.right, node.left)
+    }
+
+    if (closest < 0) {
+        return false, record
+    }
+
+    record.T = closestT
+    record.Point = r.PointOnRay(closestT)
+    record.Normal = record.Point.Subtract(p.Positions[closest]).UnitVector()
+    record.U, record.V = sphereUV(record.Normal)
+    record.Object = p
+    record.Material = p.Properties
+    if (len(p.Colors) > 0) {
+        colors := p.Colors[closest * 3:closest * 3 + 3]
+        record.Material = texturedMaterial(p.Properties, NewVector3(float32(colors[0]) / 255.0, float32(colors[1]) / 255.0, float32(colors[2]) / 255.0))
+    }
+
+    return true, record
+}
+
+// intersectSphere returns the distance along the ray to a sphere, it is Sphere's test without making a Sphere for every point
+func intersectSphere(center Vector3, radius float32, r Ray, tMin, tMax float32) (float32, bool) {
+    m := r.Origin.Subtract(center)
+    b := m.Dot(r.Direction)
+    c := m.Dot(m) - (radius * radius)
+    if (c > 0.0 && b > 0.0) {
+        return 0.0, false
+    }
+
+    descriminant := (b * b) - c
+    if (descriminant < 0.0) {
+        return 0.0, false
+    }
+
+    t := float32(float64(-b) - math.Sqrt(float64(descriminant)))
+    if (t < tMin || t > tMax) {
+        return 0.0, false
+    }
+
+    return t, true
+}
+
+// BoundingBox returns the box enclosing every point
+func (p *PointCloud) BoundingBox() BoundingBox {
+    if (p.hierarchy == nil) {
+        return EmptyBoundingBox()
+    }
+
+    return p.hierarchy.bounds
+}
+
+// GoString prints the point data without the hierarchy, whose pointers would make RenderInputHash differ between runs
+func (p *PointCloud) GoString() string {
+    return fmt.Sprintf("PointCloud{%#v, %#v, %#v, %#v, %#v}", p.Positions, p.Radii, p.Radius, p.Colors, p.Properties)
+}
+
+// material returns the point cloud's material
+func (p *PointCloud) material() Material {
+    return p.Properties
+}
+
+// withMaterial returns a copy of the point cloud with another material, the copy shares the points and hierarchy
+func (p *PointCloud) withMaterial(material Material) CollidableObject {
+    copy := *p
+    copy.Properties = material
+    return &copy
+}
+
+// pointCloudJSON is the json form of a point cloud with its material left as a json object
+type pointCloudJSON struct {
+    Positions []Vector3
+    Radii []float32
+    Radius float32
+    Colors []byte
+    Properties map[string]interface{}
+}
+
+// deserializePointCloud creates a point cloud from its json object form
+func deserializePointCloud(object map[string]interface{}) (*PointCloud, bool) {
+    b, err := json.Marshal(object)
+    checkError(err)
+
+    var points pointCloudJSON
+    if err := json.Unmarshal(b, &points); err != nil {
+        return nil, false
+    }
+
+    material, ok := deserializeMaterial(points.Properties)
+    if (points.Properties == nil || false == ok) {
+        return nil, false
+    }
+
+    if err := checkPointCloudData(points.Positions, points.Radii, points.Radius, points.Colors); err != nil {
+        return nil, false
+    }
+
+    return NewPointCloud(points.Positions, points.Radii, points.Radius, points.Colors, material), true
+}
@@ -0,0 +1,332 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ProgressReport is a snapshot of how far a render has got
+type ProgressReport struct {
+    // TilesDone of Tiles are finished.  Tiles is 0 when the render has a time limit instead of a sample count
+    TilesDone, Tiles int
+
+    // SamplesDone of Samples pixel samples are finished
+    SamplesDone, Samples uint64
+    SamplesPerSecond float64
+
+    // RaysTraced counts every ray tested against the scene, camera, bounce and shadow rays alike
+    RaysTraced uint64
+    RaysPerSecond float64
+
+    // Elapsed is the time since the render started and Remaining the estimated time until it finishes, or -1 if it is not known yet
+    Elapsed, Remaining time.Duration
+
+    // Done is set on the last report of a render
+    Done bool
+}
+
+// ProgressReporter is an interface for anything that shows or records render progress
+type ProgressReporter interface {
+    // Report is called as tiles finish, at most every progressInterval, and once more with Done set when the render ends
+    Report(report ProgressReport)
+}
+
+// ProgressFunc lets an ordinary function be used as a ProgressReporter
+type ProgressFunc func(report ProgressReport)
+
+// Report calls f
+func (f ProgressFunc) Report(report ProgressReport) {
+    f(report)
+}
+
+const (
+    // BarProgressType draws a progress bar with the estimated time remaining, redrawn in place on a terminal
+    BarProgressType = "bar"
+
+    // JSONProgressType writes every report as a line of json
+    JSONProgressType = "json"
+
+    // NoProgressType reports nothing
+    NoProgressType = "none"
+)
+
+// progressInterval is the least time between reports, except for the last one
+const progressInterval = 250 * time.Millisecond
+
+// progressBarWidth is the number of characters in the bar
+const progressBarWidth = 30
+
+// BarProgress draws a progress bar to Output, redrawing it in place
+type BarProgress struct {
+    Output io.Writer
+
+    mutex sync.Mutex
+    // lineWidth is the length of the bar on the current line, 0 when the line is empty
+    lineWidth int
+}
+
+// JSONProgress writes reports to Output as json lines
+type JSONProgress struct {
+    Output io.Writer
+}
+
+// CreateProgressReporter creates the reporter named by progressType writing to output, none returns nil
+func CreateProgressReporter(progressType string, output io.Writer) ProgressReporter {
+    switch progressType {
+        case "", BarProgressType:
+            return &BarProgress{ Output: output }
+        case JSONProgressType:
+            return JSONProgress{ Output: output }
+        case NoProgressType:
+            return nil
+    }
+
+    log.Fatalf("Unknown progress type %q", progressType)
+    return nil
+}
+
+// Report redraws the bar over the previous one and ends the line once the render is done
+func (p *BarProgress) Report(report ProgressReport) {
+    fraction := report.Fraction()
+    filled := int(fraction * progressBarWidth)
+    bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth - filled)
+
+    eta := "ETA ?"
+    if (report.Done) {
+        eta = "took " + report.Elapsed.Round(time.Second / 10).String()
+    } else if (report.Remaining >= 0) {
+        eta = "ETA " + report.Remaining.Round(time.Second).String()
+    }
+
+    tiles := fmt.Sprintf("%v tiles", report.TilesDone)
+    if (report.Tiles > 0) {
+        tiles = fmt.Sprintf("%v/%v tiles", report.TilesDone, report.Tiles)
+    }
+
+    line := fmt.Sprintf("[%v] %3.0f%% %v, %v samples/s, %v rays, %v",
+        bar, fraction * 100.0, tiles, formatCount(report.SamplesPerSecond), formatCount(float64(report.RaysTraced)), eta)
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    p.clearLine()
+    fmt.Fprint(p.Output, line)
+    p.lineWidth = len(line)
+    if (report.Done) {
+        fmt.Fprintln(p.Output)
+        p.lineWidth = 0
+    }
+}
+
+// Printf prints a message on its own line, the bar is drawn again below it by the next report
+func (p *BarProgress) Printf(format string, args ...interface{}) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    p.clearLine()
+    fmt.Fprintf(p.Output, format, args...)
+}
+
+// clearLine blanks the bar so the next output starts at the beginning of an empty line, the caller must hold the mutex
+func (p *BarProgress) clearLine() {
+    if (p.lineWidth > 0) {
+        fmt.Fprintf(p.Output, "\r%v\r", strings.Repeat(" ", p.lineWidth))
+        p.lineWidth = 0
+    }
+}
+
+// jsonProgressReport is the json form of a report with times in seconds
+type jsonProgressReport struct {
+    TilesDone, Tiles int
+    SamplesDone, Samples uint64
+    SamplesPerSecond float64
+    RaysTraced uint64
+    RaysPerSecond float64
+    ElapsedSeconds float64
+    RemainingSeconds float64
+    Done bool
+}
+
+// Report writes the report as a single line of json.  RemainingSeconds is -1 when it is not known yet
+func (p JSONProgress) Report(report ProgressReport) {
+    remaining := -1.0
+    if (report.Remaining >= 0) {
+        remaining = report.Remaining.Seconds()
+    }
+
+    line, err := json.Marshal(jsonProgressReport {
+        TilesDone: report.TilesDone,
+        Tiles: report.Tiles,
+        SamplesDone: report.SamplesDone,
+        Samples: report.Samples,
+        SamplesPerSecond: report.SamplesPerSecond,
+        RaysTraced: report.RaysTraced,
+        RaysPerSecond: report.RaysPerSecond,
+        ElapsedSeconds: report.Elapsed.Seconds(),
+        RemainingSeconds: remaining,
+        Done: report.Done })
+    checkError(err)
+
+    fmt.Fprintf(p.Output, "%s\n", line)
+}
+
+// Fraction returns how much of the render is done in [0, 1]
+func (report ProgressReport) Fraction() float64 {
+    if (report.Samples > 0) {
+        return float64(report.SamplesDone) / float64(report.Samples)
+    }
+    if (report.Done) {
+        return 1.0
+    }
+    if (report.Remaining > 0) {
+        return report.Elapsed.Seconds() / (report.Elapsed + report.Remaining).Seconds()
+    }
+
+    return 0.0
+}
+
+// formatCount formats a large number with a k, M or G suffix
+func formatCount(count float64) string {
+    switch {
+        case count >= 1e9:
+            return fmt.Sprintf("%.1fG", count / 1e9)
+        case count >= 1e6:
+            return fmt.Sprintf("%.1fM", count / 1e6)
+        case count >= 1e3:
+            return fmt.Sprintf("%.1fk", count / 1e3)
+    }
+
+    return fmt.Sprintf("%.0f", count)
+}
+
+// renderCounters count the work done while rendering a tile.  Each tile has its own so counting never waits on other goroutines
+type renderCounters struct {
+    Rays uint64
+
+    // Statistics hold the detailed counts, nil unless the renderer collects statistics
+    Statistics *renderStatistics
+}
+
+// add adds the counts in other to c
+func (c *renderCounters) add(other renderCounters) {
+    c.Rays += other.Rays
+    if (other.Statistics != nil) {
+        if (c.Statistics == nil) {
+            c.Statistics = &renderStatistics{}
+        }
+        c.Statistics.add(other.Statistics)
+    }
+}
+
+// progressTracker adds up finished tiles and sends reports to a reporter
+type progressTracker struct {
+    mutex sync.Mutex
+    reporter ProgressReporter
+    active bool
+
+    start, lastReport time.Time
+    timeLimit time.Duration
+
+    tilesDone, tiles int
+    samplesDone, samples uint64
+    counters renderCounters
+
+    // elapsed is the length of the last render once it has ended
+    elapsed time.Duration
+}
+
+// begin starts tracking a render of the given number of tiles and pixel samples.
+// A render with a time limit instead of a sample count passes 0 for both and its remaining time comes from the limit
+func (p *progressTracker) begin(reporter ProgressReporter, tiles int, samples uint64, timeLimit time.Duration) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    p.reporter = reporter
+    p.active = true
+    p.start = time.Now()
+    p.lastReport = p.start
+    p.timeLimit = timeLimit
+    p.tilesDone, p.tiles = 0, tiles
+    p.samplesDone, p.samples = 0, samples
+    p.counters = renderCounters{}
+    p.elapsed = 0
+}
+
+// tileFinished records a finished tile and reports progress if the last report was long enough ago
+func (p *progressTracker) tileFinished(samples uint64, counters renderCounters) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    p.tilesDone++
+    p.samplesDone += samples
+    p.counters.add(counters)
+
+    if (p.reporter != nil && time.Since(p.lastReport) >= progressInterval) {
+        p.lastReport = time.Now()
+        p.reporter.Report(p.report(false))
+    }
+}
+
+// end sends the last report and stops tracking
+func (p *progressTracker) end() {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    if (p.reporter != nil) {
+        p.reporter.Report(p.report(true))
+    }
+    p.active = false
+    p.elapsed = time.Since(p.start)
+}
+
+// elapsedTime returns the length of the last render, or the time so far while one is running
+func (p *progressTracker) elapsedTime() time.Duration {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    if (p.active) {
+        return time.Since(p.start)
+    }
+
+    return p.elapsed
+}
+
+// report returns the current progress, the caller must hold the mutex
+func (p *progressTracker) report(done bool) ProgressReport {
+    elapsed := time.Since(p.start)
+    report := ProgressReport {
+        TilesDone: p.tilesDone,
+        Tiles: p.tiles,
+        SamplesDone: p.samplesDone,
+        Samples: p.samples,
+        RaysTraced: p.counters.Rays,
+        Elapsed: elapsed,
+        Remaining: -1,
+        Done: done }
+
+    if (elapsed > 0) {
+        report.SamplesPerSecond = float64(p.samplesDone) / elapsed.Seconds()
+        report.RaysPerSecond = float64(p.counters.Rays) / elapsed.Seconds()
+    }
+
+    switch {
+        case done:
+            report.Remaining = 0
+        case p.samples > 0 && p.samplesDone > 0:
+            remainingFraction := float64(p.samples - p.samplesDone) / float64(p.samplesDone)
+            report.Remaining = time.Duration(float64(elapsed) * remainingFraction)
+        case p.timeLimit > 0:
+            report.Remaining = p.timeLimit - elapsed
+            if (report.Remaining < 0) {
+                report.Remaining = 0
+            }
+    }
+
+    return report
+}
@@ -0,0 +1,40 @@
+package raytracer
+
+// RandomStream is a small PCG32 random number generator.
+// Every pixel sample seeds its own stream so results do not depend on which goroutine traced it or in what order
+type RandomStream struct {
+    state uint64
+    increment uint64
+}
+
+const pcgMultiplier = 6364136223846793005
+
+// NewRandomStream creates a random stream from a seed and a stream selector, different selectors give independent sequences for the same seed
+func NewRandomStream(seed, stream uint64) RandomStream {
+    var r RandomStream
+    r.Seed(seed, stream)
+    return r
+}
+
+// Seed resets the stream to the start of the sequence selected by seed and stream
+func (r *RandomStream) Seed(seed, stream uint64) {
+    r.state = 0
+    r.increment = (stream << 1) | 1
+    r.Uint32()
+    r.state += seed
+    r.Uint32()
+}
+
+// Uint32 returns the next 32 random bits
+func (r *RandomStream) Uint32() uint32 {
+    old := r.state
+    r.state = old * pcgMultiplier + r.increment
+    xorShifted := uint32(((old >> 18) ^ old) >> 27)
+    rotation := uint32(old >> 59)
+    return (xorShifted >> rotation) | (xorShifted << ((-rotation) & 31))
+}
+
+// Float32 returns the next random number in [0, 1)
+func (r *RandomStream) Float32() float32 {
+    return hashToFloat(r.Uint32())
+}
@@ -3,6 +3,9 @@ package raytracer
 // Ray is a mathematical ray having a starting point and direction vector
 type Ray struct {
     Origin, Direction Vector3
+
+    // Time is when the ray was taken as a fraction of the time the shutter is open
+    Time float32
 }
 
 // PointOnRay will get a point on the ray at time t Origin + (t * Direction)
@@ -0,0 +1,306 @@
+package raytracer
+
+import
+(
+    "context"
+    "fmt"
+    "image"
+    "runtime"
+    "sync"
+    "time"
+)
+
+// defaultTileSize is the width and height in pixels of the tiles a pass is split into
+const defaultTileSize = 16
+
+// Renderer traces the global scene from the global camera into a film one pass of samples at a time
+type Renderer struct {
+    Film *Film
+    Integrator Integrator
+    Sampler Sampler
+
+    // Region is the part of the film being rendered, samples are also taken around it as far as the filter reaches
+    Region image.Rectangle
+
+    // Progress receives reports as tiles finish, nil for none
+    Progress ProgressReporter
+
+    // TileSize is the width and height in pixels of the tiles each pass is split into.  Defaults to 16
+    TileSize int
+
+    // CollectStatistics counts rays, intersection tests and tile times for Statistics, it slows the render down a little
+    CollectStatistics bool
+
+    // SamplesPerPixel is the number of samples per pixel rendered so far, the next pass starts at this sample index
+    SamplesPerPixel uint32
+
+    // partialPass is true once a pass was stopped part way, the film then holds more samples for some pixels than SamplesPerPixel
+    partialPass bool
+
+    // progress adds up the tiles finished by the current render
+    progress progressTracker
+
+    // inputs are the render inputs as stored in the image metadata, worked out the first time they are needed
+    inputs *metadataInputs
+}
+
+// IncompleteRenderError is returned when a render is cancelled or runs past its deadline before it finishes.
+// The film still holds everything rendered so far, so the image can be written out
+type IncompleteRenderError struct {
+    // Err is the context's error, context.Canceled or context.DeadlineExceeded
+    Err error
+
+    // SamplesPerPixel is the number of samples per pixel in every pixel of the region
+    SamplesPerPixel uint32
+
+    // PixelsRendered of Pixels had the samples of the interrupted pass added
+    PixelsRendered, Pixels int
+}
+
+// Error describes how much was rendered before the render stopped
+func (e *IncompleteRenderError) Error() string {
+    return fmt.Sprintf("Render stopped (%v) with %v samples per pixel and %v of %v pixels of the next pass",
+        e.Err, e.SamplesPerPixel, e.PixelsRendered, e.Pixels)
+}
+
+// Unwrap returns the context's error so errors.Is can check for context.Canceled or context.DeadlineExceeded
+func (e *IncompleteRenderError) Unwrap() error {
+    return e.Err
+}
+
+// ProgressiveOptions control how RenderProgressive splits the render into passes and when it stops
+type ProgressiveOptions struct {
+    // SamplesPerPass is the number of samples per pixel added by each pass.  Defaults to 1
+    SamplesPerPass uint32
+
+    // TargetSamples stops the render once this many samples per pixel are done, 0 for no limit
+    TargetSamples uint32
+
+    // TimeLimit stops the render once a pass finishes after this much time, 0 for no limit
+    TimeLimit time.Duration
+
+    // PreviewInterval and PreviewPasses call OnPreview after this much time or this many passes since the last preview, 0 turns each off
+    PreviewInterval time.Duration
+    PreviewPasses int
+
+    // OnPreview is called with the renderer between passes and once more when the render stops
+    OnPreview func(r *Renderer)
+
+    // CheckpointInterval is the time between calls to OnCheckpoint, 0 only calls it when the render stops
+    CheckpointInterval time.Duration
+
+    // OnCheckpoint is called with the renderer every CheckpointInterval and once more when the render stops after a whole pass
+    OnCheckpoint func(r *Renderer)
+}
+
+// CreateRenderer creates a renderer with an empty film and the sampler, integrator and crop window chosen by the current config
+func CreateRenderer() *Renderer {
+    return &Renderer {
+        Film: CreateFilmFromSettings(),
+        Integrator: CreateIntegrator(Settings.Integrator),
+        Sampler: CreateSampler(Settings.Sampler, Settings.MaxAntialiasRays, Settings.Seed),
+        Region: Settings.CropWindow.Bounds(Settings.WidthInPixels, Settings.HeightInPixels) }
+}
+
+// Image develops the rendered region, cropped or in the full frame as chosen by the config's CropOutput
+func (r *Renderer) Image() *image.RGBA {
+    if (r.Region == image.Rect(0, 0, r.Film.Width, r.Film.Height)) {
+        return r.Film.Image()
+    }
+
+    return r.Film.RegionImage(r.Region, Settings.CropOutput)
+}
+
+// RenderPass adds samples more samples to every pixel, splitting the image into tiles that are traced on every CPU.
+// Sample values only depend on the pixel and sample index, so a region renders exactly the samples a full render would.
+// If ctx is cancelled or its deadline passes the tiles stop at the next pixel and an IncompleteRenderError is returned
+func (r *Renderer) RenderPass(ctx context.Context, samples uint32) error {
+    bounds := r.Film.sampleBounds(r.Region)
+    if (false == r.progress.active) {
+        r.progress.begin(r.Progress, len(splitTiles(bounds, r.tileSize())), uint64(bounds.Dx() * bounds.Dy()) * uint64(samples), 0)
+        defer r.progress.end()
+    }
+
+    pixels := r.renderPixels(ctx, bounds, r.SamplesPerPixel, samples)
+    if (pixels < bounds.Dx() * bounds.Dy()) {
+        r.partialPass = true
+        return &IncompleteRenderError {
+            Err: ctx.Err(),
+            SamplesPerPixel: r.SamplesPerPixel,
+            PixelsRendered: pixels,
+            Pixels: bounds.Dx() * bounds.Dy() }
+    }
+
+    r.SamplesPerPixel += samples
+    return nil
+}
+
+// tileSize returns the size of the tiles passes are split into
+func (r *Renderer) tileSize() int {
+    if (r.TileSize <= 0) {
+        return defaultTileSize
+    }
+
+    return r.TileSize
+}
+
+// renderPixels takes samples firstSample to firstSample + samples of every pixel in bounds.
+// The pixels are split into tiles that one goroutine per CPU takes in turn, it returns the number of pixels finished before ctx was done
+func (r *Renderer) renderPixels(ctx context.Context, bounds image.Rectangle, firstSample, samples uint32) int {
+    tiles := make(chan image.Rectangle)
+    go func() {
+        defer close(tiles)
+        for _, tile := range splitTiles(bounds, r.tileSize()) {
+            tiles <- tile
+        }
+    }()
+
+    var group sync.WaitGroup
+    var mutex sync.Mutex
+    pixels := 0
+
+    for worker := 0; worker < runtime.NumCPU(); worker++ {
+        group.Add(1)
+        go func(sampler Sampler) {
+            defer group.Done()
+
+            for tile := range tiles {
+                // Each tile counts its own work so tiles never wait on each other to count a ray
+                var counters renderCounters
+                world := Scene
+                world.counters = &counters
+                if (r.CollectStatistics) {
+                    counters.Statistics = newRenderStatistics(len(Scene.Scene.objects))
+                }
+
+                tileStart := time.Now()
+                tilePixels := 0
+                for y := tile.Min.Y; y < tile.Max.Y; y++ {
+                    tilePixels += r.renderScanLine(ctx, sampler, world, y, tile.Min.X, tile.Max.X, firstSample, samples)
+                }
+
+                mutex.Lock()
+                pixels += tilePixels
+                mutex.Unlock()
+
+                if (tilePixels == tile.Dx() * tile.Dy()) {
+                    if (counters.Statistics != nil) {
+                        counters.Statistics.TileTimes = []tileTime{ { Tile: tile, Duration: time.Since(tileStart) } }
+                    }
+                    r.progress.tileFinished(uint64(tilePixels) * uint64(samples), counters)
+                }
+            }
+        }(r.Sampler.Clone())
+    }
+
+    group.Wait()
+    return pixels
+}
+
+// renderScanLine will perform ray tracing for pixels minX to maxX of a single line of the image.
+// It returns the number of pixels finished, stopping early when ctx is done
+func (r *Renderer) renderScanLine(ctx context.Context, sampler Sampler, world World, y, minX, maxX int, firstSample, samples uint32) int {
+    width := float32(r.Film.Width)
+    height := float32(r.Film.Height)
+
+    for x := minX; x < maxX; x++ {
+        if (ctx.Err() != nil) {
+            return x - minX
+        }
+
+        sampler.StartPixel(x, y)
+
+        for s := firstSample; s < firstSample + samples; s++ {
+            sampler.StartSample(s)
+            jitterX, jitterY := sampler.Get2D()
+            filmX := float32(x) + jitterX
+            filmY := float32(y) + jitterY
+
+            var radiance Vector3
+            ray, ok := GlobalCamera.GetRay(filmX / width, filmY / height, sampler)
+            if (ok) {
+                if statistics := world.statistics(); statistics != nil {
+                    statistics.PrimaryRays++
+                }
+                radiance = r.Integrator.Li(ray, world, sampler)
+            }
+
+            r.Film.AddSample(filmX, filmY, radiance)
+        }
+    }
+
+    return maxX - minX
+}
+
+// RenderProgressive renders the whole image in passes until the target sample count or time limit is reached, calling OnPreview and OnCheckpoint along the way.
+// A renderer resumed from a checkpoint carries on from the samples it already has.  With neither limit set it stops at the config's MaxAntialiasRays samples per pixel, and returns an error straight away if that is 0.
+// If ctx is done first OnPreview still gets the partly rendered image and an IncompleteRenderError is returned, a partial pass is never checkpointed
+func (r *Renderer) RenderProgressive(ctx context.Context, options ProgressiveOptions) error {
+    samplesPerPass := options.SamplesPerPass
+    if (samplesPerPass == 0) {
+        samplesPerPass = 1
+    }
+
+    targetSamples := options.TargetSamples
+    if (targetSamples == 0 && options.TimeLimit <= 0) {
+        targetSamples = Settings.MaxAntialiasRays
+    }
+    if (targetSamples == 0 && options.TimeLimit <= 0) {
+        return fmt.Errorf("A progressive render needs a target sample count or a time limit, MaxAntialiasRays is 0 and neither was given")
+    }
+
+    bounds := r.Film.sampleBounds(r.Region)
+    if (targetSamples > r.SamplesPerPixel) {
+        passes := int((targetSamples - r.SamplesPerPixel + samplesPerPass - 1) / samplesPerPass)
+        r.progress.begin(r.Progress, passes * len(splitTiles(bounds, r.tileSize())), uint64(bounds.Dx() * bounds.Dy()) * uint64(targetSamples - r.SamplesPerPixel), options.TimeLimit)
+    } else {
+        r.progress.begin(r.Progress, 0, 0, options.TimeLimit)
+    }
+    defer r.progress.end()
+
+    startTime := time.Now()
+    lastPreview := startTime
+    lastCheckpoint := startTime
+    passesSincePreview := 0
+
+    finished := func() bool {
+        return (targetSamples > 0 && r.SamplesPerPixel >= targetSamples) ||
+            (options.TimeLimit > 0 && time.Since(startTime) >= options.TimeLimit)
+    }
+
+    var err error
+    for false == finished() {
+        passSamples := samplesPerPass
+        if (targetSamples > 0 && r.SamplesPerPixel + passSamples > targetSamples) {
+            passSamples = targetSamples - r.SamplesPerPixel
+        }
+
+        err = r.RenderPass(ctx, passSamples)
+        if (err != nil || finished()) {
+            break
+        }
+        passesSincePreview++
+
+        previewDue := (options.PreviewPasses > 0 && passesSincePreview >= options.PreviewPasses) ||
+            (options.PreviewInterval > 0 && time.Since(lastPreview) >= options.PreviewInterval)
+        if (previewDue && options.OnPreview != nil) {
+            options.OnPreview(r)
+            lastPreview = time.Now()
+            passesSincePreview = 0
+        }
+
+        if (options.CheckpointInterval > 0 && time.Since(lastCheckpoint) >= options.CheckpointInterval && options.OnCheckpoint != nil) {
+            options.OnCheckpoint(r)
+            lastCheckpoint = time.Now()
+        }
+    }
+
+    if (options.OnPreview != nil) {
+        options.OnPreview(r)
+    }
+    if (options.OnCheckpoint != nil && false == r.partialPass) {
+        options.OnCheckpoint(r)
+    }
+
+    return err
+}
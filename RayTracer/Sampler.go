@@ -0,0 +1,252 @@
+package raytracer
+
+import
+(
+    "log"
+    "math"
+)
+
+// Sampler is an interface that hands out the sample values used while tracing a pixel.
+// Every call to Get1D or Get2D consumes the next dimension of the current sample.
+type Sampler interface {
+    // StartPixel resets the sampler for the pixel at x, y
+    StartPixel(x, y int)
+
+    // StartSample resets the dimension counter for the given sample index of the current pixel
+    StartSample(index uint32)
+
+    // Get1D returns the next sample value in [0, 1)
+    Get1D() float32
+
+    // Get2D returns the next two sample values in [0, 1)
+    Get2D() (float32, float32)
+
+    // Clone returns a copy of the sampler that can be used from another goroutine
+    Clone() Sampler
+}
+
+const (
+    // IndependentSamplerType draws uniform random numbers for every dimension
+    IndependentSamplerType = "independent"
+
+    // StratifiedSamplerType jitters samples inside a grid of strata
+    StratifiedSamplerType = "stratified"
+
+    // HaltonSamplerType uses the Halton sequence rotated per pixel
+    HaltonSamplerType = "halton"
+
+    // SobolSamplerType uses an Owen-scrambled Sobol sequence
+    SobolSamplerType = "sobol"
+
+    // BlueNoiseSamplerType uses a Sobol sequence dithered per pixel by a blue noise mask
+    BlueNoiseSamplerType = "bluenoise"
+)
+
+// samplerState holds the pixel, sample, and dimension bookkeeping shared by all samplers
+type samplerState struct {
+    samplesPerPixel uint32
+    seed uint32
+    pixelHash uint32
+    x, y int
+    sampleIndex uint32
+    dimension uint32
+}
+
+// IndependentSampler returns uniform random numbers with no structure between samples
+type IndependentSampler struct {
+    samplerState
+    random RandomStream
+}
+
+// StratifiedSampler splits every dimension into strata and jitters one sample inside each
+type StratifiedSampler struct {
+    samplerState
+}
+
+// CreateSampler creates the sampler named by samplerType that will be asked for samplesPerPixel samples per pixel.
+// The same seed always produces the same sample values for a given pixel and sample index
+func CreateSampler(samplerType string, samplesPerPixel uint32, seed uint64) Sampler {
+    state := samplerState {
+        samplesPerPixel: samplesPerPixel,
+        seed: mixBits(uint32(seed), uint32(seed >> 32)) }
+
+    if (state.samplesPerPixel == 0) {
+        state.samplesPerPixel = 1
+    }
+
+    switch samplerType {
+        case "", IndependentSamplerType:
+            return &IndependentSampler{samplerState: state}
+        case StratifiedSamplerType:
+            return &StratifiedSampler{state}
+        case HaltonSamplerType:
+            return &HaltonSampler{state}
+        case SobolSamplerType:
+            return &SobolSampler{state}
+        case BlueNoiseSamplerType:
+            return &BlueNoiseSampler{state}
+    }
+
+    log.Fatalf("Unknown sampler type %q", samplerType)
+    return nil
+}
+
+// StartPixel resets the sampler for the pixel at x, y
+func (s *samplerState) StartPixel(x, y int) {
+    s.x = x
+    s.y = y
+    s.pixelHash = mixBits(s.seed, uint32(x), uint32(y))
+    s.sampleIndex = 0
+    s.dimension = 0
+}
+
+// StartSample resets the dimension counter for the given sample index of the current pixel
+func (s *samplerState) StartSample(index uint32) {
+    s.sampleIndex = index
+    s.dimension = 0
+}
+
+// nextDimension returns the current dimension and advances to the next one
+func (s *samplerState) nextDimension() uint32 {
+    d := s.dimension
+    s.dimension++
+    return d
+}
+
+// StartPixel resets the sampler and its random stream for the pixel at x, y
+func (s *IndependentSampler) StartPixel(x, y int) {
+    s.samplerState.StartPixel(x, y)
+    s.StartSample(0)
+}
+
+// StartSample seeds the random stream for the given sample index of the current pixel
+func (s *IndependentSampler) StartSample(index uint32) {
+    s.samplerState.StartSample(index)
+    s.random.Seed(uint64(s.pixelHash) << 32 | uint64(index), uint64(s.seed))
+}
+
+// Get1D returns a uniform random number
+func (s *IndependentSampler) Get1D() float32 {
+    s.nextDimension()
+    return clampSample(s.random.Float32())
+}
+
+// Get2D returns two uniform random numbers
+func (s *IndependentSampler) Get2D() (float32, float32) {
+    return s.Get1D(), s.Get1D()
+}
+
+// Clone returns a copy of the independent sampler
+func (s *IndependentSampler) Clone() Sampler {
+    c := *s
+    return &c
+}
+
+// Get1D returns a jittered sample from a stratum chosen by a per pixel permutation
+func (s *StratifiedSampler) Get1D() float32 {
+    d := s.nextDimension()
+    n := s.samplesPerPixel
+    round := s.sampleIndex / n
+    stratum := permuteIndex(s.sampleIndex % n, n, mixBits(s.pixelHash, d, round))
+    jitter := hashToFloat(mixBits(s.pixelHash, d, s.sampleIndex, 0x51ed270b))
+    return clampSample((float32(stratum) + jitter) / float32(n))
+}
+
+// Get2D returns a jittered sample from a two dimensional grid of strata
+func (s *StratifiedSampler) Get2D() (float32, float32) {
+    d := s.nextDimension()
+    s.nextDimension()
+
+    columns := uint32(math.Ceil(math.Sqrt(float64(s.samplesPerPixel))))
+    rows := (s.samplesPerPixel + columns - 1) / columns
+    n := columns * rows
+    round := s.sampleIndex / n
+    stratum := permuteIndex(s.sampleIndex % n, n, mixBits(s.pixelHash, d, round))
+
+    jitterX := hashToFloat(mixBits(s.pixelHash, d, s.sampleIndex, 0x51ed270b))
+    jitterY := hashToFloat(mixBits(s.pixelHash, d + 1, s.sampleIndex, 0x51ed270b))
+    u := (float32(stratum % columns) + jitterX) / float32(columns)
+    v := (float32(stratum / columns) + jitterY) / float32(rows)
+    return clampSample(u), clampSample(v)
+}
+
+// Clone returns a copy of the stratified sampler
+func (s *StratifiedSampler) Clone() Sampler {
+    c := *s
+    return &c
+}
+
+// hashUint32 is a fast integer hash with good avalanche behaviour
+func hashUint32(x uint32) uint32 {
+    x ^= x >> 16
+    x *= 0x7feb352d
+    x ^= x >> 15
+    x *= 0x846ca68b
+    x ^= x >> 16
+    return x
+}
+
+// mixBits combines several values into a single hash
+func mixBits(values ...uint32) uint32 {
+    h := uint32(0x9e3779b9)
+    for _, v := range values {
+        h = hashUint32(h ^ (v + 0x9e3779b9 + (h << 6) + (h >> 2)))
+    }
+
+    return h
+}
+
+// hashToFloat maps a hash to a float in [0, 1) using its upper 24 bits
+func hashToFloat(h uint32) float32 {
+    return float32(h >> 8) * (1.0 / (1 << 24))
+}
+
+// clampSample keeps rounding from pushing a sample value up to 1
+func clampSample(v float32) float32 {
+    if (v >= 1.0) {
+        return math.Nextafter32(1.0, 0.0)
+    }
+
+    return v
+}
+
+// permuteIndex returns element i of a random permutation of [0, l) selected by seed, see Kensler's "Correlated Multi-Jittered Sampling"
+func permuteIndex(i, l, seed uint32) uint32 {
+    if (l <= 1) {
+        return 0
+    }
+
+    w := l - 1
+    w |= w >> 1
+    w |= w >> 2
+    w |= w >> 4
+    w |= w >> 8
+    w |= w >> 16
+
+    for {
+        i ^= seed
+        i *= 0xe170893d
+        i ^= seed >> 16
+        i ^= (i & w) >> 4
+        i ^= seed >> 8
+        i *= 0x0929eb3f
+        i ^= seed >> 23
+        i ^= (i & w) >> 1
+        i *= 1 | seed >> 27
+        i *= 0x6935fa69
+        i ^= (i & w) >> 11
+        i *= 0x74dcb303
+        i ^= (i & w) >> 2
+        i *= 0x9e501cc3
+        i ^= (i & w) >> 2
+        i *= 0xc860a3df
+        i &= w
+        i ^= i >> 5
+
+        if (i < l) {
+            break
+        }
+    }
+
+    return (i + seed) % l
+}
@@ -0,0 +1,654 @@
+package raytracer
+
+import
+(
+    "bytes"
+    "encoding/json"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// SceneDocumentVersion is the version of the scene documents this build reads and writes.
+// The separate config, scene and camera files that came before scene documents have no version and are migrated when they are loaded
+const SceneDocumentVersion = 1
+
+// sceneDocument holds everything needed to render in one file: the render settings, cameras, materials, objects and lights
+type sceneDocument struct {
+    Version int
+
+    // Settings are the render settings, the same fields as a config file
+    Settings Config
+
+    // Camera names the camera in Cameras to render from.  Defaults to the first camera in name order
+    Camera string `json:",omitempty"`
+    Cameras map[string]cameraConfig
+
+    // Materials is the material library shared by name between objects, see MaterialDefinition
+    Materials map[string]MaterialDefinition `json:",omitempty"`
+
+    Objects map[string]documentObject `json:",omitempty"`
+    Lights map[string]documentLight `json:",omitempty"`
+    Meshes map[string]documentMesh `json:",omitempty"`
+    PointClouds map[string]documentPointCloud `json:",omitempty"`
+
+    // Includes are other scene documents whose materials, cameras, objects, lights, meshes, point clouds and models are added to this one, their settings are not used.
+    // An exported scene has the included contents copied into it
+    Includes []documentInclude `json:",omitempty"`
+
+    // Models are glTF and PLY files whose meshes, points, lights and cameras are added to the document's
+    Models []documentModel `json:",omitempty"`
+}
+
+// documentObject is a sphere in a scene document.  Its material is named in Material, with Properties overriding some of its fields, or given whole in Properties
+type documentObject struct {
+    Origin Vector3
+    Radius float32
+    Motion *Vector3 `json:",omitempty"`
+
+    Material string `json:",omitempty"`
+    Properties MaterialDefinition `json:",omitempty"`
+}
+
+// documentLight is an emissive sphere in a scene document
+type documentLight struct {
+    Origin Vector3
+    Radius float32
+    Motion *Vector3 `json:",omitempty"`
+    Emission Vector3
+}
+
+// documentMesh is a triangle mesh in a scene document, see Mesh.  Its material is given the same way as an object's
+type documentMesh struct {
+    Positions []Vector3
+    Normals []Vector3 `json:",omitempty"`
+    UVs [][2]float32 `json:",omitempty"`
+    Indices []uint32
+    Texture *Texture `json:",omitempty"`
+
+    Material string `json:",omitempty"`
+    Properties MaterialDefinition `json:",omitempty"`
+}
+
+// documentPointCloud is a cloud of small spheres in a scene document, see PointCloud.  Its material is given the same way as an object's
+type documentPointCloud struct {
+    Positions []Vector3
+    Radii []float32 `json:",omitempty"`
+    Radius float32 `json:",omitempty"`
+    Colors []byte `json:",omitempty"`
+
+    Material string `json:",omitempty"`
+    Properties MaterialDefinition `json:",omitempty"`
+}
+
+// documentModel is a .gltf, .glb or .ply file in a scene document, File is relative to the document
+type documentModel struct {
+    File string
+
+    // Material and Properties give a PLY file its material the same way as an object's, glTF files have their own.  Defaults to light gray
+    Material string `json:",omitempty"`
+    Properties MaterialDefinition `json:",omitempty"`
+
+    // PointRadius is the radius of the points of a PLY point cloud that does not give them one
+    PointRadius float32 `json:",omitempty"`
+
+    // Namespace is put in front of the names of the model's objects and cameras followed by a slash, Transform places the model in the scene
+    Namespace string `json:",omitempty"`
+    Transform *documentTransform `json:",omitempty"`
+}
+
+// ImportSceneFiles imports the scene to render, either a scene document or the older separate config, scene and camera files.
+// A scene document needs no config or camera file, if they are given anyway they replace the document's settings and camera.
+// Separate files are migrated to a scene document as they are loaded so both render exactly the same
+func ImportSceneFiles(configFilename, sceneFilename, cameraFilename string) {
+    contents := readFile(sceneFilename)
+    if (false == isSceneDocument(contents)) {
+        if (configFilename == "" || cameraFilename == "") {
+            log.Fatalf("%v is a scene file from before scene documents, it needs a config and a camera file", sceneFilename)
+        }
+
+        contents = MigrateSceneJSON(readFile(configFilename), contents, readFile(cameraFilename))
+        configFilename, cameraFilename = "", ""
+    }
+
+    importSceneDocumentJSON(contents, sceneFilename)
+
+    // Override files replace the document's settings and camera entirely rather than only the fields they have
+    if (configFilename != "") {
+        Settings = Config{}
+        ImportConfig(configFilename)
+    }
+    if (cameraFilename != "") {
+        cameraSettings = cameraConfig{}
+        ImportCamera(cameraFilename)
+    } else {
+        // The camera's aspect ratio comes from the settings, which a config file may have changed
+        setGlobalCamera(cameraSettings)
+    }
+}
+
+// readFile returns the contents of filename
+func readFile(filename string) []byte {
+    file, err := os.Open(filename)
+    checkError(err)
+    defer file.Close()
+
+    info, err := file.Stat()
+    checkError(err)
+
+    contents := make([]byte, info.Size())
+
+    _, err = file.Read(contents)
+    if (err != nil && io.EOF != err) {
+        checkError(err)
+    }
+
+    return contents
+}
+
+// isSceneDocument returns true if contents is a scene document rather than a scene file from before scene documents
+func isSceneDocument(contents []byte) bool {
+    var header struct {
+        Version json.RawMessage
+    }
+    err := json.Unmarshal(contents, &header)
+    checkError(err)
+
+    return header.Version != nil
+}
+
+// ImportSceneDocument will import a scene document as the global config, camera and scene
+func ImportSceneDocument(filename string) {
+    importSceneDocumentJSON(readFile(filename), filename)
+}
+
+// ImportSceneDocumentJSON will import the contents of a scene document as the global config, camera and scene, included and model files are relative to the current directory
+func ImportSceneDocumentJSON(contents []byte) {
+    importSceneDocumentJSON(contents, "")
+}
+
+// importSceneDocumentJSON imports the contents of the scene document filename, included and model files are relative to its directory.
+// filename is empty for contents that did not come from a file
+func importSceneDocumentJSON(contents []byte, filename string) {
+    document := decodeSceneDocument(contents, filename)
+
+    directory := "."
+    var including []string
+    if (filename != "") {
+        directory = filepath.Dir(filename)
+        absolute, err := filepath.Abs(filename)
+        checkError(err)
+        including = []string{ absolute }
+    }
+
+    // The document's own cameras come before those of its includes and models
+    if (document.Camera == "" && len(document.Cameras) > 0) {
+        document.Camera = document.activeCamera()
+    }
+    document.resolveIncludes(directory, including)
+
+    Settings = document.Settings
+    Scene = World{}
+    for name, definition := range document.Materials {
+        Scene.AddMaterial(name, definition)
+    }
+
+    // Without cameras of its own the included cameras come before those of its models
+    if (document.Camera == "" && len(document.Cameras) > 0) {
+        document.Camera = document.activeCamera()
+    }
+
+    modelObjects := make(map[string]CollidableObject)
+    for _, model := range document.Models {
+        filename := model.File
+        if (false == filepath.IsAbs(filename)) {
+            filename = filepath.Join(directory, filename)
+        }
+
+        loaded, err := loadModel(model, filename)
+        checkError(err)
+
+        var transform transformMatrix
+        if (model.Transform != nil) {
+            transform = model.Transform.matrix()
+        }
+
+        names := make([]string, 0, len(loaded.Objects))
+        for name, object := range loaded.Objects {
+            if (model.Namespace != "") {
+                name = model.Namespace + "/" + name
+            }
+            if (model.Transform != nil) {
+                object = transform.transformObject(name, object)
+            }
+            if _, exists := modelObjects[name]; exists {
+                log.Fatalf("Scene document has two model objects named %q", name)
+            }
+            modelObjects[name] = object
+            names = append(names, name)
+        }
+        model.File = filename
+        Scene.addModel(model, names)
+
+        if (nil == document.Cameras) {
+            document.Cameras = make(map[string]cameraConfig)
+        }
+        for name, camera := range loaded.Cameras {
+            if (model.Namespace != "") {
+                name = model.Namespace + "/" + name
+            }
+            if (model.Transform != nil) {
+                camera = transform.transformCamera(camera)
+            }
+            if _, exists := document.Cameras[name]; false == exists {
+                document.Cameras[name] = camera
+            }
+        }
+    }
+
+    camera, ok := document.Cameras[document.activeCamera()]
+    if (false == ok) {
+        log.Fatalf("Scene document has no camera %q", document.Camera)
+    }
+    if (camera.Up == Vector3{}) {
+        camera.Up = defaultUpVector
+    }
+    setGlobalCamera(camera)
+
+    // Add objects in name order so the scene is identical on every import
+    names := make([]string, 0, len(document.Objects) + len(document.Lights) + len(document.Meshes) + len(document.PointClouds) + len(modelObjects))
+    named := make(map[string]bool)
+    addName := func(name string) {
+        if (named[name]) {
+            log.Fatalf("Scene document has more than one object, light, mesh, point cloud or model object named %q", name)
+        }
+        named[name] = true
+        names = append(names, name)
+    }
+    for name := range document.Objects {
+        addName(name)
+    }
+    for name := range document.Lights {
+        addName(name)
+    }
+    for name := range document.Meshes {
+        addName(name)
+    }
+    for name := range document.PointClouds {
+        addName(name)
+    }
+    for name := range modelObjects {
+        addName(name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        if object, isModelObject := modelObjects[name]; isModelObject {
+            Scene.AddObject(name, object)
+            continue
+        }
+
+        if mesh, isMesh := document.Meshes[name]; isMesh {
+            addDocumentMesh(name, mesh)
+            continue
+        }
+
+        if points, isPointCloud := document.PointClouds[name]; isPointCloud {
+            addDocumentPointCloud(name, points)
+            continue
+        }
+
+        if light, isLight := document.Lights[name]; isLight {
+            Scene.AddObject(name, Sphere {
+                Origin: light.Origin,
+                Radius: light.Radius,
+                Motion: motionOrZero(light.Motion),
+                Properties: Emissive{ Emission: light.Emission } })
+            continue
+        }
+
+        object := document.Objects[name]
+        sphere := Sphere {
+            Origin: object.Origin,
+            Radius: object.Radius,
+            Motion: motionOrZero(object.Motion) }
+
+        if (object.Material != "") {
+            Scene.AddObjectWithMaterial(name, sphere, object.Material, object.Properties)
+            continue
+        }
+
+        if (object.Properties == nil) {
+            log.Fatalf("Object %q has no Material or Properties", name)
+        }
+        material, ok := deserializeMaterial(object.Properties)
+        if (false == ok) {
+            log.Fatalf("Object %q has invalid Properties", name)
+        }
+        sphere.Properties = material
+        Scene.AddObject(name, sphere)
+    }
+
+    Scene.BuildHierarchy()
+}
+
+// decodeSceneDocument decodes a scene document and checks its version, filename is used in errors and may be empty
+func decodeSceneDocument(contents []byte, filename string) sceneDocument {
+    var document sceneDocument
+    decoder := json.NewDecoder(bytes.NewReader(contents))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&document); err != nil {
+        if (filename != "") {
+            log.Fatalf("%v: %v", filename, err)
+        }
+        log.Fatal(err)
+    }
+
+    if (document.Version < 1 || document.Version > SceneDocumentVersion) {
+        log.Fatalf("Scene document version %v is not supported, this build reads versions 1 to %v", document.Version, SceneDocumentVersion)
+    }
+
+    return document
+}
+
+// addDocumentMesh adds a mesh from a scene document to the global scene, a mesh whose data does not fit together is skipped as it is in scene files
+func addDocumentMesh(name string, mesh documentMesh) {
+    if err := checkMeshData(mesh.Positions, mesh.Normals, mesh.UVs, mesh.Indices); err != nil {
+        log.Printf("Skipping mesh %v: %v", name, err)
+        return
+    }
+
+    if (mesh.Material != "") {
+        Scene.AddObjectWithMaterial(name, NewMesh(mesh.Positions, mesh.Normals, mesh.UVs, mesh.Indices, nil, mesh.Texture), mesh.Material, mesh.Properties)
+        return
+    }
+
+    if (mesh.Properties == nil) {
+        log.Fatalf("Mesh %q has no Material or Properties", name)
+    }
+    material, ok := deserializeMaterial(mesh.Properties)
+    if (false == ok) {
+        log.Fatalf("Mesh %q has invalid Properties", name)
+    }
+    Scene.AddObject(name, NewMesh(mesh.Positions, mesh.Normals, mesh.UVs, mesh.Indices, material, mesh.Texture))
+}
+
+// addDocumentPointCloud adds a point cloud from a scene document to the global scene, a point cloud whose data does not fit together is skipped as it is in scene files
+func addDocumentPointCloud(name string, points documentPointCloud) {
+    if err := checkPointCloudData(points.Positions, points.Radii, points.Radius, points.Colors); err != nil {
+        log.Printf("Skipping point cloud %v: %v", name, err)
+        return
+    }
+
+    if (points.Material != "") {
+        Scene.AddObjectWithMaterial(name, NewPointCloud(points.Positions, points.Radii, points.Radius, points.Colors, nil), points.Material, points.Properties)
+        return
+    }
+
+    if (points.Properties == nil) {
+        log.Fatalf("Point cloud %q has no Material or Properties", name)
+    }
+    material, ok := deserializeMaterial(points.Properties)
+    if (false == ok) {
+        log.Fatalf("Point cloud %q has invalid Properties", name)
+    }
+    Scene.AddObject(name, NewPointCloud(points.Positions, points.Radii, points.Radius, points.Colors, material))
+}
+
+// activeCamera returns the name of the camera to render from
+func (d sceneDocument) activeCamera() string {
+    if (d.Camera != "") {
+        return d.Camera
+    }
+
+    names := make([]string, 0, len(d.Cameras))
+    for name := range d.Cameras {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    if (len(names) == 0) {
+        log.Fatalf("Scene document has no cameras")
+    }
+
+    return names[0]
+}
+
+// motionOrZero returns the motion, or no motion when it is not set
+func motionOrZero(motion *Vector3) Vector3 {
+    if (motion == nil) {
+        return Vector3{}
+    }
+
+    return *motion
+}
+
+// MigrateSceneJSON converts the contents of separate config, scene and camera files to a scene document.
+// Emissive spheres become lights and every other sphere's material becomes a named material shared by objects with the same material
+func MigrateSceneJSON(config, scene, camera []byte) []byte {
+    document := sceneDocument {
+        Version: SceneDocumentVersion,
+        Camera: "main",
+        Cameras: make(map[string]cameraConfig),
+        Materials: make(map[string]MaterialDefinition),
+        Objects: make(map[string]documentObject),
+        Lights: make(map[string]documentLight),
+        Meshes: make(map[string]documentMesh),
+        PointClouds: make(map[string]documentPointCloud) }
+
+    err := json.Unmarshal(config, &document.Settings)
+    checkError(err)
+
+    var documentCamera cameraConfig
+    err = json.Unmarshal(camera, &documentCamera)
+    checkError(err)
+    document.Cameras[document.Camera] = documentCamera
+
+    var sceneObjects map[string]interface{}
+    err = json.Unmarshal(scene, &sceneObjects)
+    checkError(err)
+
+    names := make([]string, 0, len(sceneObjects))
+    for name := range sceneObjects {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    // Objects that would not have loaded from the scene file are left out and logged, just as loading it would have
+    materialNames := make(map[string]string)
+    for _, name := range names {
+        object, ok := sceneObjects[name].(map[string]interface{})
+        if (false == ok) {
+            continue
+        }
+
+        // Meshes and point clouds are written to scene files by distributed renders and render metadata, their material is kept inline
+        if (nil != object["Indices"]) {
+            if _, isMesh := deserializeMesh(object); isMesh {
+                var mesh documentMesh
+                convertJSONObject(object, &mesh)
+                document.Meshes[name] = mesh
+            } else {
+                log.Printf("Skipping %v, it is not a valid mesh, the validate command shows why", name)
+            }
+            continue
+        }
+        if (nil != object["Positions"]) {
+            if _, isPointCloud := deserializePointCloud(object); isPointCloud {
+                var points documentPointCloud
+                convertJSONObject(object, &points)
+                document.PointClouds[name] = points
+            } else {
+                log.Printf("Skipping %v, it is not a valid point cloud, the validate command shows why", name)
+            }
+            continue
+        }
+
+        sphere, isSphere := deserializeSphere(object)
+        if (false == isSphere || sphere.Properties == nil) {
+            log.Printf("Skipping %v, it is not a valid sphere, the validate command shows why", name)
+            continue
+        }
+
+        var motion *Vector3
+        if (sphere.Motion != Vector3{}) {
+            motion = &sphere.Motion
+        }
+
+        if emissive, isEmissive := sphere.Properties.(Emissive); isEmissive {
+            document.Lights[name] = documentLight {
+                Origin: sphere.Origin,
+                Radius: sphere.Radius,
+                Motion: motion,
+                Emission: emissive.Emission }
+            continue
+        }
+
+        properties := MaterialDefinition(object["Properties"].(map[string]interface{}))
+        key, err := json.Marshal(properties)
+        checkError(err)
+
+        materialName, exists := materialNames[string(key)]
+        if (false == exists) {
+            materialName = name
+            materialNames[string(key)] = materialName
+            document.Materials[materialName] = properties
+        }
+
+        document.Objects[name] = documentObject {
+            Origin: sphere.Origin,
+            Radius: sphere.Radius,
+            Motion: motion,
+            Material: materialName }
+    }
+
+    contents, err := json.MarshalIndent(document, "", "    ")
+    checkError(err)
+
+    return contents
+}
+
+// convertJSONObject fills v with the fields of a json object
+func convertJSONObject(object map[string]interface{}, v interface{}) {
+    b, err := json.Marshal(object)
+    checkError(err)
+
+    err = json.Unmarshal(b, v)
+    checkError(err)
+}
+
+// currentSceneDocument returns the global config, camera and scene as a scene document to be written to directory, model files are referred to relative to it.
+// With no directory model files are referred to by their absolute path
+func currentSceneDocument(directory string) sceneDocument {
+    document := sceneDocument {
+        Version: SceneDocumentVersion,
+        Settings: Settings,
+        Camera: "main",
+        Cameras: map[string]cameraConfig{ "main": cameraSettings },
+        Materials: Scene.Materials,
+        Objects: make(map[string]documentObject),
+        Lights: make(map[string]documentLight),
+        Meshes: make(map[string]documentMesh),
+        PointClouds: make(map[string]documentPointCloud) }
+
+    for _, model := range Scene.models {
+        if (directory != "") {
+            absolute, err := filepath.Abs(directory)
+            checkError(err)
+            if file, err := filepath.Rel(absolute, model.File); err == nil {
+                model.File = file
+            }
+        }
+        model.File = filepath.ToSlash(model.File)
+        document.Models = append(document.Models, model)
+    }
+
+    for i, name := range Scene.Scene.names {
+        if (Scene.modelObjects[name]) {
+            continue
+        }
+
+        if mesh, isMesh := Scene.Scene.objects[i].(*Mesh); isMesh {
+            exported := documentMesh {
+                Positions: mesh.Positions,
+                Normals: mesh.Normals,
+                UVs: mesh.UVs,
+                Indices: mesh.Indices,
+                Texture: mesh.Texture }
+            if reference, ok := Scene.objectMaterialReference(name, mesh); ok {
+                exported.Material = reference.Material
+                exported.Properties = reference.Overrides
+            } else {
+                exported.Properties = MaterialDefinition(toJSONObject(mesh.Properties))
+            }
+            document.Meshes[name] = exported
+            continue
+        }
+
+        if points, isPointCloud := Scene.Scene.objects[i].(*PointCloud); isPointCloud {
+            exported := documentPointCloud {
+                Positions: points.Positions,
+                Radii: points.Radii,
+                Radius: points.Radius,
+                Colors: points.Colors }
+            if reference, ok := Scene.objectMaterialReference(name, points); ok {
+                exported.Material = reference.Material
+                exported.Properties = reference.Overrides
+            } else {
+                exported.Properties = MaterialDefinition(toJSONObject(points.Properties))
+            }
+            document.PointClouds[name] = exported
+            continue
+        }
+
+        sphere, isSphere := Scene.Scene.objects[i].(Sphere)
+        if (false == isSphere) {
+            log.Fatalf("Object %q cannot be exported, only spheres, meshes and point clouds can", name)
+        }
+
+        var motion *Vector3
+        if (sphere.Motion != Vector3{}) {
+            motion = &sphere.Motion
+        }
+
+        if reference, ok := Scene.objectMaterialReference(name, sphere); ok {
+            document.Objects[name] = documentObject {
+                Origin: sphere.Origin,
+                Radius: sphere.Radius,
+                Motion: motion,
+                Material: reference.Material,
+                Properties: reference.Overrides }
+            continue
+        }
+
+        if emissive, isEmissive := sphere.Properties.(Emissive); isEmissive {
+            document.Lights[name] = documentLight {
+                Origin: sphere.Origin,
+                Radius: sphere.Radius,
+                Motion: motion,
+                Emission: emissive.Emission }
+            continue
+        }
+
+        document.Objects[name] = documentObject {
+            Origin: sphere.Origin,
+            Radius: sphere.Radius,
+            Motion: motion,
+            Properties: MaterialDefinition(toJSONObject(sphere.Properties)) }
+    }
+
+    return document
+}
+
+// MigrateSceneFiles converts separate config, scene and camera files to a scene document written to filename
+func MigrateSceneFiles(configFilename, sceneFilename, cameraFilename, filename string) {
+    contents := MigrateSceneJSON(readFile(configFilename), readFile(sceneFilename), readFile(cameraFilename))
+
+    documentFile, err := os.Create(filename)
+    checkError(err)
+    defer documentFile.Close()
+
+    documentFile.Write(contents)
+}
@@ -0,0 +1,249 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+const (
+    // ConfigSchema is the schema of config files
+    ConfigSchema = "config"
+
+    // CameraSchema is the schema of camera files
+    CameraSchema = "camera"
+
+    // SceneSchema is the schema of the scene files from before scene documents
+    SceneSchema = "scene"
+
+    // DocumentSchema is the schema of scene documents
+    DocumentSchema = "document"
+)
+
+// SchemaKinds are the kinds of file there is a schema for
+var SchemaKinds = []string{ ConfigSchema, CameraSchema, SceneSchema, DocumentSchema }
+
+// schemaGenerator builds a JSON Schema from the Go types files are loaded into, every struct type becomes a definition that is referred to wherever it is used
+type schemaGenerator struct {
+    definitions map[string]interface{}
+}
+
+// Schema returns the JSON Schema of one kind of file, see SchemaKinds.  Editors use it to complete field names and point out mistakes while a file is written
+func Schema(kind string) []byte {
+    g := schemaGenerator{ definitions: make(map[string]interface{}) }
+
+    var root map[string]interface{}
+    switch kind {
+        case ConfigSchema:
+            root = g.schema(reflect.TypeOf(Config{}), "")
+            root["required"] = configRequiredFields
+        case CameraSchema:
+            root = g.schema(reflect.TypeOf(cameraConfig{}), "")
+        case DocumentSchema:
+            root = g.schema(reflect.TypeOf(sceneDocument{}), "")
+        case SceneSchema:
+            // Scene entries are told apart by their fields, a mesh has Indices and a point cloud Positions
+            root = map[string]interface{} {
+                "type": "object",
+                "additionalProperties": map[string]interface{} {
+                    "anyOf": []interface{} {
+                        g.schema(reflect.TypeOf(Sphere{}), ""),
+                        g.schema(reflect.TypeOf(Mesh{}), ""),
+                        g.schema(reflect.TypeOf(PointCloud{}), "") } } }
+        default:
+            log.Fatalf("Unknown schema %v, expected one of %v", kind, strings.Join(SchemaKinds, ", "))
+    }
+
+    root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+    root["title"] = "vohumana-gotracer " + kind
+    root["$defs"] = g.definitions
+
+    b, err := json.MarshalIndent(root, "", "    ")
+    checkError(err)
+    return b
+}
+
+// WriteSchemas writes the schema of every kind of file to directory as config.schema.json, camera.schema.json, scene.schema.json and document.schema.json
+func WriteSchemas(directory string) []string {
+    checkError(os.MkdirAll(directory, 0755))
+
+    var filenames []string
+    for _, kind := range SchemaKinds {
+        filename := filepath.Join(directory, kind + ".schema.json")
+        checkError(ioutil.WriteFile(filename, Schema(kind), 0644))
+        filenames = append(filenames, filename)
+    }
+
+    return filenames
+}
+
+// reference returns a schema that refers to a definition
+func reference(name string) map[string]interface{} {
+    return map[string]interface{}{ "$ref": "#/$defs/" + name }
+}
+
+// schema returns the schema of a type, rule names the field it is for in knownOptions and valueRules
+func (g *schemaGenerator) schema(t reflect.Type, rule string) map[string]interface{} {
+    if (t == materialType) {
+        g.materialSchema("Material", false)
+        return reference("Material")
+    }
+    if (t == materialDefinitionType) {
+        g.materialSchema("MaterialDefinition", true)
+        return reference("MaterialDefinition")
+    }
+
+    r, hasRule := valueRules[rule]
+
+    switch t.Kind() {
+        case reflect.Ptr:
+            return g.schema(t.Elem(), rule)
+
+        case reflect.Struct:
+            // Vectors with a range have it on each component
+            if (hasRule && t == reflect.TypeOf(Vector3{})) {
+                return g.vectorSchema(r)
+            }
+
+            name := schemaName(t)
+            if _, defined := g.definitions[name]; false == defined {
+                g.definitions[name] = nil
+                g.definitions[name] = g.structSchema(t, name)
+            }
+            return reference(name)
+
+        case reflect.Map:
+            return map[string]interface{} {
+                "type": "object",
+                "additionalProperties": g.schema(t.Elem(), "") }
+
+        case reflect.Slice, reflect.Array:
+            if (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+                return map[string]interface{} {
+                    "type": "string",
+                    "contentEncoding": "base64" }
+            }
+
+            schema := map[string]interface{} {
+                "type": "array",
+                "items": g.schema(t.Elem(), rule) }
+            if (t.Kind() == reflect.Array) {
+                schema["minItems"] = t.Len()
+                schema["maxItems"] = t.Len()
+            }
+            return schema
+
+        case reflect.String:
+            schema := map[string]interface{}{ "type": "string" }
+            if known, ok := knownOptions[rule]; ok {
+                schema["enum"] = known
+            }
+            return schema
+
+        case reflect.Bool:
+            return map[string]interface{}{ "type": "boolean" }
+
+        case reflect.Float32, reflect.Float64:
+            schema := map[string]interface{}{ "type": "number" }
+            if (hasRule) {
+                r.apply(schema)
+            }
+            return schema
+
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+            schema := map[string]interface{}{ "type": "integer" }
+            switch t.Kind() {
+                case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+                    schema["minimum"] = 0
+                    if (t.Bits() < 64) {
+                        schema["maximum"] = uint64(1 << uint(t.Bits())) - 1
+                    }
+            }
+            if (hasRule) {
+                r.apply(schema)
+            }
+            return schema
+    }
+
+    // Anything else such as interface{} can hold any value
+    return map[string]interface{}{}
+}
+
+// structSchema returns the schema of the fields of a struct, name is its schema name
+func (g *schemaGenerator) structSchema(t reflect.Type, name string) map[string]interface{} {
+    fields, names := jsonFields(t)
+    properties := make(map[string]interface{}, len(names))
+    for _, field := range names {
+        properties[field] = g.schema(fields[field].Type, name + "." + field)
+    }
+
+    schema := map[string]interface{} {
+        "type": "object",
+        "properties": properties,
+        "additionalProperties": false }
+    if required, ok := requiredFields[name]; ok {
+        schema["required"] = required
+    }
+
+    return schema
+}
+
+// vectorSchema returns the schema of a vector whose components are in the range of a rule
+func (g *schemaGenerator) vectorSchema(r valueRule) map[string]interface{} {
+    properties := make(map[string]interface{})
+    for _, axis := range []string{ "X", "Y", "Z" } {
+        component := map[string]interface{}{ "type": "number" }
+        r.apply(component)
+        properties[axis] = component
+    }
+
+    return map[string]interface{} {
+        "type": "object",
+        "properties": properties,
+        "additionalProperties": false }
+}
+
+// materialSchema defines the schema of a material with every field any kind of material has, definitions in the material library may also inherit from another
+func (g *schemaGenerator) materialSchema(name string, inherits bool) {
+    if _, defined := g.definitions[name]; defined {
+        return
+    }
+
+    fields := make([]string, 0, len(materialFields))
+    for field := range materialFields {
+        fields = append(fields, field)
+    }
+    sort.Strings(fields)
+
+    properties := make(map[string]interface{})
+    for _, field := range fields {
+        properties[field] = g.schema(materialFields[field], "Material." + field)
+    }
+    if (inherits) {
+        properties[inheritsField] = map[string]interface{}{ "type": "string" }
+    }
+
+    g.definitions[name] = map[string]interface{} {
+        "type": "object",
+        "description": "Fuzziness makes a metal, RefractiveIndex a dielectric and Emission an emissive material, otherwise it is lambertian",
+        "properties": properties,
+        "additionalProperties": false }
+}
+
+// apply adds the range of a rule to a number schema
+func (r valueRule) apply(schema map[string]interface{}) {
+    if (r.hasMinimum && r.exclusiveMinimum) {
+        schema["exclusiveMinimum"] = r.minimum
+    } else if (r.hasMinimum) {
+        schema["minimum"] = r.minimum
+    }
+    if (r.hasMaximum) {
+        schema["maximum"] = r.maximum
+    }
+}
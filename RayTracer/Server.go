@@ -0,0 +1,426 @@
+package raytracer
+
+import
+(
+    "context"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    // JobQueued jobs are waiting for the jobs ahead of them to finish
+    JobQueued = "queued"
+
+    // JobRendering is the job being rendered
+    JobRendering = "rendering"
+
+    // JobDone jobs reached their sample count
+    JobDone = "done"
+
+    // JobCancelled jobs were cancelled, a job cancelled while rendering keeps the image it had so far
+    JobCancelled = "cancelled"
+)
+
+// maxQueuedJobs is the number of jobs that can wait to be rendered before new jobs are turned away
+const maxQueuedJobs = 64
+
+// maxBundleBytes is the largest job body the server reads, scenes with meshes and point clouds can be large but not unbounded
+const maxBundleBytes = 256 << 20
+
+// renderBundle is the body posted to create a job, the contents of a config, scene and camera file
+type renderBundle struct {
+    Config, Scene, Camera json.RawMessage
+
+    // Samples is the number of samples per pixel to render.  Defaults to the config's MaxAntialiasRays
+    Samples uint32
+}
+
+// JobStatus describes a render job
+type JobStatus struct {
+    ID int
+    State string
+
+    // SamplesPerPixel is the number of samples per pixel in the current image out of TargetSamples
+    SamplesPerPixel, TargetSamples uint32
+    Progress float32
+
+    // RemainingSeconds is the estimated time until a rendering job finishes, -1 when it is not known
+    RemainingSeconds float64
+
+    Created time.Time
+    Started, Finished *time.Time `json:",omitempty"`
+}
+
+// renderJob is a job and its latest image
+type renderJob struct {
+    status JobStatus
+    bundle renderBundle
+    image []byte
+    ctx context.Context
+    cancel context.CancelFunc
+}
+
+// RenderServer renders jobs posted over HTTP one at a time, the ray tracer's global config, scene and camera belong to the job being rendered
+type RenderServer struct {
+    mutex sync.Mutex
+    jobs []*renderJob
+    queue chan *renderJob
+}
+
+// Serve renders jobs posted to an HTTP API on address until the server fails
+func Serve(address string) error {
+    return http.ListenAndServe(address, CreateRenderServer())
+}
+
+// CreateRenderServer creates a render server and starts rendering the jobs posted to it
+func CreateRenderServer() *RenderServer {
+    s := &RenderServer{ queue: make(chan *renderJob, maxQueuedJobs) }
+
+    go s.run()
+    return s
+}
+
+// ServeHTTP routes a request to the job API.
+//  GET / is the live preview page
+//  GET /jobs lists the jobs and POST /jobs creates one from a bundle
+//  GET /jobs/{id} is the status of a job and GET /jobs/{id}/image.png its latest image
+//  POST /jobs/{id}/cancel or DELETE /jobs/{id} cancels a job
+func (s *RenderServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+    route := r.Method + " " + path[0]
+    if (len(path) > 1) {
+        route += " {id}"
+    }
+    if (len(path) > 2) {
+        route += " " + strings.Join(path[2:], "/")
+    }
+
+    var id string
+    if (len(path) > 1) {
+        id = path[1]
+    }
+
+    switch route {
+        case "GET ":
+            s.handleIndex(w, r)
+        case "GET jobs":
+            s.handleList(w, r)
+        case "POST jobs":
+            s.handleCreate(w, r)
+        case "GET jobs {id}":
+            s.handleStatus(w, id)
+        case "GET jobs {id} image.png":
+            s.handleImage(w, id)
+        case "POST jobs {id} cancel", "DELETE jobs {id}":
+            s.handleCancel(w, id)
+        default:
+            http.NotFound(w, r)
+    }
+}
+
+// run renders queued jobs in the order they were posted
+func (s *RenderServer) run() {
+    for job := range s.queue {
+        s.mutex.Lock()
+        if (job.status.State != JobQueued) {
+            s.mutex.Unlock()
+            continue
+        }
+
+        started := time.Now()
+        job.status.State = JobRendering
+        job.status.Started = &started
+        s.mutex.Unlock()
+
+        ImportRenderInputs(job.bundle.Config, job.bundle.Scene, job.bundle.Camera)
+        renderer := CreateRenderer()
+        renderer.Progress = ProgressFunc(func(report ProgressReport) {
+            s.updateProgress(job, report)
+        })
+        targetSamples := job.bundle.Samples
+        if (targetSamples == 0) {
+            targetSamples = Settings.MaxAntialiasRays
+        }
+
+        s.mutex.Lock()
+        job.status.TargetSamples = targetSamples
+        s.mutex.Unlock()
+
+        renderer.RenderProgressive(job.ctx, ProgressiveOptions {
+            TargetSamples: targetSamples,
+            PreviewInterval: time.Second,
+            OnPreview: func(r *Renderer) {
+                s.updateImage(job, r)
+            } })
+        job.cancel()
+
+        s.mutex.Lock()
+        finished := time.Now()
+        job.status.Finished = &finished
+        if (job.status.State == JobRendering) {
+            job.status.State = JobDone
+        }
+        s.mutex.Unlock()
+    }
+}
+
+// updateImage stores the renderer's current image and sample count in the job
+func (s *RenderServer) updateImage(job *renderJob, r *Renderer) {
+    var encoded bytes.Buffer
+    err := WritePNG(&encoded, r.Image(), r.Metadata())
+    checkError(err)
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    job.image = encoded.Bytes()
+    job.status.SamplesPerPixel = r.SamplesPerPixel
+}
+
+// updateProgress stores the progress reported by the job's renderer
+func (s *RenderServer) updateProgress(job *renderJob, report ProgressReport) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    job.status.Progress = float32(report.Fraction())
+    job.status.RemainingSeconds = -1
+    if (report.Remaining >= 0) {
+        job.status.RemainingSeconds = report.Remaining.Seconds()
+    }
+}
+
+// findJob returns the job with the given id, writing an error response if there is none
+func (s *RenderServer) findJob(w http.ResponseWriter, id string) *renderJob {
+    index, err := strconv.Atoi(id)
+    if (err == nil && index >= 1 && index <= len(s.jobs)) {
+        return s.jobs[index - 1]
+    }
+
+    http.Error(w, "No job " + id, http.StatusNotFound)
+    return nil
+}
+
+// writeJSON writes v as the json response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+// handleCreate queues the posted bundle as a new job
+func (s *RenderServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+    var bundle renderBundle
+    err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBundleBytes)).Decode(&bundle)
+    if (err == nil) {
+        err = checkRenderBundle(bundle)
+    }
+    if (err != nil) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    job := &renderJob {
+        status: JobStatus {
+            ID: len(s.jobs) + 1,
+            State: JobQueued,
+            RemainingSeconds: -1,
+            Created: time.Now() },
+        bundle: bundle }
+    job.ctx, job.cancel = context.WithCancel(context.Background())
+
+    select {
+        case s.queue <- job:
+        default:
+            http.Error(w, "Too many jobs are queued", http.StatusServiceUnavailable)
+            return
+    }
+
+    s.jobs = append(s.jobs, job)
+    writeJSON(w, http.StatusCreated, job.status)
+}
+
+// handleList lists every job in the order they were posted
+func (s *RenderServer) handleList(w http.ResponseWriter, r *http.Request) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    statuses := make([]JobStatus, 0, len(s.jobs))
+    for _, job := range s.jobs {
+        statuses = append(statuses, job.status)
+    }
+
+    writeJSON(w, http.StatusOK, statuses)
+}
+
+// handleStatus returns the status of a job
+func (s *RenderServer) handleStatus(w http.ResponseWriter, id string) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if job := s.findJob(w, id); job != nil {
+        writeJSON(w, http.StatusOK, job.status)
+    }
+}
+
+// handleImage returns the latest image of a job as a png
+func (s *RenderServer) handleImage(w http.ResponseWriter, id string) {
+    s.mutex.Lock()
+    job := s.findJob(w, id)
+    var image []byte
+    if (job != nil) {
+        image = job.image
+    }
+    s.mutex.Unlock()
+
+    if (job == nil) {
+        return
+    }
+    if (image == nil) {
+        http.Error(w, "The job has no image yet", http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Cache-Control", "no-store")
+    w.Write(image)
+}
+
+// handleCancel cancels a queued job or stops a rendering job where it is
+func (s *RenderServer) handleCancel(w http.ResponseWriter, id string) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    job := s.findJob(w, id)
+    if (job == nil) {
+        return
+    }
+
+    switch job.status.State {
+        case JobQueued, JobRendering:
+            job.status.State = JobCancelled
+            job.cancel()
+    }
+
+    writeJSON(w, http.StatusOK, job.status)
+}
+
+// handleIndex serves a page that lists the jobs and refreshes their images
+func (s *RenderServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    fmt.Fprint(w, serverIndexPage)
+}
+
+// checkRenderBundle returns an error if a bundle would fail to import or never finish rendering.
+// The ray tracer stops on bad files, so the bundle is checked with the validate command's rules here where the server can turn the job away instead
+func checkRenderBundle(bundle renderBundle) error {
+    parts := []struct {
+        name string
+        check func(v *validator)
+    } {
+        { "Config", func(v *validator) { v.checkConfig(bundle.Config) } },
+        { "Scene", func(v *validator) { v.checkScene(bundle.Scene, ".") } },
+        { "Camera", func(v *validator) { v.checkCameraFile(bundle.Camera) } } }
+
+    for _, part := range parts {
+        var v validator
+        part.check(&v)
+        if (len(v.problems) > 0) {
+            messages := make([]string, len(v.problems))
+            for i, problem := range v.problems {
+                messages[i] = part.name + ": " + problem.String()
+            }
+            return errors.New(strings.Join(messages, "\n"))
+        }
+    }
+
+    var config Config
+    if err := json.Unmarshal(bundle.Config, &config); err != nil {
+        return fmt.Errorf("Config: %v", err)
+    }
+
+    if (bundle.Samples == 0 && config.MaxAntialiasRays == 0) {
+        return fmt.Errorf("Samples: the job gives no Samples and the config no MaxAntialiasRays, the render would never finish")
+    }
+
+    if (false == config.CropWindow.IsEmpty()) {
+        crop := config.CropWindow
+        if (crop.Normalized) {
+            crop.MinX *= float32(config.WidthInPixels)
+            crop.MaxX *= float32(config.WidthInPixels)
+            crop.MinY *= float32(config.HeightInPixels)
+            crop.MaxY *= float32(config.HeightInPixels)
+        }
+
+        if (crop.MaxX <= 0 || crop.MaxY <= 0 || crop.MinX >= float32(config.WidthInPixels) || crop.MinY >= float32(config.HeightInPixels)) {
+            return fmt.Errorf("Config.CropWindow: does not overlap the image")
+        }
+    }
+
+    return nil
+}
+
+// serverIndexPage lists the jobs and reloads their images every second
+const serverIndexPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>Ray tracer jobs</title>
+<style>
+body { font-family: sans-serif; background: #222; color: #ddd; }
+.job { display: inline-block; margin: 8px; vertical-align: top; }
+.job img { display: block; max-width: 480px; background: #000; }
+button { margin-left: 8px; }
+</style>
+</head>
+<body>
+<h1>Ray tracer jobs</h1>
+<div id="jobs">No jobs yet, POST a bundle of Config, Scene and Camera json to /jobs</div>
+<script>
+function refresh() {
+    fetch("jobs").then(function(response) { return response.json(); }).then(function(jobs) {
+        if (jobs.length == 0) {
+            return;
+        }
+
+        var container = document.getElementById("jobs");
+        container.textContent = "";
+        jobs.slice().reverse().forEach(function(job) {
+            var div = document.createElement("div");
+            div.className = "job";
+
+            var title = document.createElement("div");
+            title.textContent = "Job " + job.ID + ": " + job.State + ", " + job.SamplesPerPixel + " of " + job.TargetSamples + " samples (" + Math.round(job.Progress * 100) + "%)";
+            if (job.State == "queued" || job.State == "rendering") {
+                var cancel = document.createElement("button");
+                cancel.textContent = "Cancel";
+                cancel.onclick = function() { fetch("jobs/" + job.ID + "/cancel", { method: "POST" }).then(refresh); };
+                title.appendChild(cancel);
+            }
+            div.appendChild(title);
+
+            if (job.SamplesPerPixel > 0) {
+                var img = document.createElement("img");
+                img.src = "jobs/" + job.ID + "/image.png?samples=" + job.SamplesPerPixel;
+                div.appendChild(img);
+            }
+
+            container.appendChild(div);
+        });
+    });
+}
+
+refresh();
+setInterval(refresh, 1000);
+</script>
+</body>
+</html>
+`
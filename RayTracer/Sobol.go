@@ -0,0 +1,119 @@
+package raytracer
+
+import
+(
+    "math/bits"
+    "sync"
+)
+
+// SobolSampler draws samples from an Owen-scrambled Sobol sequence.
+// Dimensions are handed out in groups of four, each group using a differently shuffled and scrambled copy of the first four Sobol dimensions, see Burley's "Practical Hash-based Owen Scrambling"
+type SobolSampler struct {
+    samplerState
+}
+
+// sobolDimensionsPerGroup is the number of Sobol dimensions used before the sequence is reshuffled
+const sobolDimensionsPerGroup = 4
+
+// sobolPolynomial describes the primitive polynomial and initial direction numbers of a Sobol dimension
+type sobolPolynomial struct {
+    degree uint32
+    coefficients uint32
+    initial []uint32
+}
+
+// sobolPolynomials are the Joe-Kuo parameters for dimensions two through four, dimension one is the van der Corput sequence
+var sobolPolynomials = []sobolPolynomial {
+    { degree: 1, coefficients: 0, initial: []uint32{ 1 } },
+    { degree: 2, coefficients: 1, initial: []uint32{ 1, 3 } },
+    { degree: 3, coefficients: 1, initial: []uint32{ 1, 3, 1 } } }
+
+var sobolDirections [sobolDimensionsPerGroup][32]uint32
+var sobolDirectionsOnce sync.Once
+
+// generateSobolDirections builds the direction numbers for the first four Sobol dimensions
+func generateSobolDirections() {
+    for i := uint32(0); i < 32; i++ {
+        sobolDirections[0][i] = 1 << (31 - i)
+    }
+
+    for d, poly := range sobolPolynomials {
+        v := &sobolDirections[d + 1]
+        s := poly.degree
+
+        for i := uint32(0); i < s; i++ {
+            v[i] = poly.initial[i] << (31 - i)
+        }
+
+        for i := s; i < 32; i++ {
+            v[i] = v[i - s] ^ (v[i - s] >> s)
+            for k := uint32(1); k < s; k++ {
+                if ((poly.coefficients >> (s - 1 - k)) & 1 == 1) {
+                    v[i] ^= v[i - k]
+                }
+            }
+        }
+    }
+}
+
+// sobolSample returns dimension d of the Sobol sequence at index as a 32 bit fixed point value
+func sobolSample(index, d uint32) uint32 {
+    sobolDirectionsOnce.Do(generateSobolDirections)
+
+    result := uint32(0)
+    for i := 0; index != 0; i++ {
+        if (index & 1 == 1) {
+            result ^= sobolDirections[d][i]
+        }
+
+        index >>= 1
+    }
+
+    return result
+}
+
+// laineKarrasPermutation scrambles the bits of x so that each bit only depends on the bits below it
+func laineKarrasPermutation(x, seed uint32) uint32 {
+    x += seed
+    x ^= x * 0x6c50b47c
+    x ^= x * 0xb82f1e52
+    x ^= x * 0xc7afe638
+    x ^= x * 0x8d22f6e6
+    return x
+}
+
+// nestedUniformScramble performs a base two Owen scramble of the fixed point value x
+func nestedUniformScramble(x, seed uint32) uint32 {
+    x = bits.Reverse32(x)
+    x = laineKarrasPermutation(x, seed)
+    return bits.Reverse32(x)
+}
+
+// owenScrambledSobol returns dimension d of the Sobol sequence at index, shuffled and scrambled by seed
+func owenScrambledSobol(index, d, seed uint32) float32 {
+    group := d / sobolDimensionsPerGroup
+    shuffledIndex := nestedUniformScramble(index, mixBits(seed, group))
+    value := sobolSample(shuffledIndex, d % sobolDimensionsPerGroup)
+    value = nestedUniformScramble(value, mixBits(seed, d, 0x2c6fe96e))
+    return hashToFloat(value)
+}
+
+// Get1D returns the next Sobol dimension for the current sample
+func (s *SobolSampler) Get1D() float32 {
+    return owenScrambledSobol(s.sampleIndex, s.nextDimension(), s.pixelHash)
+}
+
+// Get2D returns the next two Sobol dimensions, aligned so both come from the same pair of the group
+func (s *SobolSampler) Get2D() (float32, float32) {
+    if (s.dimension % 2 == 1) {
+        s.nextDimension()
+    }
+
+    return s.Get1D(), s.Get1D()
+}
+
+// Clone returns a copy of the Sobol sampler
+func (s *SobolSampler) Clone() Sampler {
+    c := *s
+    return &c
+}
@@ -2,7 +2,6 @@ package raytracer
 
 import
 (
-    "image/color"
     "math"
 )
 
@@ -11,6 +10,14 @@ type Sphere struct {
     Origin Vector3
     Radius float32 
     Properties Material
+    
+    // Motion is how far the sphere moves while the camera shutter is open, it is blurred along this path
+    Motion Vector3
+}
+
+// centerAt returns the center of the sphere at time t during the exposure
+func (s Sphere) centerAt(t float32) Vector3 {
+    return s.Origin.Add(s.Motion.Scale(t))
 }
 
 // TestIntersection will test for an intersection between the sphere and ray
@@ -18,7 +25,8 @@ func (s Sphere) TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionR
     var record IntersectionRecord
     
     // Make a vector from the sphere origin to the ray origin
-    m := r.Origin.Subtract(s.Origin)
+    center := s.centerAt(r.Time)
+    m := r.Origin.Subtract(center)
     
     // Dot the direction of the ray and the direction of m.  They must face opposite ways for their to be collision, ie they must have a 0 or negative dot product.
     b := m.Dot(r.Direction)
@@ -43,57 +51,31 @@ func (s Sphere) TestIntersection(r Ray, tMin, tMax float32) (bool, IntersectionR
     }
     
     record.Point = r.PointOnRay(record.T)
-    record.Normal = record.Point.Subtract(s.Origin).UnitVector()
+    record.Normal = record.Point.Subtract(center).UnitVector()
+    record.U, record.V = sphereUV(record.Normal)
     record.Object = s
+    record.Material = s.Properties
     
     return true, record
 }
 
-// GetColor gets the color at a collision point
-func (s Sphere) GetColor(r Ray, i IntersectionRecord, bounces uint32) color.RGBA {    
-    // If the ray has bounced more times than the provided amout return this objects' color
-    if (bounces > Settings.MaxBounces) {
-        return color.RGBA {
-            R: 255,
-            G: 255,
-            B: 255,
-            A: 255 }
-    }
-    
-    var bouncedRay Ray
-    var c color.RGBA
-    
-    if (false == s.Properties.IsEmissive()) {
-        var red, green, blue float32
-    
-        // Bounce multiple diffuse rays
-        for rays := uint32(0); rays < Settings.MaxRaysPerBounce; rays++ {
-            bouncedRay = s.Properties.Scatter(r, i)
-            
-            color := ShootRay(bouncedRay, Scene, bounces + 1)
-            red += float32(color.R)
-            green += float32(color.G)
-            blue += float32(color.B)
-        }
-        
-        // Average the color
-        red /= float32(Settings.MaxRaysPerBounce)
-        green /= float32(Settings.MaxRaysPerBounce)
-        blue /= float32(Settings.MaxRaysPerBounce)
-        
-        // Set the averaged color
-        c.R = uint8(red)
-        c.G = uint8(green)
-        c.B = uint8(blue)
-        c.A = uint8(255)        
-        
-        // Multiply this objects color with the incoming color
-        c = AsVector3(c).Multiply(s.Properties.GetAttenuation()).AsColor()    
-    } else {
-        c = s.Properties.GetEmission().AsColor()
-    }
-    
-    return c
+// BoundingBox returns the box enclosing the sphere over its whole motion
+func (s Sphere) BoundingBox() BoundingBox {
+    r := NewVector3(s.Radius, s.Radius, s.Radius)
+    start := BoundingBox {
+        Min: s.Origin.Subtract(r),
+        Max: s.Origin.Add(r) }
+    end := BoundingBox {
+        Min: s.centerAt(1.0).Subtract(r),
+        Max: s.centerAt(1.0).Add(r) }
+    return start.Union(end)
+}
+
+// sphereUV returns the longitude and latitude of the unit normal n mapped to [0, 1]
+func sphereUV(n Vector3) (float32, float32) {
+    u := 0.5 + math.Atan2(float64(n.Z), float64(n.X)) / (2.0 * math.Pi)
+    v := 0.5 - math.Asin(math.Max(-1.0, math.Min(1.0, float64(n.Y)))) / math.Pi
+    return float32(u), float32(v)
 }
 
 func deserializeSphere(object map[string]interface{}) (Sphere, bool) {
@@ -112,6 +94,16 @@ func deserializeSphere(object map[string]interface{}) (Sphere, bool) {
                     }
                 }
                 
+            case "Motion":
+                motion, ok := object.(map[string]interface{})
+                if (true == ok) {
+                    sphere.Motion, ok = deserializeVector3(motion)
+                }
+                if (false == ok) {
+                    validSphere = false
+                    break;
+                }
+                
             case "Radius":
                 radius, ok := object.(float64)
                 if (true == ok) {
@@ -140,4 +132,15 @@ func deserializeSphere(object map[string]interface{}) (Sphere, bool) {
     }
     
     return sphere, validSphere
-}
\ No newline at end of file
+}
+
+// material returns the sphere's material
+func (s Sphere) material() Material {
+    return s.Properties
+}
+
+// withMaterial returns a copy of the sphere with another material
+func (s Sphere) withMaterial(material Material) CollidableObject {
+    s.Properties = material
+    return s
+}
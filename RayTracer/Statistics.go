@@ -0,0 +1,209 @@
+package raytracer
+
+import
+(
+    "encoding/json"
+    "fmt"
+    "image"
+    "io"
+    "os"
+    "sort"
+    "text/tabwriter"
+    "time"
+)
+
+// summaryObjects is the number of objects listed in a statistics summary, the json has all of them
+const summaryObjects = 10
+
+// renderStatistics are the detailed counts kept while rendering a tile when a renderer collects statistics
+type renderStatistics struct {
+    // PrimaryRays is the number of camera rays
+    PrimaryRays uint64
+
+    // PathVertices is the number of surfaces hit by camera and bounce rays, shadow and occlusion rays are not counted
+    PathVertices uint64
+
+    // ObjectTests and ObjectHits count the intersection tests of each object and how many of them found a closer hit, by the object's index in the scene
+    ObjectTests, ObjectHits []uint64
+
+    // TileTimes is the time taken by each tile
+    TileTimes []tileTime
+}
+
+// tileTime is the time taken to render a tile
+type tileTime struct {
+    Tile image.Rectangle
+    Duration time.Duration
+}
+
+// RenderStatistics describe where the time of a render went
+type RenderStatistics struct {
+    Seconds float64
+
+    // Rays counts every ray tested against the scene.  SecondaryRays are the bounce, shadow and occlusion rays, everything but the PrimaryRays from the camera
+    Rays, PrimaryRays, SecondaryRays uint64
+    RaysPerSecond float64
+
+    // AveragePathDepth is the average number of surfaces hit per camera ray
+    AveragePathDepth float64
+
+    // Objects are sorted by the number of intersection tests, the most tested first
+    Objects []ObjectStatistics
+
+    // Tiles are sorted top to bottom, left to right.  A tile rendered in several passes has the time of all of them
+    Tiles []TileStatistics
+}
+
+// ObjectStatistics count the intersection tests of a scene object and how many of them found a hit closer than any found before
+type ObjectStatistics struct {
+    Name string
+    Tests, Hits uint64
+}
+
+// TileStatistics is the time spent rendering a tile
+type TileStatistics struct {
+    MinX, MinY, MaxX, MaxY int
+    Seconds float64
+}
+
+// newRenderStatistics creates empty statistics for a scene with the given number of objects
+func newRenderStatistics(objects int) *renderStatistics {
+    return &renderStatistics {
+        ObjectTests: make([]uint64, objects),
+        ObjectHits: make([]uint64, objects) }
+}
+
+// add adds the counts in other to s
+func (s *renderStatistics) add(other *renderStatistics) {
+    s.PrimaryRays += other.PrimaryRays
+    s.PathVertices += other.PathVertices
+
+    if (len(s.ObjectTests) < len(other.ObjectTests)) {
+        s.ObjectTests = append(s.ObjectTests, make([]uint64, len(other.ObjectTests) - len(s.ObjectTests))...)
+        s.ObjectHits = append(s.ObjectHits, make([]uint64, len(other.ObjectHits) - len(s.ObjectHits))...)
+    }
+    for i := range other.ObjectTests {
+        s.ObjectTests[i] += other.ObjectTests[i]
+        s.ObjectHits[i] += other.ObjectHits[i]
+    }
+
+    s.TileTimes = append(s.TileTimes, other.TileTimes...)
+}
+
+// Statistics returns the statistics of the last render, or of the render so far while one is running.
+// Only the time and ray counts are known unless CollectStatistics was set
+func (r *Renderer) Statistics() RenderStatistics {
+    elapsed := r.progress.elapsedTime()
+
+    r.progress.mutex.Lock()
+    defer r.progress.mutex.Unlock()
+
+    counters := r.progress.counters
+    statistics := RenderStatistics {
+        Seconds: elapsed.Seconds(),
+        Rays: counters.Rays }
+    if (elapsed > 0) {
+        statistics.RaysPerSecond = float64(counters.Rays) / elapsed.Seconds()
+    }
+
+    detail := counters.Statistics
+    if (detail == nil) {
+        return statistics
+    }
+
+    statistics.PrimaryRays = detail.PrimaryRays
+    statistics.SecondaryRays = counters.Rays - detail.PrimaryRays
+    if (detail.PrimaryRays > 0) {
+        statistics.AveragePathDepth = float64(detail.PathVertices) / float64(detail.PrimaryRays)
+    }
+
+    for i, tests := range detail.ObjectTests {
+        if (i < len(Scene.Scene.names)) {
+            statistics.Objects = append(statistics.Objects, ObjectStatistics {
+                Name: Scene.Scene.names[i],
+                Tests: tests,
+                Hits: detail.ObjectHits[i] })
+        }
+    }
+    sort.SliceStable(statistics.Objects, func(a, b int) bool {
+        return statistics.Objects[a].Tests > statistics.Objects[b].Tests
+    })
+
+    tileDurations := make(map[image.Rectangle]time.Duration)
+    for _, tile := range detail.TileTimes {
+        tileDurations[tile.Tile] += tile.Duration
+    }
+    for tile, duration := range tileDurations {
+        statistics.Tiles = append(statistics.Tiles, TileStatistics {
+            MinX: tile.Min.X,
+            MinY: tile.Min.Y,
+            MaxX: tile.Max.X,
+            MaxY: tile.Max.Y,
+            Seconds: duration.Seconds() })
+    }
+    sort.Slice(statistics.Tiles, func(a, b int) bool {
+        if (statistics.Tiles[a].MinY != statistics.Tiles[b].MinY) {
+            return statistics.Tiles[a].MinY < statistics.Tiles[b].MinY
+        }
+        return statistics.Tiles[a].MinX < statistics.Tiles[b].MinX
+    })
+
+    return statistics
+}
+
+// WriteSummary writes the statistics as a short table for people to read
+func (s RenderStatistics) WriteSummary(output io.Writer) {
+    table := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(table, "Render time\t%.2f s\n", s.Seconds)
+    fmt.Fprintf(table, "Rays\t%v (%v/s)\n", formatCount(float64(s.Rays)), formatCount(s.RaysPerSecond))
+
+    if (s.PrimaryRays > 0) {
+        fmt.Fprintf(table, "Primary rays\t%v\n", formatCount(float64(s.PrimaryRays)))
+        fmt.Fprintf(table, "Secondary rays\t%v\n", formatCount(float64(s.SecondaryRays)))
+        fmt.Fprintf(table, "Average path depth\t%.2f\n", s.AveragePathDepth)
+    }
+
+    if (len(s.Tiles) > 0) {
+        total := 0.0
+        slowest := s.Tiles[0]
+        for _, tile := range s.Tiles {
+            total += tile.Seconds
+            if (tile.Seconds > slowest.Seconds) {
+                slowest = tile
+            }
+        }
+
+        fmt.Fprintf(table, "Tiles\t%v, %.3f s on average, slowest %v,%v-%v,%v took %.3f s\n",
+            len(s.Tiles), total / float64(len(s.Tiles)), slowest.MinX, slowest.MinY, slowest.MaxX, slowest.MaxY, slowest.Seconds)
+    }
+
+    if (len(s.Objects) > 0) {
+        fmt.Fprintf(table, "\nObject\tTests\tHits\tHit rate\n")
+        for i, object := range s.Objects {
+            if (i == summaryObjects) {
+                fmt.Fprintf(table, "%v more\t\t\t\n", len(s.Objects) - summaryObjects)
+                break
+            }
+
+            rate := 0.0
+            if (object.Tests > 0) {
+                rate = float64(object.Hits) / float64(object.Tests) * 100.0
+            }
+            fmt.Fprintf(table, "%v\t%v\t%v\t%.1f%%\n", object.Name, formatCount(float64(object.Tests)), formatCount(float64(object.Hits)), rate)
+        }
+    }
+
+    table.Flush()
+}
+
+// Export writes the statistics to filename as json
+func (s RenderStatistics) Export(filename string) {
+    statisticsString, err := json.MarshalIndent(s, "", "    ")
+    checkError(err)
+
+    statisticsFile, err := os.Create(filename)
+    checkError(err)
+    defer statisticsFile.Close()
+
+    statisticsFile.Write(statisticsString)
+}
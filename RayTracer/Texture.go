@@ -0,0 +1,73 @@
+package raytracer
+
+import
+(
+    "image"
+    "image/color"
+    "image/draw"
+    "math"
+)
+
+// Texture is an image wrapped around a mesh using its texture coordinates, Pixels holds 8 bit red, green, blue and alpha values row by row from the top
+type Texture struct {
+    Width, Height int
+    Pixels []byte
+}
+
+// NewTexture creates a texture from an image
+func NewTexture(img image.Image) *Texture {
+    bounds := img.Bounds()
+    rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+    draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+    return &Texture {
+        Width: bounds.Dx(),
+        Height: bounds.Dy(),
+        Pixels: rgba.Pix }
+}
+
+// texel returns the color of the pixel at x, y wrapping around the edges
+func (t Texture) texel(x, y int) Vector3 {
+    x = ((x % t.Width) + t.Width) % t.Width
+    y = ((y % t.Height) + t.Height) % t.Height
+    offset := (y * t.Width + x) * 4
+    return AsVector3(color.RGBA{ R: t.Pixels[offset], G: t.Pixels[offset + 1], B: t.Pixels[offset + 2], A: t.Pixels[offset + 3] })
+}
+
+// Sample returns the color of the texture at u, v blending the nearest four pixels, 0, 0 is the upper left corner and the texture repeats outside of 0 to 1
+func (t Texture) Sample(u, v float32) Vector3 {
+    if (t.Width == 0 || t.Height == 0) {
+        return NewVector3(1.0, 1.0, 1.0)
+    }
+
+    x := float64(u) * float64(t.Width) - 0.5
+    y := float64(v) * float64(t.Height) - 0.5
+    x0 := math.Floor(x)
+    y0 := math.Floor(y)
+    fx := float32(x - x0)
+    fy := float32(y - y0)
+
+    top := t.texel(int(x0), int(y0)).Scale(1.0 - fx).Add(t.texel(int(x0) + 1, int(y0)).Scale(fx))
+    bottom := t.texel(int(x0), int(y0) + 1).Scale(1.0 - fx).Add(t.texel(int(x0) + 1, int(y0) + 1).Scale(fx))
+    return top.Scale(1.0 - fy).Add(bottom.Scale(fy))
+}
+
+// texturedMaterial returns the material with its attenuation multiplied by a texture color, emissive materials have their emission multiplied instead
+func texturedMaterial(material Material, c Vector3) Material {
+    switch m := material.(type) {
+        case Lambertian:
+            m.Attenuation = m.Attenuation.Multiply(c)
+            return m
+        case Metal:
+            m.Attenuation = m.Attenuation.Multiply(c)
+            return m
+        case Dielectric:
+            m.Attenuation = m.Attenuation.Multiply(c)
+            return m
+        case Emissive:
+            m.Emission = m.Emission.Multiply(c)
+            return m
+    }
+
+    return material
+}
@@ -0,0 +1,195 @@
+package raytracer
+
+import
+(
+    "log"
+    "math"
+)
+
+// transformMatrix is a 4x4 matrix that moves, rotates and scales points, stored column by column as glTF does
+type transformMatrix [16]float64
+
+// identityTransform is the matrix that leaves points where they are
+var identityTransform = transformMatrix{ 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1 }
+
+// rotationMatrix returns the rotation by degrees.X around the X axis, then degrees.Y around Y and degrees.Z around Z
+func rotationMatrix(degrees Vector3) transformMatrix {
+    rotation := identityTransform
+    for axis := 0; axis < 3; axis++ {
+        angle := float64(ConvertDegreesToRadians(component(degrees, axis)))
+        if (angle == 0.0) {
+            continue
+        }
+
+        // The two axes the rotation turns into each other
+        a, b := (axis + 1) % 3, (axis + 2) % 3
+        turn := identityTransform
+        turn[a * 4 + a], turn[b * 4 + a] = math.Cos(angle), -math.Sin(angle)
+        turn[a * 4 + b], turn[b * 4 + b] = math.Sin(angle), math.Cos(angle)
+        rotation = turn.multiply(rotation)
+    }
+
+    return rotation
+}
+
+// quaternionMatrix returns the rotation of the unit quaternion x, y, z, w
+func quaternionMatrix(x, y, z, w float64) transformMatrix {
+    return transformMatrix {
+        1 - 2 * (y * y + z * z), 2 * (x * y + z * w), 2 * (x * z - y * w), 0,
+        2 * (x * y - z * w), 1 - 2 * (x * x + z * z), 2 * (y * z + x * w), 0,
+        2 * (x * z + y * w), 2 * (y * z - x * w), 1 - 2 * (x * x + y * y), 0,
+        0, 0, 0, 1 }
+}
+
+// multiply returns m * a, the transform that applies a and then m
+func (m transformMatrix) multiply(a transformMatrix) transformMatrix {
+    var result transformMatrix
+    for column := 0; column < 4; column++ {
+        for row := 0; row < 4; row++ {
+            sum := 0.0
+            for k := 0; k < 4; k++ {
+                sum += m[k * 4 + row] * a[column * 4 + k]
+            }
+            result[column * 4 + row] = sum
+        }
+    }
+
+    return result
+}
+
+// transformPoint moves a point by the transform
+func (m transformMatrix) transformPoint(x, y, z float64) Vector3 {
+    return NewVector3(
+        float32(m[0] * x + m[4] * y + m[8] * z + m[12]),
+        float32(m[1] * x + m[5] * y + m[9] * z + m[13]),
+        float32(m[2] * x + m[6] * y + m[10] * z + m[14]))
+}
+
+// transformDirection rotates and scales a direction by the transform without moving it
+func (m transformMatrix) transformDirection(x, y, z float64) Vector3 {
+    return NewVector3(
+        float32(m[0] * x + m[4] * y + m[8] * z),
+        float32(m[1] * x + m[5] * y + m[9] * z),
+        float32(m[2] * x + m[6] * y + m[10] * z))
+}
+
+// determinant returns the determinant of the rotation and scale part of the transform, it is negative when the transform mirrors
+func (m transformMatrix) determinant() float64 {
+    return m[0] * (m[5] * m[10] - m[9] * m[6]) -
+        m[4] * (m[1] * m[10] - m[9] * m[2]) +
+        m[8] * (m[1] * m[6] - m[5] * m[2])
+}
+
+// normalMatrix returns the transform for normals, the inverse transpose of the rotation and scale part scaled by the size of the determinant.
+// The scale does not matter as normals are normalized after they are transformed
+func (m transformMatrix) normalMatrix() transformMatrix {
+    sign := 1.0
+    if (m.determinant() < 0.0) {
+        sign = -1.0
+    }
+
+    return transformMatrix {
+        sign * (m[5] * m[10] - m[6] * m[9]), sign * (m[6] * m[8] - m[4] * m[10]), sign * (m[4] * m[9] - m[5] * m[8]), 0,
+        sign * (m[2] * m[9] - m[1] * m[10]), sign * (m[0] * m[10] - m[2] * m[8]), sign * (m[1] * m[8] - m[0] * m[9]), 0,
+        sign * (m[1] * m[6] - m[2] * m[5]), sign * (m[2] * m[4] - m[0] * m[6]), sign * (m[0] * m[5] - m[1] * m[4]), 0,
+        0, 0, 0, 1 }
+}
+
+// scaleFactor returns how much the transform scales lengths, for a transform that scales each axis differently it is the average scale
+func (m transformMatrix) scaleFactor() float32 {
+    return float32(math.Cbrt(math.Abs(m.determinant())))
+}
+
+// transformCamera moves and turns camera settings by the transform
+func (m transformMatrix) transformCamera(config cameraConfig) cameraConfig {
+    up := config.Up
+    if (up == Vector3{}) {
+        up = defaultUpVector
+    }
+
+    config.LookFrom = m.transformPoint(float64(config.LookFrom.X), float64(config.LookFrom.Y), float64(config.LookFrom.Z))
+    config.LookAt = m.transformPoint(float64(config.LookAt.X), float64(config.LookAt.Y), float64(config.LookAt.Z))
+    config.Up = m.transformDirection(float64(up.X), float64(up.Y), float64(up.Z)).UnitVector()
+    config.FocusDistance *= m.scaleFactor()
+    config.OrthographicHeight *= m.scaleFactor()
+    return config
+}
+
+// transformVector moves a point by the transform
+func (m transformMatrix) transformVector(v Vector3) Vector3 {
+    return m.transformPoint(float64(v.X), float64(v.Y), float64(v.Z))
+}
+
+// transformMotion turns and scales a direction or distance by the transform without moving it
+func (m transformMatrix) transformMotion(v Vector3) Vector3 {
+    return m.transformDirection(float64(v.X), float64(v.Y), float64(v.Z))
+}
+
+// transformObject returns a copy of a sphere, mesh or point cloud moved, turned and scaled by the transform.
+// Spheres and points stay round so their radius is scaled by scaleFactor
+func (m transformMatrix) transformObject(name string, obj CollidableObject) CollidableObject {
+    switch object := obj.(type) {
+        case Sphere:
+            object.Origin = m.transformVector(object.Origin)
+            object.Motion = m.transformMotion(object.Motion)
+            object.Radius *= m.scaleFactor()
+            return object
+
+        case *Mesh:
+            positions, normals, indices := m.transformMeshData(object.Positions, object.Normals, object.Indices)
+            return NewMesh(positions, normals, object.UVs, indices, object.Properties, object.Texture)
+
+        case *PointCloud:
+            positions, radii := m.transformPointData(object.Positions, object.Radii)
+            return NewPointCloud(positions, radii, object.Radius * m.scaleFactor(), object.Colors, object.Properties)
+    }
+
+    log.Fatalf("Object %q cannot be transformed, only spheres, meshes and point clouds can", name)
+    return nil
+}
+
+// transformMeshData returns copies of a mesh's positions, normals and indices moved, turned and scaled by the transform
+func (m transformMatrix) transformMeshData(positions, normals []Vector3, indices []uint32) ([]Vector3, []Vector3, []uint32) {
+    transformed := make([]Vector3, len(positions))
+    for i, position := range positions {
+        transformed[i] = m.transformVector(position)
+    }
+
+    var transformedNormals []Vector3
+    if (len(normals) > 0) {
+        normalMatrix := m.normalMatrix()
+        transformedNormals = make([]Vector3, len(normals))
+        for i, normal := range normals {
+            transformedNormals[i] = normalMatrix.transformMotion(normal).UnitVector()
+        }
+    }
+
+    // A mirroring transform turns the triangles inside out, swapping two corners turns them back
+    if (m.determinant() < 0.0) {
+        indices = append([]uint32{}, indices...)
+        for i := 0; i + 2 < len(indices); i += 3 {
+            indices[i + 1], indices[i + 2] = indices[i + 2], indices[i + 1]
+        }
+    }
+
+    return transformed, transformedNormals, indices
+}
+
+// transformPointData returns copies of a point cloud's positions and radii moved and scaled by the transform
+func (m transformMatrix) transformPointData(positions []Vector3, radii []float32) ([]Vector3, []float32) {
+    transformed := make([]Vector3, len(positions))
+    for i, position := range positions {
+        transformed[i] = m.transformVector(position)
+    }
+
+    var scaled []float32
+    if (len(radii) > 0) {
+        scale := m.scaleFactor()
+        scaled = make([]float32, len(radii))
+        for i, radius := range radii {
+            scaled[i] = radius * scale
+        }
+    }
+
+    return transformed, scaled
+}
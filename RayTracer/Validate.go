@@ -0,0 +1,908 @@
+package raytracer
+
+import
+(
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "image/color"
+    "io/ioutil"
+    "math"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+// ValidationProblem is something wrong with a config, camera or scene file.  Path is where in the file it is, such as sphere3.Properties.Fuzziness, and empty for the whole file
+type ValidationProblem struct {
+    Path string
+    Message string
+}
+
+// String returns the problem as path: message
+func (p ValidationProblem) String() string {
+    if (p.Path == "") {
+        return p.Message
+    }
+
+    return p.Path + ": " + p.Message
+}
+
+// knownOptions are the values the string options accept, by the schema name of their type and the field name
+var knownOptions = map[string][]string {
+    "Config.Sampler": { IndependentSamplerType, StratifiedSamplerType, HaltonSamplerType, SobolSamplerType, BlueNoiseSamplerType },
+    "Config.Integrator": { PathIntegratorType, WhittedIntegratorType, AmbientOcclusionIntegratorType, NormalsIntegratorType, UVIntegratorType, DepthIntegratorType, CostIntegratorType },
+    "Config.Filter": { BoxFilterType, TentFilterType, GaussianFilterType, MitchellFilterType, LanczosFilterType },
+    "Config.CropOutput": { CroppedOutput, FullFrameOutput },
+    "Camera.Type": { PerspectiveCameraType, OrthographicCameraType, FisheyeCameraType, EquirectangularCameraType },
+    "Camera.FisheyeMapping": { EquidistantFisheyeMapping, EquisolidFisheyeMapping },
+    "Camera.FovAxis": { VerticalFovAxis, HorizontalFovAxis } }
+
+// requiredFields are the fields that have no useful default and must be given, by the schema name of their type.
+// A config file must give its resolution too, which the settings of a scene document may leave to a config file or the document including them
+var requiredFields = map[string][]string {
+    "Camera": { "LookFrom", "LookAt" },
+    "Sphere": { "Origin", "Radius", "Properties" },
+    "Mesh": { "Positions", "Indices", "Properties" },
+    "PointCloud": { "Positions", "Properties" },
+    "SceneDocument": { "Version" },
+    "DocumentObject": { "Origin", "Radius" },
+    "DocumentLight": { "Origin", "Radius", "Emission" },
+    "DocumentMesh": { "Positions", "Indices" },
+    "DocumentPointCloud": { "Positions" },
+    "DocumentModel": { "File" },
+    "DocumentInclude": { "File" } }
+
+// valueRule is the range of values a number, or each of X, Y and Z of a vector, must be in to make physical sense
+type valueRule struct {
+    minimum, maximum float64
+    hasMinimum, hasMaximum bool
+
+    // exclusiveMinimum is true when the minimum itself is not allowed
+    exclusiveMinimum bool
+
+    // reason says what is wrong with a value outside of the range
+    reason string
+}
+
+// atLeast returns a rule for values of minimum or more
+func atLeast(minimum float64, reason string) valueRule {
+    return valueRule{ minimum: minimum, hasMinimum: true, reason: reason }
+}
+
+// above returns a rule for values greater than minimum
+func above(minimum float64, reason string) valueRule {
+    return valueRule{ minimum: minimum, hasMinimum: true, exclusiveMinimum: true, reason: reason }
+}
+
+// between returns a rule for values from minimum to maximum
+func between(minimum, maximum float64, reason string) valueRule {
+    return valueRule{ minimum: minimum, maximum: maximum, hasMinimum: true, hasMaximum: true, reason: reason }
+}
+
+// check returns what is wrong with a value, or an empty string when it is in range
+func (r valueRule) check(value float64) string {
+    if ((r.hasMinimum && (value < r.minimum || (r.exclusiveMinimum && value == r.minimum))) || (r.hasMaximum && value > r.maximum)) {
+        return fmt.Sprintf("%v, got %v", r.reason, value)
+    }
+
+    return ""
+}
+
+// valueRules are the ranges of numeric fields by the schema name of their type and the field name, a Material rule applies to every material
+var valueRules = map[string]valueRule {
+    "Config.WidthInPixels": atLeast(1, "the resolution must be at least 1 pixel"),
+    "Config.HeightInPixels": atLeast(1, "the resolution must be at least 1 pixel"),
+    "Config.MaxAntialiasRays": atLeast(1, "at least 1 sample per pixel is needed to see anything"),
+    "Config.SkyColorTop": atLeast(0, "colors cannot be negative"),
+    "Config.SkyColorBottom": atLeast(0, "colors cannot be negative"),
+    "Config.AmbientOcclusionDistance": atLeast(0, "a distance cannot be negative"),
+    "Config.DebugDepthRange": atLeast(0, "a distance cannot be negative"),
+    "Config.FilterRadius": atLeast(0, "a filter radius cannot be negative"),
+    "Config.SkyLuminance": atLeast(0, "a luminance cannot be negative"),
+    "Config.DebugCostRange": atLeast(0, "a cost range cannot be negative"),
+    "Camera.ISO": atLeast(0, "an ISO cannot be negative"),
+    "Camera.ShutterSpeed": atLeast(0, "a shutter speed cannot be negative"),
+    "Camera.FNumber": atLeast(0, "an f-number cannot be negative"),
+    "Camera.SensorHeight": atLeast(0, "a sensor height cannot be negative"),
+    "Camera.FocusDistance": atLeast(0, "a focus distance cannot be negative"),
+    "Camera.MetersPerUnit": atLeast(0, "a size cannot be negative"),
+    "Camera.WhiteBalance": atLeast(0, "a color temperature cannot be negative"),
+    "Camera.OrthographicHeight": atLeast(0, "a view height cannot be negative"),
+    "Material.Attenuation": between(0, 1, "an attenuation above 1 reflects more light than arrives and below 0 is negative light"),
+    "Material.Fuzziness": between(0, 1, "fuzziness goes from 0 for a mirror to 1"),
+    "Material.RefractiveIndex": atLeast(1, "a refractive index below 1 means light travels faster than in a vacuum"),
+    "Material.Emission": atLeast(0, "emission cannot be negative"),
+    "Sphere.Radius": above(0, "a radius must be greater than 0"),
+    "PointCloud.Radius": atLeast(0, "a radius cannot be negative"),
+    "PointCloud.Radii": above(0, "a radius must be greater than 0"),
+    "DocumentObject.Radius": above(0, "a radius must be greater than 0"),
+    "DocumentLight.Radius": above(0, "a radius must be greater than 0"),
+    "DocumentLight.Emission": atLeast(0, "emission cannot be negative"),
+    "DocumentPointCloud.Radius": atLeast(0, "a radius cannot be negative"),
+    "DocumentPointCloud.Radii": above(0, "a radius must be greater than 0"),
+    "DocumentModel.PointRadius": atLeast(0, "a radius cannot be negative"),
+    "DocumentTransform.Scale": atLeast(0, "a scale cannot be negative, mirror with a Matrix instead") }
+
+// materialFields are the fields of every kind of material by name, a material's kind is picked by which of them it has
+var materialFields = map[string]reflect.Type {
+    "Color": reflect.TypeOf(color.RGBA{}),
+    "Attenuation": reflect.TypeOf(Vector3{}),
+    "Fuzziness": reflect.TypeOf(float32(0)),
+    "RefractiveIndex": reflect.TypeOf(float32(0)),
+    "Emission": reflect.TypeOf(Vector3{}) }
+
+var materialType = reflect.TypeOf((*Material)(nil)).Elem()
+var materialDefinitionType = reflect.TypeOf(MaterialDefinition{})
+
+// schemaName returns the name a type is known by in validation rules and schemas
+func schemaName(t reflect.Type) string {
+    switch t {
+        case reflect.TypeOf(cameraConfig{}):
+            return "Camera"
+        case reflect.TypeOf(color.RGBA{}):
+            return "Color"
+    }
+
+    name := t.Name()
+    if (name == "") {
+        return ""
+    }
+    return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// jsonFields returns the exported fields of a struct by the name they have in json
+func jsonFields(t reflect.Type) (map[string]reflect.StructField, []string) {
+    fields := make(map[string]reflect.StructField)
+    var names []string
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if (field.PkgPath != "") {
+            continue
+        }
+
+        name := strings.Split(field.Tag.Get("json"), ",")[0]
+        if (name == "-") {
+            continue
+        }
+        if (name == "") {
+            name = field.Name
+        }
+        fields[name] = field
+        names = append(names, name)
+    }
+
+    return fields, names
+}
+
+// validator collects the problems found while checking a file
+type validator struct {
+    problems []ValidationProblem
+}
+
+// report adds a problem at path
+func (v *validator) report(path string, format string, arguments ...interface{}) {
+    v.problems = append(v.problems, ValidationProblem{ Path: path, Message: fmt.Sprintf(format, arguments...) })
+}
+
+// childPath returns the path of a field or map entry inside path
+func childPath(path, name string) string {
+    if (path == "") {
+        return name
+    }
+
+    return path + "." + name
+}
+
+// sortedKeys returns the keys of a json object in order so problems are always reported in the same order
+func sortedKeys(object map[string]interface{}) []string {
+    keys := make([]string, 0, len(object))
+    for key := range object {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// configRequiredFields are the fields a config file must give
+var configRequiredFields = []string{ "WidthInPixels", "HeightInPixels" }
+
+// ValidateConfig checks a config file and returns every problem found in it
+func ValidateConfig(filename string) []ValidationProblem {
+    var v validator
+    if contents, ok := v.read(filename); ok {
+        v.checkConfig(contents)
+    }
+    return v.problems
+}
+
+// checkConfig checks the contents of a config file
+func (v *validator) checkConfig(contents []byte) {
+    if value, ok := v.parse(contents); ok {
+        v.validate("", value, reflect.TypeOf(Config{}))
+        if object, ok := value.(map[string]interface{}); ok {
+            for _, required := range configRequiredFields {
+                if _, given := object[required]; false == given {
+                    v.report(required, "missing")
+                }
+            }
+        }
+    }
+}
+
+// ValidateCamera checks a camera file and returns every problem found in it
+func ValidateCamera(filename string) []ValidationProblem {
+    var v validator
+    if contents, ok := v.read(filename); ok {
+        v.checkCameraFile(contents)
+    }
+    return v.problems
+}
+
+// checkCameraFile checks the contents of a camera file
+func (v *validator) checkCameraFile(contents []byte) {
+    if value, ok := v.parse(contents); ok {
+        v.validate("", value, reflect.TypeOf(cameraConfig{}))
+        v.checkCamera("", value)
+    }
+}
+
+// ValidateScene checks a scene document or a scene file from before scene documents and returns every problem found in it
+func ValidateScene(filename string) []ValidationProblem {
+    var v validator
+    if contents, ok := v.read(filename); ok {
+        v.checkScene(contents, filepath.Dir(filename))
+    }
+    return v.problems
+}
+
+// checkScene checks the contents of a scene document or scene file, files a document refers to are relative to directory
+func (v *validator) checkScene(contents []byte, directory string) {
+    value, ok := v.parse(contents)
+    if (false == ok) {
+        return
+    }
+
+    object, ok := value.(map[string]interface{})
+    if (false == ok) {
+        v.report("", "a scene is a json object")
+        return
+    }
+
+    if _, versioned := object["Version"]; versioned {
+        v.validate("", object, reflect.TypeOf(sceneDocument{}))
+        v.checkDocument(object, directory)
+        return
+    }
+
+    // Entries of scenes from before scene documents are told apart the same way ImportSceneJSON does
+    for _, name := range sortedKeys(object) {
+        entry, ok := object[name].(map[string]interface{})
+        if (false == ok) {
+            v.report(name, "expected an object, entries that are not objects are ignored")
+            continue
+        }
+
+        if (nil != entry["Indices"]) {
+            v.validate(name, entry, reflect.TypeOf(Mesh{}))
+            v.checkMesh(name, entry)
+        } else if (nil != entry["Positions"]) {
+            v.validate(name, entry, reflect.TypeOf(PointCloud{}))
+            v.checkPointCloud(name, entry)
+        } else {
+            v.validate(name, entry, reflect.TypeOf(Sphere{}))
+        }
+    }
+}
+
+// read returns the contents of a file, a file that cannot be read is reported
+func (v *validator) read(filename string) ([]byte, bool) {
+    contents, err := ioutil.ReadFile(filename)
+    if (err != nil) {
+        v.report("", "%v", err)
+        return nil, false
+    }
+
+    return contents, true
+}
+
+// parse decodes json contents, contents that cannot be decoded are reported with the line the problem is on
+func (v *validator) parse(contents []byte) (interface{}, bool) {
+    decoder := json.NewDecoder(bytes.NewReader(contents))
+    decoder.UseNumber()
+
+    var value interface{}
+    if err := decoder.Decode(&value); err != nil {
+        if syntaxError, ok := err.(*json.SyntaxError); ok {
+            line := bytes.Count(contents[:syntaxError.Offset], []byte("\n")) + 1
+            v.report("", "line %v: not valid json: %v", line, err)
+        } else {
+            v.report("", "not valid json: %v", err)
+        }
+        return nil, false
+    }
+
+    return value, true
+}
+
+// validate checks that a decoded json value fits the Go type it is loaded into, reporting unknown fields, values of the wrong type and values out of range
+func (v *validator) validate(path string, value interface{}, t reflect.Type) {
+    // null leaves the field at its default
+    if (value == nil) {
+        return
+    }
+
+    if (t == materialType) {
+        v.validateMaterial(path, value, true, false)
+        return
+    }
+    if (t == materialDefinitionType) {
+        v.validateMaterial(path, value, false, true)
+        return
+    }
+
+    switch t.Kind() {
+        case reflect.Ptr:
+            v.validate(path, value, t.Elem())
+
+        case reflect.Struct:
+            object, ok := value.(map[string]interface{})
+            if (false == ok) {
+                v.report(path, "expected an object, got %v", describeJSON(value))
+                return
+            }
+
+            name := schemaName(t)
+            fields, names := jsonFields(t)
+            for _, key := range sortedKeys(object) {
+                field, known := fields[key]
+                if (false == known) {
+                    v.unknownField(childPath(path, key), key, names)
+                    continue
+                }
+
+                // Properties is a whole material unless it overrides the fields of a library material
+                if (field.Type == materialDefinitionType && nil == object["Material"]) {
+                    v.validateMaterial(childPath(path, key), object[key], true, false)
+                } else {
+                    v.validate(childPath(path, key), object[key], field.Type)
+                }
+                v.checkRange(name + "." + key, childPath(path, key), object[key])
+                v.checkOption(name + "." + key, childPath(path, key), object[key])
+            }
+
+            for _, required := range requiredFields[name] {
+                if _, given := object[required]; false == given {
+                    v.report(childPath(path, required), "missing")
+                }
+            }
+
+        case reflect.Map:
+            object, ok := value.(map[string]interface{})
+            if (false == ok) {
+                v.report(path, "expected an object, got %v", describeJSON(value))
+                return
+            }
+            for _, key := range sortedKeys(object) {
+                v.validate(childPath(path, key), object[key], t.Elem())
+            }
+
+        case reflect.Slice, reflect.Array:
+            // Byte slices are base64 strings in json
+            if (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+                text, ok := value.(string)
+                if (false == ok) {
+                    v.report(path, "expected a base64 string, got %v", describeJSON(value))
+                } else if _, err := base64.StdEncoding.DecodeString(text); err != nil {
+                    v.report(path, "not valid base64: %v", err)
+                }
+                return
+            }
+
+            array, ok := value.([]interface{})
+            if (false == ok) {
+                v.report(path, "expected an array, got %v", describeJSON(value))
+                return
+            }
+            if (t.Kind() == reflect.Array && len(array) != t.Len()) {
+                v.report(path, "expected %v values, got %v", t.Len(), len(array))
+            }
+            for i, element := range array {
+                v.validate(fmt.Sprintf("%v[%v]", path, i), element, t.Elem())
+            }
+
+        case reflect.String:
+            if _, ok := value.(string); false == ok {
+                v.report(path, "expected a string, got %v", describeJSON(value))
+            }
+
+        case reflect.Bool:
+            if _, ok := value.(bool); false == ok {
+                v.report(path, "expected true or false, got %v", describeJSON(value))
+            }
+
+        case reflect.Float32, reflect.Float64:
+            if _, ok := jsonNumber(value); false == ok {
+                v.report(path, "expected a number, got %v", describeJSON(value))
+            }
+
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            v.validateInteger(path, value, t)
+
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+            v.validateInteger(path, value, t)
+    }
+}
+
+// validateInteger checks that a value is a whole number that fits in t
+func (v *validator) validateInteger(path string, value interface{}, t reflect.Type) {
+    number, ok := value.(json.Number)
+    if (false == ok) {
+        v.report(path, "expected a whole number, got %v", describeJSON(value))
+        return
+    }
+
+    f, err := number.Float64()
+    if (err != nil || f != math.Trunc(f)) {
+        v.report(path, "expected a whole number, got %v", number)
+        return
+    }
+
+    zero := reflect.Zero(t)
+    switch t.Kind() {
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+            if (f < 0) {
+                v.report(path, "cannot be negative, got %v", number)
+            } else if (zero.OverflowUint(uint64(f))) {
+                v.report(path, "%v is too large, the most is %v", number, uint64(1 << uint(t.Bits())) - 1)
+            }
+        default:
+            if (zero.OverflowInt(int64(f))) {
+                v.report(path, "%v is out of range", number)
+            }
+    }
+}
+
+// validateMaterial checks a material.  A complete material is a whole material on its own, otherwise it is a library definition or overrides which only have some fields.
+// Library definitions may inherit from another definition
+func (v *validator) validateMaterial(path string, value interface{}, complete, inherits bool) {
+    object, ok := value.(map[string]interface{})
+    if (false == ok) {
+        v.report(path, "expected a material object, got %v", describeJSON(value))
+        return
+    }
+
+    names := make([]string, 0, len(materialFields) + 1)
+    for name := range materialFields {
+        names = append(names, name)
+    }
+    if (inherits) {
+        names = append(names, inheritsField)
+    }
+
+    for _, key := range sortedKeys(object) {
+        if (inherits && key == inheritsField) {
+            v.validate(childPath(path, key), object[key], reflect.TypeOf(""))
+            continue
+        }
+
+        fieldType, known := materialFields[key]
+        if (false == known) {
+            v.unknownField(childPath(path, key), key, names)
+            continue
+        }
+
+        v.validate(childPath(path, key), object[key], fieldType)
+        v.checkRange("Material." + key, childPath(path, key), object[key])
+    }
+
+    // The kind of a whole material is picked by its fields the same way deserializeMaterial picks it, so fields of other kinds are silently ignored
+    if (complete) {
+        kind, used := "lambertian", []string{ "Color", "Attenuation" }
+        if (nil != object["Fuzziness"]) {
+            kind, used = "metal", []string{ "Color", "Fuzziness", "Attenuation" }
+        } else if (nil != object["RefractiveIndex"]) {
+            kind, used = "dielectric", []string{ "RefractiveIndex", "Attenuation" }
+        } else if (nil != object["Emission"]) {
+            kind, used = "emissive", []string{ "Emission" }
+        }
+
+        for _, key := range sortedKeys(object) {
+            if _, known := materialFields[key]; known && false == containsString(used, key) {
+                v.report(childPath(path, key), "ignored, this is a %v material which only uses %v", kind, strings.Join(used, ", "))
+            }
+        }
+    }
+}
+
+// unknownField reports a field the type does not have, suggesting the field that was probably meant
+func (v *validator) unknownField(path, key string, names []string) {
+    if suggestion := closestName(key, names); suggestion != "" {
+        v.report(path, "unknown field, did you mean %v?", suggestion)
+        return
+    }
+
+    v.report(path, "unknown field")
+}
+
+// checkRange reports a number, or each component of a vector or list of numbers, outside of the range its rule allows
+func (v *validator) checkRange(rule, path string, value interface{}) {
+    r, ok := valueRules[rule]
+    if (false == ok) {
+        return
+    }
+
+    if number, ok := jsonNumber(value); ok {
+        if problem := r.check(number); problem != "" {
+            v.report(path, "%v", problem)
+        }
+        return
+    }
+
+    if object, ok := value.(map[string]interface{}); ok {
+        for _, axis := range []string{ "X", "Y", "Z" } {
+            if number, ok := jsonNumber(object[axis]); ok {
+                if problem := r.check(number); problem != "" {
+                    v.report(childPath(path, axis), "%v", problem)
+                }
+            }
+        }
+        return
+    }
+
+    if array, ok := value.([]interface{}); ok {
+        for i, element := range array {
+            if number, ok := jsonNumber(element); ok {
+                if problem := r.check(number); problem != "" {
+                    v.report(fmt.Sprintf("%v[%v]", path, i), "%v", problem)
+                }
+            }
+        }
+    }
+}
+
+// checkOption reports a string option that is not one of the values it accepts
+func (v *validator) checkOption(option, path string, value interface{}) {
+    known, ok := knownOptions[option]
+    text, isString := value.(string)
+    if (false == ok || false == isString || text == "" || containsString(known, text)) {
+        return
+    }
+
+    if suggestion := closestName(text, known); suggestion != "" {
+        v.report(path, "unknown value %q, did you mean %v?", text, suggestion)
+        return
+    }
+    v.report(path, "unknown value %q, expected one of %v", text, strings.Join(known, ", "))
+}
+
+// checkCamera reports a field of view the camera's projection cannot use
+func (v *validator) checkCamera(path string, value interface{}) {
+    object, ok := value.(map[string]interface{})
+    if (false == ok) {
+        return
+    }
+
+    fov, ok := jsonNumber(object["Fov"])
+    projection, _ := object["Type"].(string)
+    if (false == ok || (projection != "" && projection != PerspectiveCameraType)) {
+        return
+    }
+    if (fov <= 0 || fov >= 180) {
+        v.report(childPath(path, "Fov"), "a perspective field of view must be between 0 and 180 degrees, got %v", fov)
+    }
+}
+
+// checkMesh reports indices past the mesh's positions and normals or texture coordinates that do not match them
+func (v *validator) checkMesh(path string, object map[string]interface{}) {
+    positions, _ := object["Positions"].([]interface{})
+    indices, _ := object["Indices"].([]interface{})
+
+    if (len(indices) % 3 != 0) {
+        v.report(childPath(path, "Indices"), "has %v indices, a mesh needs three for each triangle", len(indices))
+    }
+    for i, index := range indices {
+        if number, ok := jsonNumber(index); ok && number >= float64(len(positions)) {
+            v.report(fmt.Sprintf("%v[%v]", childPath(path, "Indices"), i), "index %v is past the %v positions", number, len(positions))
+        }
+    }
+
+    for _, field := range []string{ "Normals", "UVs" } {
+        if values, ok := object[field].([]interface{}); ok && len(values) > 0 && len(values) != len(positions) {
+            v.report(childPath(path, field), "has %v entries for %v positions, it needs one per position", len(values), len(positions))
+        }
+    }
+}
+
+// checkPointCloud reports radii and colors that do not match the points and points without any radius
+func (v *validator) checkPointCloud(path string, object map[string]interface{}) {
+    positions, _ := object["Positions"].([]interface{})
+    radii, _ := object["Radii"].([]interface{})
+
+    if (len(radii) > 0 && len(radii) != len(positions)) {
+        v.report(childPath(path, "Radii"), "has %v radii for %v points", len(radii), len(positions))
+    }
+    if radius, _ := jsonNumber(object["Radius"]); len(radii) == 0 && radius <= 0 {
+        v.report(childPath(path, "Radius"), "the points have no Radii and no Radius, give one of them")
+    }
+
+    if text, ok := object["Colors"].(string); ok {
+        if colors, err := base64.StdEncoding.DecodeString(text); err == nil && len(colors) > 0 && len(colors) != len(positions) * 3 {
+            v.report(childPath(path, "Colors"), "has %v bytes for %v points, it needs three per point", len(colors), len(positions))
+        }
+    }
+}
+
+// checkDocument checks the parts of a scene document that refer to each other: its camera, material references, material inheritance and the files it loads
+func (v *validator) checkDocument(object map[string]interface{}, directory string) {
+    // Fields of the wrong type were already reported, the rest is checked as well as it decodes
+    var document sceneDocument
+    b, err := json.Marshal(object)
+    checkError(err)
+    json.Unmarshal(b, &document)
+
+    if (document.Version > SceneDocumentVersion) {
+        v.report("Version", "version %v is newer than this build, which reads version %v", document.Version, SceneDocumentVersion)
+    }
+
+    // Includes and models can add cameras and materials, so references may be to things this file does not define
+    addsMore := len(document.Includes) > 0 || len(document.Models) > 0
+
+    if (document.Camera != "") {
+        if _, ok := document.Cameras[document.Camera]; false == ok && false == addsMore {
+            v.report("Camera", "no camera named %q in Cameras", document.Camera)
+        }
+    } else if (len(document.Cameras) == 0 && false == addsMore) {
+        v.report("Cameras", "the document has no cameras")
+    }
+
+    for name, camera := range mapObjects(object["Cameras"]) {
+        v.checkCamera(childPath("Cameras", name), camera)
+    }
+
+    for _, name := range sortedMaterialNames(document.Materials) {
+        parent, ok := document.Materials[name][inheritsField].(string)
+        if (false == ok) {
+            continue
+        }
+        if _, defined := document.Materials[parent]; false == defined {
+            if (false == addsMore) {
+                v.report(childPath(childPath("Materials", name), inheritsField), "no material named %q in Materials", parent)
+            }
+            continue
+        }
+
+        // Follow the chain of parents, coming back to name is a cycle
+        chain := []string{ name }
+        for current := parent; ; {
+            if (containsString(chain, current)) {
+                if (current == name) {
+                    v.report(childPath(childPath("Materials", name), inheritsField), "materials inherit from each other in a cycle: %v", strings.Join(append(chain, current), " -> "))
+                }
+                break
+            }
+            chain = append(chain, current)
+            next, ok := document.Materials[current][inheritsField].(string)
+            if (false == ok) {
+                break
+            }
+            current = next
+        }
+    }
+
+    references := make(map[string]string)
+    for name, o := range document.Objects {
+        references[childPath("Objects", name)] = o.Material
+    }
+    for name, m := range document.Meshes {
+        references[childPath("Meshes", name)] = m.Material
+    }
+    for name, p := range document.PointClouds {
+        references[childPath("PointClouds", name)] = p.Material
+    }
+    for i, m := range document.Models {
+        references[fmt.Sprintf("Models[%v]", i)] = m.Material
+    }
+    paths := make([]string, 0, len(references))
+    for path := range references {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+    for _, path := range paths {
+        material := references[path]
+        if _, defined := document.Materials[material]; material != "" && false == defined && false == addsMore {
+            v.report(childPath(path, "Material"), "no material named %q in Materials", material)
+        }
+    }
+
+    // Every object, light, mesh and point cloud shares one set of names
+    owners := make(map[string]string)
+    for _, group := range []struct {
+        field string
+        names []string
+    } {
+        { "Objects", mapKeys(object["Objects"]) },
+        { "Lights", mapKeys(object["Lights"]) },
+        { "Meshes", mapKeys(object["Meshes"]) },
+        { "PointClouds", mapKeys(object["PointClouds"]) } } {
+        for _, name := range group.names {
+            if owner, taken := owners[name]; taken {
+                v.report(childPath(group.field, name), "%q is also in %v, names must be unique across objects, lights, meshes and point clouds", name, owner)
+                continue
+            }
+            owners[name] = group.field
+        }
+    }
+
+    for name, mesh := range mapObjects(object["Meshes"]) {
+        v.checkMesh(childPath("Meshes", name), mesh)
+    }
+    for name, points := range mapObjects(object["PointClouds"]) {
+        v.checkPointCloud(childPath("PointClouds", name), points)
+    }
+
+    for i, include := range document.Includes {
+        v.checkFile(fmt.Sprintf("Includes[%v].File", i), include.File, directory)
+    }
+    for i, model := range document.Models {
+        path := fmt.Sprintf("Models[%v].File", i)
+        if (v.checkFile(path, model.File, directory)) {
+            switch strings.ToLower(filepath.Ext(model.File)) {
+                case ".gltf", ".glb", ".ply":
+                    // Models are loaded to find damaged files, the material is left out as it is checked with the document's references
+                    filename := model.File
+                    if (false == filepath.IsAbs(filename)) {
+                        filename = filepath.Join(directory, filename)
+                    }
+                    if _, err := loadModel(documentModel{ PointRadius: model.PointRadius }, filename); err != nil {
+                        v.report(path, "cannot be loaded: %v", err)
+                    }
+                default:
+                    v.report(path, "%v is not a .gltf, .glb or .ply file", model.File)
+            }
+        }
+    }
+}
+
+// checkFile reports a file a document refers to that cannot be read, relative files are found in directory
+func (v *validator) checkFile(path, filename, directory string) bool {
+    if (filename == "") {
+        return false
+    }
+    if (false == filepath.IsAbs(filename)) {
+        filename = filepath.Join(directory, filename)
+    }
+    if _, err := os.Stat(filename); err != nil {
+        v.report(path, "cannot be read: %v", err)
+        return false
+    }
+
+    return true
+}
+
+// mapObjects returns the entries of a json object that are objects themselves
+func mapObjects(value interface{}) map[string]map[string]interface{} {
+    objects := make(map[string]map[string]interface{})
+    if object, ok := value.(map[string]interface{}); ok {
+        for name, entry := range object {
+            if entryObject, ok := entry.(map[string]interface{}); ok {
+                objects[name] = entryObject
+            }
+        }
+    }
+    return objects
+}
+
+// mapKeys returns the keys of a json object in order, nothing for anything else
+func mapKeys(value interface{}) []string {
+    if object, ok := value.(map[string]interface{}); ok {
+        return sortedKeys(object)
+    }
+    return nil
+}
+
+// sortedMaterialNames returns the names in a material library in order
+func sortedMaterialNames(materials map[string]MaterialDefinition) []string {
+    names := make([]string, 0, len(materials))
+    for name := range materials {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// jsonNumber returns the value of a json number
+func jsonNumber(value interface{}) (float64, bool) {
+    switch number := value.(type) {
+        case json.Number:
+            f, err := number.Float64()
+            return f, err == nil
+        case float64:
+            return number, true
+    }
+
+    return 0, false
+}
+
+// describeJSON names the kind of a json value for problems
+func describeJSON(value interface{}) string {
+    switch value := value.(type) {
+        case map[string]interface{}:
+            return "an object"
+        case []interface{}:
+            return "an array"
+        case string:
+            return fmt.Sprintf("the string %q", value)
+        case bool:
+            return fmt.Sprintf("%v", value)
+        case json.Number, float64:
+            return fmt.Sprintf("the number %v", value)
+    }
+
+    return "null"
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+    for _, item := range list {
+        if (item == s) {
+            return true
+        }
+    }
+    return false
+}
+
+// closestName returns the name a misspelt name was probably meant to be, or an empty string when none of names is close.
+// A name that only differs in case or is the start of a name is always a match, otherwise it may be a couple of letters off
+func closestName(name string, names []string) string {
+    best, bestDistance := "", 3
+    for _, candidate := range names {
+        if (strings.EqualFold(name, candidate)) {
+            return candidate
+        }
+        if (len(name) >= 4 && strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(name))) {
+            return candidate
+        }
+
+        distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+        if (distance < bestDistance && distance < len(candidate) / 2 + 1) {
+            best, bestDistance = candidate, distance
+        }
+    }
+
+    return best
+}
+
+// editDistance returns the number of letters that have to be added, removed or changed to turn a into b
+func editDistance(a, b string) int {
+    previous := make([]int, len(b) + 1)
+    current := make([]int, len(b) + 1)
+    for j := range previous {
+        previous[j] = j
+    }
+
+    for i := 1; i <= len(a); i++ {
+        current[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if (a[i - 1] == b[j - 1]) {
+                cost = 0
+            }
+            current[j] = minInt(minInt(previous[j] + 1, current[j - 1] + 1), previous[j - 1] + cost)
+        }
+        previous, current = current, previous
+    }
+
+    return previous[len(b)]
+}
+
+// minInt returns the smaller of a and b
+func minInt(a, b int) int {
+    if (a < b) {
+        return a
+    }
+    return b
+}
@@ -84,9 +84,13 @@ func (v Vector3) UnitVector() Vector3 {
         Z: v.Z / len }
 }
 
-// AsColor converts a vector to RGBA color values
+// AsColor converts a vector to RGBA color values, components outside of [0, 1] are clamped
 func (v Vector3) AsColor() color.RGBA {
-    return color.RGBA{uint8(v.X * math.MaxUint8), uint8(v.Y * math.MaxUint8), uint8(v.Z * math.MaxUint8), 255}
+    return color.RGBA{
+        uint8(restrictValues(v.X, 0.0, 1.0) * math.MaxUint8),
+        uint8(restrictValues(v.Y, 0.0, 1.0) * math.MaxUint8),
+        uint8(restrictValues(v.Z, 0.0, 1.0) * math.MaxUint8),
+        255}
 }
 
 // AsVector3 converts a color to a Vector3
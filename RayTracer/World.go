@@ -3,16 +3,29 @@ package raytracer
 import
 (
     "encoding/json"
-    "image/color"
     "io"
     "log"
-    "math"
     "os"
+    "path/filepath"
+    "sort"
 )
 
 // World contains information about the world
 type World struct {
     Scene CollisionList
+
+    // Materials is the material library, objects added with AddObjectWithMaterial refer to its materials by name
+    Materials map[string]MaterialDefinition
+
+    // materialReferences holds the library material of each object that was given one, by object name
+    materialReferences map[string]materialReference
+
+    // models are the model files imported into the scene with absolute paths, modelObjects names the objects that came from them
+    models []documentModel
+    modelObjects map[string]bool
+
+    // counters count the rays traced through this copy of the world, nil when nothing is counted
+    counters *renderCounters
 }
 
 // Config contains data on how the raytracer will behave
@@ -37,6 +50,39 @@ type Config struct {
     
     // HeightInPixels is the vertical resolution of the resulting image    
     HeightInPixels int
+
+    // Sampler selects how sample values are generated, one of independent, stratified, halton, sobol or bluenoise.  Defaults to independent
+    Sampler string
+
+    // Seed selects the sample values used for the render, the same seed and inputs always produce the same image
+    Seed uint64
+
+    // Integrator selects the lighting algorithm, one of path, whitted, ao, normals, uv, depth or cost.  Defaults to path
+    Integrator string
+
+    // AmbientOcclusionDistance is the distance within which geometry occludes a point for the ao integrator.  Defaults to 1
+    AmbientOcclusionDistance float32
+
+    // DebugDepthRange is the distance that maps to black for the depth integrator.  Defaults to 100
+    DebugDepthRange float32
+
+    // Filter is the pixel reconstruction filter, one of box, tent, gaussian, mitchell or lanczos.  Defaults to box
+    Filter string
+
+    // FilterRadius is how far in pixels the filter reaches.  Defaults to 0.5 for box, 1 for tent, 1.5 for gaussian, 2 for mitchell and 3 for lanczos
+    FilterRadius float32
+
+    // SkyLuminance scales the sky colors, with a physical camera exposure it is the sky brightness in cd/m^2.  Defaults to 1
+    SkyLuminance float32
+
+    // DebugCostRange is the number of bounding box and object tests that maps to the hottest color for the cost integrator.  Defaults to 64
+    DebugCostRange float32
+
+    // CropWindow limits rendering to part of the image, in pixels or as fractions of the image size.  Defaults to the whole image
+    CropWindow CropWindow
+
+    // CropOutput is cropped to write only the crop window or full to write the whole frame with black outside of it.  Defaults to cropped
+    CropOutput string
 }
 
 // Settings contains the current config the ray tracer will use
@@ -52,20 +98,51 @@ func (w *World) AddObject(name string, obj CollidableObject) {
 
 // TestCollision tests all the objects in the scene for collisions
 func (w World) TestCollision(r Ray, tMin, tMax float32) (bool, IntersectionRecord) {
+    w.countRay()
+    if statistics := w.statistics(); statistics != nil {
+        collisions := collisionCounters {
+            objectTests: statistics.ObjectTests,
+            objectHits: statistics.ObjectHits }
+        return w.Scene.testCollisionWithCounters(r, tMin, tMax, &collisions)
+    }
+
     return w.Scene.testCollision(r, tMin, tMax)
 }
 
-// ShootRay shoots a ray and tests for intersection
-func ShootRay(r Ray, w World, bounceDepth uint32) color.RGBA {
-    collided, record := w.TestCollision(r, 0.0001, math.MaxFloat32)
-    if (collided) {
-        return record.Object.GetColor(r, record, bounceDepth)
+// countRay counts a ray traced through the world
+func (w World) countRay() {
+    if (w.counters != nil) {
+        w.counters.Rays++
     }
-    
-    t := 0.5 * (r.Direction.Y + 1.0)
+}
+
+// countPathVertex counts a surface hit by a camera or bounce ray when statistics are collected
+func (w World) countPathVertex() {
+    if statistics := w.statistics(); statistics != nil {
+        statistics.PathVertices++
+    }
+}
+
+// statistics returns the detailed counters of this copy of the world, nil when statistics are not collected
+func (w World) statistics() *renderStatistics {
+    if (w.counters == nil) {
+        return nil
+    }
+
+    return w.counters.Statistics
+}
+
+// BuildHierarchy builds the bounding volume hierarchy used to speed up collision tests, call it after the last object is added
+func (w *World) BuildHierarchy() {
+    w.Scene.buildHierarchy()
+}
+
+// skyColor returns the color of the sky seen in direction d
+func skyColor(d Vector3) Vector3 {
+    t := 0.5 * (d.Y + 1.0)
     // Lerp from blue to white
-    c := Settings.SkyColorBottom.Scale(1.0 - t).Add(Settings.SkyColorTop.Scale(t)); 
-    return c.AsColor()
+    c := Settings.SkyColorBottom.Scale(1.0 - t).Add(Settings.SkyColorTop.Scale(t))
+    return c.Scale(defaultFloat(Settings.SkyLuminance, 1.0))
 }
 
 func checkError(err error) {
@@ -74,22 +151,20 @@ func checkError(err error) {
     }
 }
 
-// ExportScene will export the current global scene
+// ExportScene will export the current global config, camera and scene as a scene document.
+// Objects that use a library material keep the reference to it rather than a copy of its fields and objects imported from model files are referred to by the file
 func ExportScene(filename string) {
-    sceneString, err := json.Marshal(Scene.Scene.collisionList)
+    sceneString, err := json.MarshalIndent(currentSceneDocument(filepath.Dir(filename)), "", "    ")
     checkError(err)
     
-    jsonString := []byte{}
-    jsonString = append(jsonString, sceneString...)
-    
     sceneFile, err := os.Create(filename)
     checkError(err)
     defer sceneFile.Close()
     
-    sceneFile.Write(jsonString)
+    sceneFile.Write(sceneString)
 }
 
-// ImportScene will import the given scene file
+// ImportScene will import the given scene file, a scene document such as ExportScene writes is imported with its config and camera as well
 func ImportScene(filename string) {
     sceneFile, err := os.Open(filename)
     checkError(err)
@@ -105,24 +180,71 @@ func ImportScene(filename string) {
         checkError(err)   
     }
     
+    if (isSceneDocument(contents)) {
+        importSceneDocumentJSON(contents, filename)
+        return
+    }
+    
+    ImportSceneJSON(contents)
+}
+
+// ImportSceneJSON will import the contents of a scene file
+func ImportSceneJSON(contents []byte) {
     var sceneObjects map[string]interface{}
-    err = json.Unmarshal(contents, &sceneObjects)
+    err := json.Unmarshal(contents, &sceneObjects)
     checkError(err)
     
-    for name, object := range sceneObjects {
+    // Add objects in name order so the scene is identical on every import
+    names := make([]string, 0, len(sceneObjects))
+    for name := range sceneObjects {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    
+    for _, name := range names {
+        object := sceneObjects[name]
         switch object.(type) {
             case map[string]interface{}:
                 obj, ok := object.(map[string]interface{})
-                if (true == ok) {
+                if (true == ok && nil != obj["Indices"]) {
+                    m, isMesh := deserializeMesh(obj)
+                    if (true == isMesh) {
+                        Scene.AddObject(name, m)
+                    } else {
+                        log.Printf("Skipping %v, it is not a valid mesh, the validate command shows why", name)
+                    }
+                } else if (true == ok && nil != obj["Positions"]) {
+                    p, isPointCloud := deserializePointCloud(obj)
+                    if (true == isPointCloud) {
+                        Scene.AddObject(name, p)
+                    } else {
+                        log.Printf("Skipping %v, it is not a valid point cloud, the validate command shows why", name)
+                    }
+                } else if (true == ok) {
                     s, isSphere := deserializeSphere(obj)
                     if (true == isSphere) {
                         Scene.AddObject(name, s)
-                    }    
+                    } else {
+                        log.Printf("Skipping %v, it is not a valid sphere, the validate command shows why", name)
+                    }
                 }
             default:
                 continue
         }
-    }    
+    }
+    
+    Scene.BuildHierarchy()
+}
+
+// ImportRenderInputs replaces the global config, scene and camera with the contents of a config, scene and camera file
+func ImportRenderInputs(config, scene, camera []byte) {
+    Settings = Config{}
+    Scene = World{}
+    cameraSettings = cameraConfig{}
+
+    ImportConfigJSON(config)
+    ImportSceneJSON(scene)
+    ImportCameraJSON(camera)
 }
 
 // ExportConfig will export the current global config
@@ -153,6 +275,11 @@ func ImportConfig(filename string) {
         checkError(err)   
     }
     
-    err = json.Unmarshal(contents, &Settings)
+    ImportConfigJSON(contents)
+}
+
+// ImportConfigJSON will import the contents of a config file to the global config
+func ImportConfigJSON(contents []byte) {
+    err := json.Unmarshal(contents, &Settings)
     checkError(err)
 }
\ No newline at end of file
@@ -0,0 +1,259 @@
+package main
+
+import
+(
+    "log"
+    "math"
+    "math/rand"
+    "github.com/vohumana/vohumana-gotracer/raytracer"
+)
+
+// placement is where a generated sphere goes and how big it is
+type placement struct {
+    Origin raytracer.Vector3
+    Radius float32
+}
+
+// layoutOptions describe the spheres a layout places
+type layoutOptions struct {
+    // Count is the number of spheres to place
+    Count int
+
+    // Min and Max bound the region the centers of the spheres are placed in
+    Min, Max raytracer.Vector3
+
+    // MinRadius and MaxRadius bound the radius of each sphere
+    MinRadius, MaxRadius float32
+
+    // Shells is the number of shells for the shells layout
+    Shells int
+
+    // Turns is the number of times the spiral layout goes around
+    Turns float32
+}
+
+// layout places spheres using the random source r
+type layout func(r *rand.Rand, options layoutOptions) []placement
+
+// createLayout returns the layout with the given name, one of random, poisson, grid, spiral or shells
+func createLayout(name string) layout {
+    switch name {
+        case "random":
+            return randomLayout
+        case "poisson":
+            return poissonLayout
+        case "grid":
+            return gridLayout
+        case "spiral":
+            return spiralLayout
+        case "shells":
+            return shellsLayout
+    }
+
+    log.Fatalf("Unknown layout %v, expected random, poisson, grid, spiral or shells", name)
+    return nil
+}
+
+// randomRadius picks a radius between the options' smallest and largest
+func randomRadius(r *rand.Rand, options layoutOptions) float32 {
+    return options.MinRadius + (r.Float32() * (options.MaxRadius - options.MinRadius))
+}
+
+// randomPoint picks a point anywhere in the options' region
+func randomPoint(r *rand.Rand, options layoutOptions) raytracer.Vector3 {
+    size := options.Max.Subtract(options.Min)
+    return raytracer.NewVector3(
+        options.Min.X + (r.Float32() * size.X),
+        options.Min.Y + (r.Float32() * size.Y),
+        options.Min.Z + (r.Float32() * size.Z))
+}
+
+// center returns the middle of the options' region
+func center(options layoutOptions) raytracer.Vector3 {
+    return options.Min.Add(options.Max).Scale(0.5)
+}
+
+// randomLayout scatters spheres anywhere in the region, they may overlap
+func randomLayout(r *rand.Rand, options layoutOptions) []placement {
+    spheres := make([]placement, options.Count)
+    for i := range spheres {
+        spheres[i].Origin = randomPoint(r, options)
+        spheres[i].Radius = randomRadius(r, options)
+    }
+
+    return spheres
+}
+
+// poissonLayout scatters spheres in the region without any two overlapping by throwing darts, each new sphere is tried at random points until it fits.
+// When the region is too full for every sphere fewer are placed
+func poissonLayout(r *rand.Rand, options layoutOptions) []placement {
+    // Spheres are kept in a grid of cells as wide as the largest sphere so only the neighbouring cells have to be checked for overlaps
+    cellSize := 2.0 * options.MaxRadius
+    if (cellSize <= 0.0) {
+        log.Fatalf("The poisson layout needs a radius above 0")
+    }
+    type cell struct {
+        X, Y, Z int
+    }
+    cellOf := func(p raytracer.Vector3) cell {
+        return cell {
+            X: int(math.Floor(float64(p.X / cellSize))),
+            Y: int(math.Floor(float64(p.Y / cellSize))),
+            Z: int(math.Floor(float64(p.Z / cellSize))) }
+    }
+    cells := make(map[cell][]int)
+
+    var spheres []placement
+    maxAttempts := options.Count * 100
+    for attempt := 0; attempt < maxAttempts && len(spheres) < options.Count; attempt++ {
+        candidate := placement {
+            Origin: randomPoint(r, options),
+            Radius: randomRadius(r, options) }
+
+        home := cellOf(candidate.Origin)
+        fits := true
+        for x := home.X - 1; x <= home.X + 1 && fits; x++ {
+            for y := home.Y - 1; y <= home.Y + 1 && fits; y++ {
+                for z := home.Z - 1; z <= home.Z + 1 && fits; z++ {
+                    for _, i := range cells[cell{ X: x, Y: y, Z: z }] {
+                        if (spheres[i].Origin.Subtract(candidate.Origin).Length() < float64(spheres[i].Radius + candidate.Radius)) {
+                            fits = false
+                            break
+                        }
+                    }
+                }
+            }
+        }
+
+        if (fits) {
+            cells[home] = append(cells[home], len(spheres))
+            spheres = append(spheres, candidate)
+        }
+    }
+
+    if (len(spheres) < options.Count) {
+        log.Printf("Only %v of %v spheres fit without overlapping, make the bounds larger or the radii smaller for more", len(spheres), options.Count)
+    }
+
+    return spheres
+}
+
+// gridLayout places spheres at the centers of the cells of an even grid filling the region, axes the region is flat on get a single cell so flat bounds make a flat grid.
+// Radii are limited to half the distance between cells so neighbours never overlap
+func gridLayout(r *rand.Rand, options layoutOptions) []placement {
+    size := options.Max.Subtract(options.Min)
+    extents := []float32{ size.X, size.Y, size.Z }
+
+    axes := 0
+    for _, extent := range extents {
+        if (extent > 0.0) {
+            axes++
+        }
+    }
+
+    // Every axis with room gets the same number of cells, enough for all the spheres
+    perAxis := 1
+    if (axes > 0) {
+        perAxis = int(math.Ceil(math.Pow(float64(options.Count), 1.0 / float64(axes)) - 1e-9))
+    }
+
+    var cellCounts [3]int
+    var spacing [3]float32
+    largestRadius := options.MaxRadius
+    for axis, extent := range extents {
+        cellCounts[axis] = 1
+        if (extent > 0.0) {
+            cellCounts[axis] = perAxis
+        }
+        spacing[axis] = extent / float32(cellCounts[axis])
+        if (extent > 0.0 && spacing[axis] / 2.0 < largestRadius) {
+            largestRadius = spacing[axis] / 2.0
+        }
+    }
+
+    spheres := make([]placement, 0, options.Count)
+    for i := 0; i < options.Count; i++ {
+        x := i % cellCounts[0]
+        y := (i / cellCounts[0]) % cellCounts[1]
+        z := i / (cellCounts[0] * cellCounts[1])
+
+        radius := randomRadius(r, options)
+        if (radius > largestRadius) {
+            radius = largestRadius
+        }
+
+        spheres = append(spheres, placement {
+            Origin: raytracer.NewVector3(
+                options.Min.X + ((float32(x) + 0.5) * spacing[0]),
+                options.Min.Y + ((float32(y) + 0.5) * spacing[1]),
+                options.Min.Z + ((float32(z) + 0.5) * spacing[2])),
+            Radius: radius })
+    }
+
+    return spheres
+}
+
+// spiralLayout places spheres along a spiral that winds outwards from the middle of the region while rising from its bottom to its top
+func spiralLayout(r *rand.Rand, options layoutOptions) []placement {
+    middle := center(options)
+    size := options.Max.Subtract(options.Min)
+    reach := float64(math.Min(float64(size.X), float64(size.Z)) / 2.0)
+
+    spheres := make([]placement, options.Count)
+    for i := range spheres {
+        t := (float64(i) + 0.5) / float64(options.Count)
+        angle := 2.0 * math.Pi * float64(options.Turns) * t
+
+        spheres[i].Origin = raytracer.NewVector3(
+            middle.X + float32(t * reach * math.Cos(angle)),
+            options.Min.Y + float32(t * float64(size.Y)),
+            middle.Z + float32(t * reach * math.Sin(angle)))
+        spheres[i].Radius = randomRadius(r, options)
+    }
+
+    return spheres
+}
+
+// shellsLayout spreads spheres evenly over the surfaces of nested spherical shells around the middle of the region, the outer shell touching its nearest sides.
+// Larger shells get more spheres so they are as densely covered as the smaller ones
+func shellsLayout(r *rand.Rand, options layoutOptions) []placement {
+    if (options.Shells < 1) {
+        log.Fatalf("The shells layout needs at least 1 shell")
+    }
+
+    middle := center(options)
+    size := options.Max.Subtract(options.Min)
+    outer := math.Min(float64(size.X), math.Min(float64(size.Y), float64(size.Z))) / 2.0
+
+    // A shell's share of the spheres grows with its area
+    total := 0
+    for shell := 1; shell <= options.Shells; shell++ {
+        total += shell * shell
+    }
+
+    goldenAngle := math.Pi * (3.0 - math.Sqrt(5.0))
+    spheres := make([]placement, 0, options.Count)
+    for shell := 1; shell <= options.Shells; shell++ {
+        count := options.Count * shell * shell / total
+        if (shell == options.Shells) {
+            count = options.Count - len(spheres)
+        }
+        radius := outer * float64(shell) / float64(options.Shells)
+
+        // Points on a Fibonacci spiral from pole to pole cover a sphere evenly
+        for i := 0; i < count; i++ {
+            y := 1.0 - (2.0 * (float64(i) + 0.5) / float64(count))
+            ring := math.Sqrt(1.0 - (y * y))
+            angle := goldenAngle * float64(i)
+
+            spheres = append(spheres, placement {
+                Origin: middle.Add(raytracer.NewVector3(
+                    float32(radius * ring * math.Cos(angle)),
+                    float32(radius * y),
+                    float32(radius * ring * math.Sin(angle)))),
+                Radius: randomRadius(r, options) })
+        }
+    }
+
+    return spheres
+}
@@ -2,17 +2,20 @@ package main
 
 import
 (
+    "flag"
     "fmt"
     "image/color"
 	"log"
-    // "math"
+    "math"
     "math/rand"
     "github.com/vohumana/vohumana-gotracer/raytracer"
     "strconv"
+    "strings"
     "time"
 )
 
-var communicationChannel chan bool
+// materialKinds are the kinds of material generated spheres can have, in the order they are picked from
+var materialKinds = []string{ "lambertian", "metal", "dielectric", "emissive" }
 
 func checkError(err error) {
 	if (err != nil) {
@@ -21,130 +24,306 @@ func checkError(err error) {
 }
 
 func main() {
-    seedVal := time.Now().UTC().UnixNano()
-    rand.Seed(seedVal)
-    fmt.Printf("Generating scene with seed val: %v", seedVal)
-    
-    // shellIncrement := 10.0
-    // sphereRadius := 20.0
-    // numSpheresPerRow := 35.0
-    // numShells := 3
-    // incrementValue := 360.0 / numSpheresPerRow
-    // incrementValue = float64(raytracer.ConvertDegreesToRadians(float32(incrementValue)))
-    
-    // for shell := 0; shell < numShells; shell++ {
-        
-    //     theta := float64(0.0)
-    //     phi := float64(0.0)
-        
-    //     for i := 0; i < int(numSpheresPerRow); i++ {
-    //         var pos raytracer.Vector3
-            
-    //         switch shell % 3 {
-    //             case 0:
-    //                 pos.X = float32(sphereRadius * math.Cos(theta))
-    //                 pos.Y = float32(sphereRadius * math.Sin(phi))
-    //             case 1:
-    //                 pos.X = float32(sphereRadius * math.Cos(theta))
-    //                 pos.Z = float32(sphereRadius * math.Sin(phi)) 
-    //             case 2:
-    //                 pos.Y = float32(sphereRadius * math.Cos(theta))
-    //                 pos.Z = float32(sphereRadius * math.Sin(phi))
-    //         }
-    //         sphere := raytracer.Sphere {
-    //             Origin: pos,
-    //             Radius: 2.0 }
-    //         color := color.RGBA {
-    //             R: uint8(rand.Intn(255)), 
-    //             G: uint8(rand.Intn(255)), 
-    //             B: uint8(rand.Intn(255)), 
-    //             A: 255}
-            
-    //         switch rand.Int() % 3 {
-    //             case 0:
-    //                 sphere.Properties = raytracer.Lambertian {
-    //                     Color: color,
-    //                     Attenuation: raytracer.AsVector3(color) }
-    //             case 1:
-    //                 sphere.Properties = raytracer.Metal {
-    //                     Color: color,
-    //                     Attenuation: raytracer.AsVector3(color),
-    //                     Fuzziness: rand.Float32() * 0.5 }
-    //             case 2:
-    //                 sphere.Properties = raytracer.Dielectric {
-    //                     Attenuation: raytracer.Vector3 {
-    //                         X: 1.0,
-    //                         Y: 1.0,
-    //                         Z: 1.0 },
-    //                     RefractiveIndex: (rand.Float32() * 1.3) + 1.1 }
-    //         }
-            
-    //         theta += incrementValue
-    //         phi += incrementValue
-            
-    //         raytracer.Scene.AddObject(strconv.Itoa((i + 1) * (shell + 1)), sphere)
-    //     }   
-        
-    //     sphereRadius += shellIncrement
-    // }
-    
-    numSpheres := 150
-    for i := 0; i < numSpheres; i++ {
-        var pos raytracer.Vector3
-        pos.X = rand.Float32() * 100.0
-        pos.Y = rand.Float32() * 100.0
-        pos.Z = rand.Float32() * 100.0
-        
-        radius := 1.0 + (rand.Float32() * 4.0)
-        
+    var seed int64
+    var layoutName string
+    var templateName string
+    var bounds string
+    var mix string
+    var sceneFilename string
+    var cameraFilename string
+    var configFilename string
+    var fov float64
+    var width int
+    var height int
+    var samples uint
+    var minRadius, maxRadius float64
+    var turns float64
+    var options layoutOptions
+
+    flag.Int64Var(&seed, "seed", 0, "Seed for the random numbers, the same seed and flags always generate the same scene.  Defaults to one based on the time")
+    flag.IntVar(&options.Count, "count", 150, "Number of spheres to generate")
+    flag.StringVar(&templateName, "template", "", "Write a reference scene instead of random spheres: cornell, rtiow for the Ray Tracing in One Weekend cover, materials or furnace")
+    flag.StringVar(&layoutName, "layout", "random", "How spheres are placed: random, poisson for random without overlaps, grid, spiral or shells")
+    flag.StringVar(&bounds, "bounds", "0,0,0,100,100,100", "Region sphere centers are placed in as minX,minY,minZ,maxX,maxY,maxZ")
+    flag.Float64Var(&minRadius, "minradius", 1.0, "Smallest sphere radius")
+    flag.Float64Var(&maxRadius, "maxradius", 5.0, "Largest sphere radius")
+    flag.IntVar(&options.Shells, "shells", 3, "Number of shells for the shells layout")
+    flag.Float64Var(&turns, "turns", 3.0, "Number of times the spiral layout goes around")
+    flag.StringVar(&mix, "mix", "lambertian=1,metal=1,dielectric=1,emissive=0", "Relative amounts of each kind of material as kind=weight pairs")
+    flag.StringVar(&sceneFilename, "scene", "GeneratedScene.json", "Scene document to write")
+    flag.StringVar(&cameraFilename, "camera", "camera.json", "Camera file to write, empty to skip it")
+    flag.StringVar(&configFilename, "config", "", "Config file to write, empty to skip it")
+    flag.Float64Var(&fov, "fov", 50.0, "Vertical field of view of the camera in degrees")
+    flag.IntVar(&width, "width", 800, "Horizontal resolution of the image")
+    flag.IntVar(&height, "height", 600, "Vertical resolution of the image")
+    flag.UintVar(&samples, "samples", 16, "Samples per pixel")
+    flag.Parse()
+
+    // Flags given on the command line override a template's own settings
+    given := make(map[string]bool)
+    flag.Visit(func(f *flag.Flag) {
+        given[f.Name] = true
+    })
+
+    if (templateName != "") {
+        generateTemplate(templateName, seed, given, sceneFilename, cameraFilename, configFilename, width, height, samples)
+        return
+    }
+
+    if (minRadius <= 0.0 || maxRadius < minRadius) {
+        log.Fatalf("Radii from %v to %v are not a range of positive radii", minRadius, maxRadius)
+    }
+    if (options.Count < 0) {
+        log.Fatalf("Cannot generate %v spheres", options.Count)
+    }
+    if (width <= 0 || height <= 0) {
+        log.Fatalf("Resolution %v x %v has no pixels", width, height)
+    }
+    options.MinRadius, options.MaxRadius = float32(minRadius), float32(maxRadius)
+    options.Turns = float32(turns)
+    options.Min, options.Max = parseBounds(bounds)
+    weights := parseMix(mix)
+    placeSpheres := createLayout(layoutName)
+
+    if (seed == 0) {
+        seed = time.Now().UTC().UnixNano()
+    }
+    fmt.Printf("Generating scene with seed %v\n", seed)
+    r := rand.New(rand.NewSource(seed))
+
+    spheres := placeSpheres(r, options)
+    for i, p := range spheres {
         sphere := raytracer.Sphere {
-            Origin: pos,
-            Radius: radius}
-        color := color.RGBA {
-            R: uint8(rand.Intn(255)), 
-            G: uint8(rand.Intn(255)), 
-            B: uint8(rand.Intn(255)), 
-            A: 255}
-        
-        switch rand.Int() % 3 {
-            case 0:
-                sphere.Properties = raytracer.Lambertian {
-                    Color: color,
-                    Attenuation: raytracer.AsVector3(color) }
-            case 1:
-                sphere.Properties = raytracer.Metal {
-                    Color: color,
-                    Attenuation: raytracer.AsVector3(color),
-                    Fuzziness: rand.Float32() * 0.5 }
-            case 2:
-                sphere.Properties = raytracer.Dielectric {
-                    Attenuation: raytracer.Vector3 {
-                        X: 1.0,
-                        Y: 1.0,
-                        Z: 1.0 },
-                    RefractiveIndex: (rand.Float32() * 1.3) + 1.1 }
-        }
-        
+            Origin: p.Origin,
+            Radius: p.Radius,
+            Properties: randomMaterial(r, weights) }
+
         raytracer.Scene.AddObject(strconv.Itoa(i + 1), sphere)
     }
-    
-    raytracer.ExportScene("GeneratedScene.json")
-    
+
+    raytracer.Settings = defaultSettings(width, height, samples)
+    frameCamera(spheres, float32(fov), float32(width) / float32(height))
+
+    fmt.Printf("Generated %v spheres\n", len(spheres))
+    writeFiles(sceneFilename, cameraFilename, configFilename)
+}
+
+// generateTemplate writes a reference scene with its camera and config.  The files are named after the template, such as cornell.json, cornell.camera.json and cornell.config.json, unless their flags are given.
+// A template's random parts are the same every time unless a seed is given, and its resolution and samples are its own unless given
+func generateTemplate(name string, seed int64, given map[string]bool, sceneFilename, cameraFilename, configFilename string, width, height int, samples uint) {
+    buildTemplate := createTemplate(name)
+
+    if (false == given["scene"]) {
+        sceneFilename = name + ".json"
+    }
+    if (false == given["camera"]) {
+        cameraFilename = name + ".camera.json"
+    }
+    if (false == given["config"]) {
+        configFilename = name + ".config.json"
+    }
+    if (seed == 0) {
+        seed = 1
+    }
+
+    fmt.Printf("Generating the %v template with seed %v\n", name, seed)
+    buildTemplate(rand.New(rand.NewSource(seed)))
+
+    if (given["width"]) {
+        raytracer.Settings.WidthInPixels = width
+    }
+    if (given["height"]) {
+        raytracer.Settings.HeightInPixels = height
+    }
+    if (given["samples"]) {
+        raytracer.Settings.MaxAntialiasRays = uint32(samples)
+    }
+    if (raytracer.Settings.WidthInPixels <= 0 || raytracer.Settings.HeightInPixels <= 0) {
+        log.Fatalf("Resolution %v x %v has no pixels", raytracer.Settings.WidthInPixels, raytracer.Settings.HeightInPixels)
+    }
+
+    writeFiles(sceneFilename, cameraFilename, configFilename)
+}
+
+// writeFiles writes the global scene as a scene document, and the camera and config on their own when they have a filename
+func writeFiles(sceneFilename, cameraFilename, configFilename string) {
+    raytracer.ExportScene(sceneFilename)
+    fmt.Printf("Wrote %v\n", sceneFilename)
+    if (cameraFilename != "") {
+        raytracer.ExportCamera(cameraFilename)
+        fmt.Printf("Wrote %v\n", cameraFilename)
+    }
+    if (configFilename != "") {
+        raytracer.ExportConfig(configFilename)
+        fmt.Printf("Wrote %v\n", configFilename)
+    }
+}
+
+// parseBounds parses a region given as minX,minY,minZ,maxX,maxY,maxZ
+func parseBounds(bounds string) (raytracer.Vector3, raytracer.Vector3) {
+    parts := strings.Split(bounds, ",")
+    if (len(parts) != 6) {
+        log.Fatalf("Bounds %v should be minX,minY,minZ,maxX,maxY,maxZ", bounds)
+    }
+
+    var values [6]float32
+    for i, part := range parts {
+        value, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+        checkError(err)
+        values[i] = float32(value)
+    }
+
+    min := raytracer.NewVector3(values[0], values[1], values[2])
+    max := raytracer.NewVector3(values[3], values[4], values[5])
+    if (max.X < min.X || max.Y < min.Y || max.Z < min.Z) {
+        log.Fatalf("Bounds %v end before they start", bounds)
+    }
+
+    return min, max
+}
+
+// parseMix parses material weights given as kind=weight pairs such as lambertian=2,metal=1, kinds that are left out get no spheres
+func parseMix(mix string) map[string]float64 {
+    weights := make(map[string]float64)
+    total := 0.0
+    for _, pair := range strings.Split(mix, ",") {
+        parts := strings.SplitN(pair, "=", 2)
+        if (len(parts) != 2) {
+            log.Fatalf("Material mix %v should be kind=weight pairs", mix)
+        }
+
+        kind := strings.TrimSpace(parts[0])
+        known := false
+        for _, k := range materialKinds {
+            known = known || k == kind
+        }
+        if (false == known) {
+            log.Fatalf("Unknown material kind %v, expected one of %v", kind, strings.Join(materialKinds, ", "))
+        }
+
+        weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+        checkError(err)
+        if (weight < 0.0) {
+            log.Fatalf("Material weight %v for %v is negative", weight, kind)
+        }
+        weights[kind] = weight
+        total += weight
+    }
+
+    if (total <= 0.0) {
+        log.Fatalf("Material mix %v gives every kind a weight of 0", mix)
+    }
+
+    return weights
+}
+
+// randomMaterial picks a kind of material with a chance in proportion to its weight and gives it a random color
+func randomMaterial(r *rand.Rand, weights map[string]float64) raytracer.Material {
+    total := 0.0
+    for _, weight := range weights {
+        total += weight
+    }
+
+    kind := ""
+    pick := r.Float64() * total
+    for _, k := range materialKinds {
+        if (weights[k] <= 0.0) {
+            continue
+        }
+        kind = k
+        if (pick < weights[k]) {
+            break
+        }
+        pick -= weights[k]
+    }
+
+    color := color.RGBA {
+        R: uint8(r.Intn(255)),
+        G: uint8(r.Intn(255)),
+        B: uint8(r.Intn(255)),
+        A: 255}
+
+    switch kind {
+        case "metal":
+            return raytracer.Metal {
+                Color: color,
+                Attenuation: raytracer.AsVector3(color),
+                Fuzziness: r.Float32() * 0.5 }
+        case "dielectric":
+            return raytracer.Dielectric {
+                Attenuation: raytracer.Vector3 {
+                    X: 1.0,
+                    Y: 1.0,
+                    Z: 1.0 },
+                RefractiveIndex: (r.Float32() * 1.3) + 1.1 }
+        case "emissive":
+            return raytracer.Emissive {
+                Emission: raytracer.AsVector3(color).Scale(4.0) }
+    }
+
+    return raytracer.Lambertian {
+        Color: color,
+        Attenuation: raytracer.AsVector3(color) }
+}
+
+// defaultSettings returns render settings with the blue sky of the example configs
+func defaultSettings(width, height int, samples uint) raytracer.Config {
+    return raytracer.Config {
+        SkyColorTop: raytracer.NewVector3(0.15686275, 0.4117647, 0.81960785),
+        SkyColorBottom: raytracer.NewVector3(1.0, 0.9372549, 0.5411765),
+        MaxBounces: 4,
+        MaxRaysPerBounce: 2,
+        MaxAntialiasRays: uint32(samples),
+        WidthInPixels: width,
+        HeightInPixels: height }
+}
+
+// frameCamera points the global camera at the middle of the spheres from above and in front, far enough away for all of them to fit in the picture
+func frameCamera(spheres []placement, fov, aspectRatio float32) {
+    box := raytracer.EmptyBoundingBox()
+    for _, p := range spheres {
+        r := raytracer.NewVector3(p.Radius, p.Radius, p.Radius)
+        box = box.AddPoint(p.Origin.Subtract(r)).AddPoint(p.Origin.Add(r))
+    }
+    if (len(spheres) == 0) {
+        box = raytracer.BoundingBox{ Min: raytracer.NewVector3(-1, -1, -1), Max: raytracer.NewVector3(1, 1, 1) }
+    }
+
+    middle := box.Min.Add(box.Max).Scale(0.5)
+    up := raytracer.NewVector3(0.0, 1.0, 0.0)
+    backward := raytracer.NewVector3(0.35, 0.45, 1.0).UnitVector()
+    right := up.Cross(backward).UnitVector()
+    cameraUp := backward.Cross(right)
+
+    // The camera backs away until every corner of the box is inside both the vertical and horizontal field of view
+    tanVertical := math.Tan(float64(raytracer.ConvertDegreesToRadians(fov)) / 2.0)
+    tanHorizontal := tanVertical * float64(aspectRatio)
+    distance := 0.0
+    for corner := 0; corner < 8; corner++ {
+        p := box.Min
+        if (corner & 1 != 0) {
+            p.X = box.Max.X
+        }
+        if (corner & 2 != 0) {
+            p.Y = box.Max.Y
+        }
+        if (corner & 4 != 0) {
+            p.Z = box.Max.Z
+        }
+        p = p.Subtract(middle)
+
+        depth := float64(p.Dot(backward))
+        distance = math.Max(distance, depth + math.Abs(float64(p.Dot(right))) / tanHorizontal)
+        distance = math.Max(distance, depth + math.Abs(float64(p.Dot(cameraUp))) / tanVertical)
+    }
+
+    // A little room is left around the edges
+    distance *= 1.05
+
     _ = raytracer.CreateCameraFromPos(
-        raytracer.Vector3 {
-            X: 0.0,
-            Y: 0.0,
-            Z: -1.0 },
-        raytracer.Vector3 {
-            X: 0.0,
-            Y: 0.0,
-            Z: 0.0 },
-        raytracer.Vector3 {
-            X: 0.0,
-            Y: 1.0,
-            Z: 0.0 },
-        120,
-        4.0 / 3.0)
-    raytracer.ExportCamera("camera.json")    
-     
-}
\ No newline at end of file
+        middle,
+        middle.Add(backward.Scale(float32(distance))),
+        up,
+        fov,
+        aspectRatio)
+}
@@ -0,0 +1,274 @@
+package main
+
+import
+(
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+    "math/rand"
+    "github.com/vohumana/vohumana-gotracer/raytracer"
+)
+
+// template adds a reference scene to the global scene and sets the global settings and camera to render it, r is used by templates with random parts
+type template func(r *rand.Rand)
+
+// createTemplate returns the reference scene with the given name, one of cornell, rtiow, materials or furnace
+func createTemplate(name string) template {
+    switch name {
+        case "cornell":
+            return cornellBox
+        case "rtiow":
+            return oneWeekendCover
+        case "materials":
+            return materialGrid
+        case "furnace":
+            return furnaceTest
+    }
+
+    log.Fatalf("Unknown template %v, expected cornell, rtiow, materials or furnace", name)
+    return nil
+}
+
+// lambertian returns a diffuse material of the given color
+func lambertian(attenuation raytracer.Vector3) raytracer.Lambertian {
+    return raytracer.Lambertian {
+        Color: attenuation.AsColor(),
+        Attenuation: attenuation }
+}
+
+// definition returns a material as a material library definition
+func definition(material raytracer.Material) raytracer.MaterialDefinition {
+    b, err := json.Marshal(material)
+    checkError(err)
+
+    var d raytracer.MaterialDefinition
+    checkError(json.Unmarshal(b, &d))
+    return d
+}
+
+// quadMesh returns a flat four sided mesh with the corners in order around its edge
+func quadMesh(a, b, c, d raytracer.Vector3) *raytracer.Mesh {
+    return raytracer.NewMesh([]raytracer.Vector3{ a, b, c, d }, nil, nil, []uint32{ 0, 1, 2, 0, 2, 3 }, nil, nil)
+}
+
+// boxMesh returns a box from the origin to size turned by angle degrees around the Y axis and then moved by offset
+func boxMesh(size raytracer.Vector3, angle float64, offset raytracer.Vector3) *raytracer.Mesh {
+    sin, cos := math.Sincos(angle * math.Pi / 180.0)
+
+    // Corner i has the size's X when bit 1 of i is set, its Y for bit 2 and its Z for bit 4
+    positions := make([]raytracer.Vector3, 8)
+    for i := range positions {
+        var p raytracer.Vector3
+        if (i & 1 != 0) {
+            p.X = size.X
+        }
+        if (i & 2 != 0) {
+            p.Y = size.Y
+        }
+        if (i & 4 != 0) {
+            p.Z = size.Z
+        }
+
+        positions[i] = raytracer.NewVector3(
+            float32(cos * float64(p.X) + sin * float64(p.Z)),
+            p.Y,
+            float32(-sin * float64(p.X) + cos * float64(p.Z))).Add(offset)
+    }
+
+    var indices []uint32
+    for _, face := range [][4]uint32{ { 0, 2, 6, 4 }, { 1, 5, 7, 3 }, { 0, 4, 5, 1 }, { 2, 3, 7, 6 }, { 0, 1, 3, 2 }, { 4, 6, 7, 5 } } {
+        indices = append(indices, face[0], face[1], face[2], face[0], face[2], face[3])
+    }
+
+    return raytracer.NewMesh(positions, nil, nil, indices, nil, nil)
+}
+
+// cornellBox is the Cornell box with a red wall on the left, a green wall on the right, a square light in the ceiling and a tall and a short box, measured the way Ray Tracing: The Next Week does.
+// Only light from the ceiling lights the box, the sky is black
+func cornellBox(r *rand.Rand) {
+    raytracer.Scene.AddMaterial("white", definition(lambertian(raytracer.NewVector3(0.73, 0.73, 0.73))))
+    raytracer.Scene.AddMaterial("red", definition(lambertian(raytracer.NewVector3(0.65, 0.05, 0.05))))
+    raytracer.Scene.AddMaterial("green", definition(lambertian(raytracer.NewVector3(0.12, 0.45, 0.15))))
+    raytracer.Scene.AddMaterial("light", definition(raytracer.Emissive{ Emission: raytracer.NewVector3(15.0, 15.0, 15.0) }))
+
+    corner := func(x, y, z float32) raytracer.Vector3 {
+        return raytracer.NewVector3(x, y, z)
+    }
+    raytracer.Scene.AddObjectWithMaterial("floor", quadMesh(corner(0, 0, 0), corner(555, 0, 0), corner(555, 0, 555), corner(0, 0, 555)), "white", nil)
+    raytracer.Scene.AddObjectWithMaterial("ceiling", quadMesh(corner(0, 555, 0), corner(555, 555, 0), corner(555, 555, 555), corner(0, 555, 555)), "white", nil)
+    raytracer.Scene.AddObjectWithMaterial("back wall", quadMesh(corner(0, 0, 555), corner(555, 0, 555), corner(555, 555, 555), corner(0, 555, 555)), "white", nil)
+    raytracer.Scene.AddObjectWithMaterial("left wall", quadMesh(corner(555, 0, 0), corner(555, 555, 0), corner(555, 555, 555), corner(555, 0, 555)), "red", nil)
+    raytracer.Scene.AddObjectWithMaterial("right wall", quadMesh(corner(0, 0, 0), corner(0, 555, 0), corner(0, 555, 555), corner(0, 0, 555)), "green", nil)
+    raytracer.Scene.AddObjectWithMaterial("light", quadMesh(corner(213, 554, 227), corner(343, 554, 227), corner(343, 554, 332), corner(213, 554, 332)), "light", nil)
+    raytracer.Scene.AddObjectWithMaterial("tall box", boxMesh(corner(165, 330, 165), 15.0, corner(265, 0, 295)), "white", nil)
+    raytracer.Scene.AddObjectWithMaterial("short box", boxMesh(corner(165, 165, 165), -18.0, corner(130, 0, 65)), "white", nil)
+
+    raytracer.Settings = raytracer.Config {
+        MaxBounces: 50,
+        MaxRaysPerBounce: 1,
+        MaxAntialiasRays: 200,
+        WidthInPixels: 600,
+        HeightInPixels: 600 }
+
+    _ = raytracer.CreateCameraFromPos(
+        corner(278, 278, 0),
+        corner(278, 278, -800),
+        corner(0, 1, 0),
+        40,
+        1.0)
+}
+
+// oneWeekendCover is the final scene of Ray Tracing in One Weekend: a field of small random spheres around a large glass, diffuse and metal sphere seen with a shallow depth of field
+func oneWeekendCover(r *rand.Rand) {
+    raytracer.Scene.AddObject("ground", raytracer.Sphere {
+        Origin: raytracer.NewVector3(0, -1000, 0),
+        Radius: 1000,
+        Properties: lambertian(raytracer.NewVector3(0.5, 0.5, 0.5)) })
+
+    randomColor := func(min, max float32) raytracer.Vector3 {
+        return raytracer.NewVector3(min + (r.Float32() * (max - min)), min + (r.Float32() * (max - min)), min + (r.Float32() * (max - min)))
+    }
+    glass := raytracer.Dielectric {
+        Attenuation: raytracer.NewVector3(1, 1, 1),
+        RefractiveIndex: 1.5 }
+
+    for a := -11; a < 11; a++ {
+        for b := -11; b < 11; b++ {
+            chooseMaterial := r.Float32()
+            center := raytracer.NewVector3(float32(a) + (0.9 * r.Float32()), 0.2, float32(b) + (0.9 * r.Float32()))
+            if (center.Subtract(raytracer.NewVector3(4, 0.2, 0)).Length() <= 0.9) {
+                continue
+            }
+
+            sphere := raytracer.Sphere {
+                Origin: center,
+                Radius: 0.2 }
+            if (chooseMaterial < 0.8) {
+                sphere.Properties = lambertian(randomColor(0, 1).Multiply(randomColor(0, 1)))
+            } else if (chooseMaterial < 0.95) {
+                attenuation := randomColor(0.5, 1)
+                sphere.Properties = raytracer.Metal {
+                    Color: attenuation.AsColor(),
+                    Attenuation: attenuation,
+                    Fuzziness: r.Float32() * 0.5 }
+            } else {
+                sphere.Properties = glass
+            }
+
+            raytracer.Scene.AddObject(fmt.Sprintf("small %v %v", a, b), sphere)
+        }
+    }
+
+    raytracer.Scene.AddObject("glass", raytracer.Sphere {
+        Origin: raytracer.NewVector3(0, 1, 0),
+        Radius: 1,
+        Properties: glass })
+    raytracer.Scene.AddObject("diffuse", raytracer.Sphere {
+        Origin: raytracer.NewVector3(-4, 1, 0),
+        Radius: 1,
+        Properties: lambertian(raytracer.NewVector3(0.4, 0.2, 0.1)) })
+    raytracer.Scene.AddObject("metal", raytracer.Sphere {
+        Origin: raytracer.NewVector3(4, 1, 0),
+        Radius: 1,
+        Properties: raytracer.Metal {
+            Color: raytracer.NewVector3(0.7, 0.6, 0.5).AsColor(),
+            Attenuation: raytracer.NewVector3(0.7, 0.6, 0.5),
+            Fuzziness: 0.0 } })
+
+    raytracer.Settings = raytracer.Config {
+        SkyColorTop: raytracer.NewVector3(0.5, 0.7, 1.0),
+        SkyColorBottom: raytracer.NewVector3(1.0, 1.0, 1.0),
+        MaxBounces: 50,
+        MaxRaysPerBounce: 1,
+        MaxAntialiasRays: 500,
+        WidthInPixels: 1200,
+        HeightInPixels: 675 }
+
+    _ = raytracer.CreateCameraFromPos(
+        raytracer.NewVector3(0, 0, 0),
+        raytracer.NewVector3(13, 2, 3),
+        raytracer.NewVector3(0, 1, 0),
+        20,
+        16.0 / 9.0)
+    raytracer.SetCameraAperture(0.1, 10.0)
+}
+
+// materialSteps is the number of spheres in each row of the material grid
+const materialSteps = 6
+
+// materialGrid is two rows of spheres, metal going from a mirror to fully fuzzy at the back and glass going from a refractive index of 1 to that of diamond at the front.
+// Every sphere refers to a library material and only overrides the field that changes
+func materialGrid(r *rand.Rand) {
+    raytracer.Scene.AddMaterial("ground", definition(lambertian(raytracer.NewVector3(0.5, 0.5, 0.5))))
+    raytracer.Scene.AddMaterial("metal", definition(raytracer.Metal {
+        Color: raytracer.NewVector3(0.8, 0.8, 0.8).AsColor(),
+        Attenuation: raytracer.NewVector3(0.8, 0.8, 0.8),
+        Fuzziness: 0.0 }))
+    raytracer.Scene.AddMaterial("glass", definition(raytracer.Dielectric {
+        Attenuation: raytracer.NewVector3(1, 1, 1),
+        RefractiveIndex: 1.5 }))
+
+    raytracer.Scene.AddObjectWithMaterial("ground", raytracer.Sphere {
+        Origin: raytracer.NewVector3(0, -1000, 0),
+        Radius: 1000 }, "ground", nil)
+
+    for i := 0; i < materialSteps; i++ {
+        step := float64(i) / float64(materialSteps - 1)
+        x := float32(i) * 1.2
+
+        fuzziness := step
+        raytracer.Scene.AddObjectWithMaterial(fmt.Sprintf("metal fuzziness %.1f", fuzziness), raytracer.Sphere {
+            Origin: raytracer.NewVector3(x, 0.5, -0.7),
+            Radius: 0.5 }, "metal", raytracer.MaterialDefinition{ "Fuzziness": fuzziness })
+
+        refractiveIndex := 1.0 + (1.4 * step)
+        raytracer.Scene.AddObjectWithMaterial(fmt.Sprintf("glass refractive index %.2f", refractiveIndex), raytracer.Sphere {
+            Origin: raytracer.NewVector3(x, 0.5, 0.7),
+            Radius: 0.5 }, "glass", raytracer.MaterialDefinition{ "RefractiveIndex": refractiveIndex })
+    }
+
+    // A pale sky keeps the ground and reflections neutral so only the materials differ
+    raytracer.Settings = raytracer.Config {
+        SkyColorTop: raytracer.NewVector3(0.5, 0.7, 1.0),
+        SkyColorBottom: raytracer.NewVector3(1.0, 1.0, 1.0),
+        MaxBounces: 16,
+        MaxRaysPerBounce: 1,
+        MaxAntialiasRays: 64,
+        WidthInPixels: 960,
+        HeightInPixels: 540 }
+
+    middle := float32(materialSteps - 1) * 0.6
+    _ = raytracer.CreateCameraFromPos(
+        raytracer.NewVector3(middle, 0.3, 0),
+        raytracer.NewVector3(middle, 4, 7),
+        raytracer.NewVector3(0, 1, 0),
+        30,
+        16.0 / 9.0)
+}
+
+// furnaceTest is a white diffuse sphere inside a mid gray sky that is equally bright in every direction.
+// A sphere that reflects all light and loses none of it looks exactly as bright as the sky, so any outline of the sphere in the image shows energy being lost or gained.
+// The sky is kept well below white so a sphere that gains energy is not clipped to the same white as the sky around it
+func furnaceTest(r *rand.Rand) {
+    raytracer.Scene.AddObject("sphere", raytracer.Sphere {
+        Origin: raytracer.NewVector3(0, 0, 0),
+        Radius: 1,
+        Properties: lambertian(raytracer.NewVector3(1, 1, 1)) })
+
+    raytracer.Settings = raytracer.Config {
+        SkyColorTop: raytracer.NewVector3(0.5, 0.5, 0.5),
+        SkyColorBottom: raytracer.NewVector3(0.5, 0.5, 0.5),
+        MaxBounces: 64,
+        MaxRaysPerBounce: 1,
+        MaxAntialiasRays: 64,
+        WidthInPixels: 256,
+        HeightInPixels: 256 }
+
+    _ = raytracer.CreateCameraFromPos(
+        raytracer.NewVector3(0, 0, 0),
+        raytracer.NewVector3(0, 0, 4),
+        raytracer.NewVector3(0, 1, 0),
+        40,
+        1.0)
+}
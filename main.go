@@ -2,19 +2,28 @@ package main
 
 import
 (
+    "context"
     "flag"
     "fmt"
-	"image"
-    "image/color"
-	"image/png"
+    "io"
 	"log"
-    "math/rand"
 	"os"
+    "os/signal"
+    "path/filepath"
+    "strconv"
+    "strings"
     "time"
     "github.com/vohumana/vohumana-gotracer/raytracer"
 )
 
-var communicationChannel chan bool
+// progressReporter shows the progress of every render, nil for none
+var progressReporter raytracer.ProgressReporter
+
+// messages is where status messages are printed, stderr when stdout is kept for json progress
+var messages io.Writer = os.Stdout
+
+// showStatistics prints a statistics summary after every render and writeStatistics writes them as json next to every image
+var showStatistics, writeStatistics bool
 
 func checkError(err error) {
 	if (err != nil) {
@@ -23,24 +32,104 @@ func checkError(err error) {
 }
 
 func main() {
+    if (len(os.Args) > 1 && os.Args[1] == "serve") {
+        serve(os.Args[2:])
+        return
+    }
+    if (len(os.Args) > 1 && os.Args[1] == "metadata") {
+        metadata(os.Args[2:])
+        return
+    }
+    if (len(os.Args) > 1 && os.Args[1] == "migrate") {
+        migrate(os.Args[2:])
+        return
+    }
+    if (len(os.Args) > 1 && os.Args[1] == "validate") {
+        validate(os.Args[2:])
+        return
+    }
+    
     var configFilename string
     var sceneFilename string
     var cameraFilename string
+    var animationFilename string
+    var frameRange string
+    var outputFilename string
+    var skipExisting bool
+    var progressive bool
+    var passSamples uint
+    var targetSamples uint
+    var timeLimit time.Duration
+    var previewInterval time.Duration
+    var previewPasses int
+    var checkpointFilename string
+    var checkpointInterval time.Duration
+    var resume bool
+    var cropWindow string
+    var cropNormalized bool
+    var cropOutput string
+    var coordinatorAddress string
+    var workerAddress string
+    var network string
+    var tileSize int
+    var tileTimeout time.Duration
+    var timeout time.Duration
+    var progressType string
     
     // Get command line parameters
-	flag.StringVar(&configFilename, "config", "", "JSON filename describing how the ray tracer should render")
-	flag.StringVar(&sceneFilename, "scene", "", "JSON filename containing the scene to render")
-	flag.StringVar(&cameraFilename, "camera", "", "JSON filename containing the camera position and stats")
+	flag.StringVar(&configFilename, "config", "", "JSON filename describing how the ray tracer should render, overrides a scene document's settings")
+	flag.StringVar(&sceneFilename, "scene", "", "Scene document to render, or a scene file from before scene documents along with -config and -camera")
+	flag.StringVar(&cameraFilename, "camera", "", "JSON filename containing the camera position and stats, overrides a scene document's camera")
+	flag.StringVar(&animationFilename, "animation", "", "JSON filename containing camera and object keyframes, renders an image sequence")
+	flag.StringVar(&frameRange, "frames", "", "Frames of the animation to render as first-last or a single frame, defaults to the range in the animation file")
+	flag.StringVar(&outputFilename, "output", "", "Image filename to write, for animations a Printf pattern for the frame number like frame%04d.png")
+	flag.BoolVar(&skipExisting, "skipexisting", false, "Skip animation frames whose image already exists")
+	flag.BoolVar(&progressive, "progressive", false, "Render the whole image in passes of samples, rewriting the output image as it improves")
+	flag.UintVar(&passSamples, "passsamples", 1, "Samples per pixel added by each progressive pass")
+	flag.UintVar(&targetSamples, "samples", 0, "Samples per pixel to stop a progressive render at, defaults to MaxAntialiasRays unless -timelimit is set")
+	flag.DurationVar(&timeLimit, "timelimit", 0, "Time budget for a progressive render such as 30m, the render stops after the first pass that ends past it")
+	flag.DurationVar(&previewInterval, "previewinterval", 10 * time.Second, "Time between progressive preview images, 0 to disable")
+	flag.IntVar(&previewPasses, "previewpasses", 0, "Passes between progressive preview images, 0 to disable")
+	flag.StringVar(&checkpointFilename, "checkpoint", "", "File to periodically save a progressive render's state to so it can be resumed, implies -progressive")
+	flag.DurationVar(&checkpointInterval, "checkpointinterval", 5 * time.Minute, "Time between checkpoints, 0 to only save one when the render stops")
+	flag.BoolVar(&resume, "resume", false, "Continue the render saved in the -checkpoint file if it exists")
+	flag.StringVar(&cropWindow, "crop", "", "Only render the region minX,minY,maxX,maxY of the image, overrides the config's CropWindow")
+	flag.BoolVar(&cropNormalized, "cropnormalized", false, "The -crop region is given as fractions of the image size instead of pixels")
+	flag.StringVar(&cropOutput, "cropoutput", "", "Write a crop as cropped or full to keep the whole frame with black outside of it, overrides the config's CropOutput")
+	flag.StringVar(&coordinatorAddress, "coordinator", "", "Listen on this address and hand out tiles to -worker processes instead of rendering locally")
+	flag.StringVar(&workerAddress, "worker", "", "Render tiles for the coordinator at this address, the coordinator sends the config, scene and camera")
+	flag.StringVar(&network, "network", "tcp", "Network used between the coordinator and workers, tcp or unix")
+	flag.IntVar(&tileSize, "tilesize", 32, "Width and height in pixels of the tiles handed to workers")
+	flag.DurationVar(&tileTimeout, "tiletimeout", 10 * time.Minute, "Time a worker may take on one tile, loading the scene included for its first, before its tile is given to another worker, 0 waits forever")
+	flag.DurationVar(&timeout, "timeout", 0, "Stop rendering after this long and write out the part of the image that is done, 0 for no limit")
+	flag.StringVar(&progressType, "progress", "bar", "Progress output, bar for a progress bar with the time remaining, json for json lines on stdout or none")
+	flag.BoolVar(&showStatistics, "stats", false, "Count rays, intersection tests per object and tile times and print a summary after the render")
+	flag.BoolVar(&writeStatistics, "statsjson", false, "Count the same as -stats and write them as json next to the image, frame.png gets frame.stats.json")
 	flag.Parse()
+	
+	if (progressType == raytracer.JSONProgressType) {
+	    messages = os.Stderr
+	}
+	progressReporter = raytracer.CreateProgressReporter(progressType, os.Stdout)
+	
+	if (workerAddress != "") {
+	    checkError(raytracer.RunWorker(network, workerAddress, 30 * time.Second))
+	    return
+	}
 
-	if (configFilename == "" || sceneFilename == "" || cameraFilename == "") {
+	if (sceneFilename == "") {
 		flag.PrintDefaults()
 		return
 	}
     
-    raytracer.ImportConfig(configFilename)
-    raytracer.ImportScene(sceneFilename)
-    raytracer.ImportCamera(cameraFilename)
+    raytracer.ImportSceneFiles(configFilename, sceneFilename, cameraFilename)
+    
+    if (cropWindow != "") {
+        raytracer.Settings.CropWindow = parseCropWindow(cropWindow, cropNormalized)
+    }
+    if (cropOutput != "") {
+        raytracer.Settings.CropOutput = cropOutput
+    }
     
     // emissiveSphere := raytracer.Sphere {
     //     Origin: raytracer.NewVector3(0.0, 4, -5),
@@ -50,81 +139,388 @@ func main() {
     
     // raytracer.Scene.AddObject("emissiveSphere", emissiveSphere)
     
-    xSize := raytracer.Settings.WidthInPixels
-    ySize := raytracer.Settings.HeightInPixels
-    bounds := image.Rectangle{image.Point{0,0}, image.Point{xSize, ySize}}
-
-    rayTracedFrame := image.NewRGBA(bounds)
-    communicationChannel = make(chan bool)
+    // Ctrl+C or the timeout stop the render where it is, what is done so far is still written out
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    if (timeout > 0) {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+        defer cancel()
+    }
     
-    startTime := time.Now()
-    fmt.Printf("Beginning ray trace at resolution %v x %v\n", xSize, ySize)
-    for y := 0; y < ySize; y++ {
-        go RayTraceScanLine(rayTracedFrame, y, xSize, ySize)
+    if (animationFilename == "") {
+        if (outputFilename == "") {
+            outputFilename = "rayframe.png"
+        }
+        
+        if (coordinatorAddress != "") {
+            renderer, err := renderDistributed(ctx, outputFilename, raytracer.CoordinatorOptions {
+                Network: network,
+                Address: coordinatorAddress,
+                TileSize: tileSize,
+                TileTimeout: tileTimeout })
+            writeRenderedImage(outputFilename, renderer, err)
+            return
+        }
+        
+        if (progressive || checkpointFilename != "") {
+            renderProgressive(ctx, outputFilename, checkpointFilename, resume, raytracer.ProgressiveOptions {
+                SamplesPerPass: uint32(passSamples),
+                TargetSamples: uint32(targetSamples),
+                TimeLimit: timeLimit,
+                PreviewInterval: previewInterval,
+                PreviewPasses: previewPasses,
+                CheckpointInterval: checkpointInterval })
+            return
+        }
+        
+        renderer, err := renderFrame(ctx, outputFilename)
+        writeRenderedImage(outputFilename, renderer, err)
+        return
     }
     
-    fmt.Println("All routines are running, now waiting")
+    raytracer.ImportAnimation(animationFilename)
+    firstFrame, lastFrame := raytracer.AnimationFrameRange()
+    if (frameRange != "") {
+        firstFrame, lastFrame = parseFrameRange(frameRange)
+    }
     
-    previousPercent := uint8(0)
-    completedRoutines := 0
-    for completedRoutines != ySize {
-        percentComplete := uint8((float32(completedRoutines) / float32(ySize)) * 100.0) 
+    if (outputFilename == "") {
+        outputFilename = "frame%04d.png"
+    } else if (!strings.Contains(outputFilename, "%")) {
+        extension := filepath.Ext(outputFilename)
+        outputFilename = strings.TrimSuffix(outputFilename, extension) + "%04d" + extension
+    }
+    
+    for frame := firstFrame; frame <= lastFrame; frame++ {
+        frameFilename := fmt.Sprintf(outputFilename, frame)
+        if (skipExisting) {
+            if _, err := os.Stat(frameFilename); err == nil {
+                printf("Skipping frame %v, %v already exists\n", frame, frameFilename)
+                continue
+            }
+        }
         
-        if previousPercent != percentComplete {
-            fmt.Printf("%v%% Complete\n", percentComplete)
-            previousPercent = percentComplete   
+        printf("Rendering frame %v of %v-%v\n", frame, firstFrame, lastFrame)
+        raytracer.ApplyAnimationFrame(frame)
+        renderer, err := renderFrame(ctx, frameFilename)
+        
+        // An interrupted frame is kept under another name so -skipexisting renders it again on the next run
+        partialFilename := frameFilename + ".partial"
+        if (err != nil) {
+            writeRenderedImage(partialFilename, renderer, err)
+        }
+        writeImage(frameFilename, renderer)
+        os.Remove(partialFilename)
+    }
+}
+
+// serve runs the HTTP render server, jobs are posted to it as json bundles of a config, scene and camera
+func serve(arguments []string) {
+    var address string
+    
+    flags := flag.NewFlagSet("serve", flag.ExitOnError)
+    flags.StringVar(&address, "address", "localhost:8080", "Address to serve the job API and live preview page on")
+    flags.Parse(arguments)
+    
+    fmt.Printf("Serving render jobs on http://%v/\n", address)
+    checkError(raytracer.Serve(address))
+}
+
+// migrate converts separate config, scene and camera files to a single scene document
+func migrate(arguments []string) {
+    var configFilename string
+    var sceneFilename string
+    var cameraFilename string
+    var outputFilename string
+    
+    flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+    flags.StringVar(&configFilename, "config", "", "Config file to migrate")
+    flags.StringVar(&sceneFilename, "scene", "", "Scene file to migrate")
+    flags.StringVar(&cameraFilename, "camera", "", "Camera file to migrate")
+    flags.StringVar(&outputFilename, "output", "", "Scene document to write")
+    flags.Parse(arguments)
+    
+    if (configFilename == "" || sceneFilename == "" || cameraFilename == "" || outputFilename == "") {
+        flags.PrintDefaults()
+        os.Exit(2)
+    }
+    
+    raytracer.MigrateSceneFiles(configFilename, sceneFilename, cameraFilename, outputFilename)
+    fmt.Printf("Wrote %v\n", outputFilename)
+}
+
+// validate checks config, camera and scene files and prints every problem found, it exits with status 1 when there are any
+func validate(arguments []string) {
+    var configFilename string
+    var sceneFilename string
+    var cameraFilename string
+    var schemaDirectory string
+    
+    flags := flag.NewFlagSet("validate", flag.ExitOnError)
+    flags.StringVar(&configFilename, "config", "", "Config file to check")
+    flags.StringVar(&sceneFilename, "scene", "", "Scene document or scene file to check")
+    flags.StringVar(&cameraFilename, "camera", "", "Camera file to check")
+    flags.StringVar(&schemaDirectory, "schema", "", "Write JSON Schemas of config, camera, scene and scene document files to this directory for editors to use")
+    flags.Parse(arguments)
+    
+    if (configFilename == "" && sceneFilename == "" && cameraFilename == "" && schemaDirectory == "") {
+        flags.PrintDefaults()
+        os.Exit(2)
+    }
+    
+    if (schemaDirectory != "") {
+        for _, filename := range raytracer.WriteSchemas(schemaDirectory) {
+            fmt.Printf("Wrote %v\n", filename)
+        }
+    }
+    
+    files := []struct {
+        filename string
+        check func(string) []raytracer.ValidationProblem
+    } {
+        { configFilename, raytracer.ValidateConfig },
+        { sceneFilename, raytracer.ValidateScene },
+        { cameraFilename, raytracer.ValidateCamera } }
+    
+    failed := false
+    for _, file := range files {
+        if (file.filename == "") {
+            continue
         }
         
-        success := <-communicationChannel
-        if success {
-            completedRoutines++
+        problems := file.check(file.filename)
+        for _, problem := range problems {
+            fmt.Printf("%v: %v\n", file.filename, problem)
+        }
+        if (len(problems) == 0) {
+            fmt.Printf("%v: ok\n", file.filename)
         }
+        failed = failed || len(problems) > 0
     }
     
-    elapsedTime := time.Since(startTime)
-    fmt.Printf("Render duration was: %v s", elapsedTime.Seconds())
+    if (failed) {
+        os.Exit(1)
+    }
+}
+
+// metadata prints the render metadata stored in an image and writes out the config, scene and camera it was rendered from
+func metadata(arguments []string) {
+    var configFilename string
+    var sceneFilename string
+    var cameraFilename string
+    var documentFilename string
     
-    outFile, err := os.Create("rayframe.png")
+    flags := flag.NewFlagSet("metadata", flag.ExitOnError)
+    flags.StringVar(&configFilename, "config", "", "Write the config the image was rendered with to this file")
+    flags.StringVar(&sceneFilename, "scene", "", "Write the scene the image was rendered from to this file")
+    flags.StringVar(&cameraFilename, "camera", "", "Write the camera the image was rendered with to this file")
+    flags.StringVar(&documentFilename, "document", "", "Write the config, scene and camera the image was rendered from to this file as a scene document")
+    flags.Usage = func() {
+        fmt.Fprintf(flags.Output(), "Usage: %v metadata [flags] image.png\n", os.Args[0])
+        flags.PrintDefaults()
+    }
+    flags.Parse(arguments)
+    
+    if (flags.NArg() != 1) {
+        flags.Usage()
+        os.Exit(2)
+    }
+    
+    renderMetadata, err := raytracer.ReadImageMetadata(flags.Arg(0))
     checkError(err)
-    defer outFile.Close()
+    renderMetadata.WriteSummary(os.Stdout)
+    
+    if (false == renderMetadata.CheckInputs()) {
+        fmt.Println("Warning: the stored inputs do not match the input hash, this build may not render the image the same way")
+    }
+    
+    renderMetadata.ExportInputs(configFilename, sceneFilename, cameraFilename)
+    if (documentFilename != "") {
+        renderMetadata.ExportSceneDocument(documentFilename)
+    }
+    for _, filename := range []string{ configFilename, sceneFilename, cameraFilename, documentFilename } {
+        if (filename != "") {
+            fmt.Printf("Wrote %v\n", filename)
+        }
+    }
+}
+
+// parseFrameRange parses a frame range given as first-last or a single frame number
+func parseFrameRange(frameRange string) (int, int) {
+    parts := strings.SplitN(frameRange, "-", 2)
     
-    err = png.Encode(outFile, rayTracedFrame)
+    first, err := strconv.Atoi(strings.TrimSpace(parts[0]))
     checkError(err)
+    
+    last := first
+    if (len(parts) == 2) {
+        last, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+        checkError(err)
+    }
+    
+    if (last < first) {
+        log.Fatalf("Frame range %v ends before it starts", frameRange)
+    }
+    
+    return first, last
 }
 
-// RayTraceScanLine will perform ray tracing for a single line of the image
-func RayTraceScanLine(frame *image.RGBA, y, maxX, maxY int) {
-    for x := 0; x < maxX; x++ { 
-        var red, green, blue float32
-        
-        for s := uint32(0); s < raytracer.Settings.MaxAntialiasRays; s++ {
-            u := (float32(x) + rand.Float32()) / float32(maxX)
-            v := (float32(y) + rand.Float32()) / float32(maxY)
-                    
-            r := raytracer.Ray{
-                Origin: raytracer.GlobalCamera.Origin,
-                Direction: raytracer.GlobalCamera.UpperLeftCorner.Add(raytracer.GlobalCamera.ImagePlaneHorizontal.Scale(u)).Add(raytracer.GlobalCamera.ImagePlaneVertical.Scale(v)).Subtract(raytracer.GlobalCamera.Origin).UnitVector() }
-                
-            color := raytracer.ShootRay(r, raytracer.Scene, 0)
-            
-            red += float32(color.R)
-            green += float32(color.G)
-            blue += float32(color.B)
+// parseCropWindow parses a crop window given as minX,minY,maxX,maxY
+func parseCropWindow(window string, normalized bool) raytracer.CropWindow {
+    parts := strings.Split(window, ",")
+    if (len(parts) != 4) {
+        log.Fatalf("Crop window %v should be minX,minY,maxX,maxY", window)
+    }
+    
+    var bounds [4]float32
+    for i, part := range parts {
+        value, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+        checkError(err)
+        bounds[i] = float32(value)
+    }
+    
+    crop := raytracer.CropWindow {
+        MinX: bounds[0],
+        MinY: bounds[1],
+        MaxX: bounds[2],
+        MaxY: bounds[3],
+        Normalized: normalized }
+    
+    if (crop.IsEmpty()) {
+        log.Fatalf("Crop window %v has no area", window)
+    }
+    
+    return crop
+}
+
+// createRenderer creates a renderer for the current scene that reports progress and collects statistics as the flags ask
+func createRenderer() *raytracer.Renderer {
+    renderer := raytracer.CreateRenderer()
+    renderer.Progress = progressReporter
+    renderer.CollectStatistics = showStatistics || writeStatistics
+    return renderer
+}
+
+// renderFrame ray traces the current scene from the global camera, filename is the image the frame will be written to.
+// If ctx is done first it returns the renderer with the partly rendered image and the error saying how much was rendered
+func renderFrame(ctx context.Context, filename string) (*raytracer.Renderer, error) {
+    renderer := createRenderer()
+    
+    startTime := time.Now()
+    printf("Beginning ray trace at resolution %v x %v\n", raytracer.Settings.WidthInPixels, raytracer.Settings.HeightInPixels)
+    err := renderer.RenderPass(ctx, raytracer.Settings.MaxAntialiasRays)
+    printf("Render duration was: %v s\n", time.Since(startTime).Seconds())
+    reportStatistics(renderer, filename)
+    
+    return renderer, err
+}
+
+// renderProgressive ray traces the current scene in passes over the whole frame, writing filename after every preview so a usable image is always on disk.
+// When checkpointFilename is set the render state is saved there, and with resume an existing checkpoint is continued
+func renderProgressive(ctx context.Context, filename, checkpointFilename string, resume bool, options raytracer.ProgressiveOptions) {
+    renderer := createRenderer()
+    if (resume && checkpointFilename != "") {
+        if _, err := os.Stat(checkpointFilename); err == nil {
+            checkError(renderer.LoadCheckpoint(checkpointFilename))
+            printf("Resuming from %v at %v samples per pixel\n", checkpointFilename, renderer.SamplesPerPixel)
         }
-        
-        red /= float32(raytracer.Settings.MaxAntialiasRays)
-        green /= float32(raytracer.Settings.MaxAntialiasRays)
-        blue /= float32(raytracer.Settings.MaxAntialiasRays)
-        
-        c := color.RGBA {
-            R: uint8(red),
-            G: uint8(green),
-            B: uint8(blue),
-            A: 255 }
-        
-        // Render upside down because the image is upside down
-        frame.Set(x, y, c)
     }
     
-    communicationChannel <- true
-}
\ No newline at end of file
+    startTime := time.Now()
+    printf("Beginning progressive ray trace at resolution %v x %v\n", raytracer.Settings.WidthInPixels, raytracer.Settings.HeightInPixels)
+    
+    options.OnPreview = func(renderer *raytracer.Renderer) {
+        writeImage(filename, renderer)
+        printf("Wrote %v at %v samples per pixel after %v s\n", filename, renderer.SamplesPerPixel, time.Since(startTime).Seconds())
+    }
+    
+    if (checkpointFilename != "") {
+        options.OnCheckpoint = func(renderer *raytracer.Renderer) {
+            checkError(renderer.SaveCheckpoint(checkpointFilename))
+            printf("Saved checkpoint %v at %v samples per pixel\n", checkpointFilename, renderer.SamplesPerPixel)
+        }
+    }
+    
+    err := renderer.RenderProgressive(ctx, options)
+    if _, incomplete := err.(*raytracer.IncompleteRenderError); err != nil && false == incomplete {
+        checkError(err)
+    }
+    reportStatistics(renderer, filename)
+    if (err != nil) {
+        log.Fatalf("%v, %v holds what was rendered", err, filename)
+    }
+}
+
+// renderDistributed ray traces the current scene by handing out tiles to worker processes, filename is the image the frame will be written to.
+// If ctx is done first it returns the renderer with the tiles finished so far and the error saying how much was rendered
+func renderDistributed(ctx context.Context, filename string, options raytracer.CoordinatorOptions) (*raytracer.Renderer, error) {
+    renderer := createRenderer()
+    
+    startTime := time.Now()
+    printf("Beginning distributed ray trace at resolution %v x %v\n", raytracer.Settings.WidthInPixels, raytracer.Settings.HeightInPixels)
+    
+    err := renderer.RenderDistributed(ctx, raytracer.Settings.MaxAntialiasRays, options)
+    if _, incomplete := err.(*raytracer.IncompleteRenderError); err != nil && false == incomplete {
+        checkError(err)
+    }
+    printf("Render duration was: %v s\n", time.Since(startTime).Seconds())
+    reportStatistics(renderer, filename)
+    
+    return renderer, err
+}
+
+// reportStatistics prints the renderer's statistics and writes them next to the image filename when the flags ask for them
+func reportStatistics(renderer *raytracer.Renderer, filename string) {
+    if (false == showStatistics && false == writeStatistics) {
+        return
+    }
+    
+    statistics := renderer.Statistics()
+    if (showStatistics) {
+        var summary strings.Builder
+        statistics.WriteSummary(&summary)
+        printf("%v", summary.String())
+    }
+    
+    if (writeStatistics) {
+        statisticsFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".stats.json"
+        statistics.Export(statisticsFilename)
+        printf("Wrote statistics to %v\n", statisticsFilename)
+    }
+}
+
+// printf prints a status message without breaking up the progress bar
+func printf(format string, args ...interface{}) {
+    if bar, ok := progressReporter.(*raytracer.BarProgress); ok {
+        bar.Printf(format, args...)
+        return
+    }
+    
+    fmt.Fprintf(messages, format, args...)
+}
+
+// writeRenderedImage writes the renderer's image to filename, if the render was stopped early it then exits with the error saying how much was rendered
+func writeRenderedImage(filename string, renderer *raytracer.Renderer, err error) {
+    writeImage(filename, renderer)
+    if (err != nil) {
+        log.Fatalf("%v, %v holds what was rendered", err, filename)
+    }
+}
+
+// writeImage writes the renderer's image to filename as a png with the render metadata needed to render it again.
+// The image is written to a temporary file first and renamed over filename so readers never see a partly written image
+func writeImage(filename string, renderer *raytracer.Renderer) {
+    tempFilename := filename + ".tmp"
+    outFile, err := os.Create(tempFilename)
+    checkError(err)
+    
+    err = raytracer.WritePNG(outFile, renderer.Image(), renderer.Metadata())
+    checkError(err)
+    
+    err = outFile.Close()
+    checkError(err)
+    
+    err = os.Rename(tempFilename, filename)
+    checkError(err)
+}